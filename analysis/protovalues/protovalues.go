@@ -0,0 +1,416 @@
+// Package protovalues defines an Analyzer that reports protobuf messages
+// handled by value.
+//
+// Messages generated by protoc-gen-go hold internal state that must not be
+// copied, and the protobuf runtime only understands repeated message fields
+// as slices of pointers. Value slices and maps of messages, such as
+// []pb.User, are what protoc-gen-go-values generates for value_slice and
+// value_map fields, and are fine until they reach the runtime. The analyzer
+// reports:
+//
+//   - values passed to protobuf runtime APIs, such as proto.Marshal, that are
+//     value slices of messages or messages that hold one in a field, as
+//     protoc-gen-go-values generates for value_slice fields;
+//   - copies of message structs: dereferencing assignments, arguments and
+//     parameters of message type, and range loops over message values.
+//
+// Where a pointer-based alternative can be substituted in place, the
+// diagnostic carries it as a suggested fix. Generated files are not checked
+// unless the -generated flag is set.
+package protovalues
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// Analyzer reports protobuf messages handled by value.
+var Analyzer = &analysis.Analyzer{
+	Name: "protovalues",
+	Doc:  "report protobuf messages copied by value or held in value slices that reach the protobuf runtime",
+	URL:  "https://pkg.go.dev/github.com/benjamin-rood/protogo-values/analysis/protovalues",
+	Run:  run,
+}
+
+// checkGenerated reports whether generated files are checked too, as the
+// tests of protoc-gen-go-values do with its output
+var checkGenerated bool
+
+func init() {
+	Analyzer.Flags.BoolVar(&checkGenerated, "generated", false, "also check generated files")
+}
+
+// runtimePackages are the import path prefixes of the protobuf runtime
+var runtimePackages = []string{
+	"google.golang.org/protobuf/",
+	"github.com/golang/protobuf/",
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	for _, file := range pass.Files {
+		if ast.IsGenerated(file) && !checkGenerated {
+			continue
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.CallExpr:
+				checkCall(pass, n)
+			case *ast.AssignStmt:
+				checkAssign(pass, n)
+			case *ast.ValueSpec:
+				checkValueSpec(pass, n)
+			case *ast.RangeStmt:
+				checkRange(pass, n)
+			case *ast.FuncType:
+				checkSignature(pass, n)
+			}
+			return true
+		})
+	}
+	return nil, nil
+}
+
+// message returns the named struct type generated for a protobuf message,
+// recognised by the ProtoReflect method of its pointer type
+func message(t types.Type) (*types.Named, bool) {
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok {
+		return nil, false
+	}
+	if _, ok := named.Underlying().(*types.Struct); !ok {
+		return nil, false
+	}
+	obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(named), false, nil, "ProtoReflect")
+	if _, ok := obj.(*types.Func); !ok {
+		return nil, false
+	}
+	return named, true
+}
+
+// valueSliceElem returns the message type of a value slice of messages
+func valueSliceElem(t types.Type) (*types.Named, bool) {
+	slice, ok := t.Underlying().(*types.Slice)
+	if !ok {
+		return nil, false
+	}
+	return message(slice.Elem())
+}
+
+// heldValueSlice returns the path, such as "Order.Items", of a value slice
+// of messages held by the message t or a pointer to it, directly or through
+// its message fields
+func heldValueSlice(t types.Type) (path string, elem *types.Named, ok bool) {
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := message(t)
+	if !ok {
+		return "", nil, false
+	}
+	fields, elem, ok := findValueSlice(named, make(map[*types.Named]bool))
+	if !ok {
+		return "", nil, false
+	}
+	return named.Obj().Name() + "." + strings.Join(fields, "."), elem, true
+}
+
+// findValueSlice returns the field names leading from msg to a value slice
+// of messages
+func findValueSlice(msg *types.Named, seen map[*types.Named]bool) ([]string, *types.Named, bool) {
+	if seen[msg] {
+		return nil, nil, false
+	}
+	seen[msg] = true
+	fields := msg.Underlying().(*types.Struct)
+	for i := 0; i < fields.NumFields(); i++ {
+		field := fields.Field(i)
+		if elem, ok := valueSliceElem(field.Type()); ok {
+			return []string{field.Name()}, elem, true
+		}
+		nested := field.Type()
+		switch u := nested.Underlying().(type) {
+		case *types.Slice:
+			nested = u.Elem()
+		case *types.Map:
+			nested = u.Elem()
+		}
+		if ptr, ok := nested.Underlying().(*types.Pointer); ok {
+			nested = ptr.Elem()
+		}
+		named, ok := message(nested)
+		if !ok {
+			continue
+		}
+		if path, elem, ok := findValueSlice(named, seen); ok {
+			return append([]string{field.Name()}, path...), elem, true
+		}
+	}
+	return nil, nil, false
+}
+
+// checkCall reports value slices of messages passed to the protobuf runtime,
+// and messages passed by value to any function
+func checkCall(pass *analysis.Pass, call *ast.CallExpr) {
+	if tv, ok := pass.TypesInfo.Types[call.Fun]; ok && tv.IsType() {
+		return // a conversion
+	}
+	callee := typeutil.Callee(pass.TypesInfo, call)
+	name, runtime := runtimeAPI(pass, callee)
+	if runtime {
+		args := call.Args
+		// A method of the runtime, such as ProtoReflect, also receives the
+		// value it is called on
+		if sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr); ok {
+			if selection, ok := pass.TypesInfo.Selections[sel]; ok && selection.Kind() == types.MethodVal {
+				args = append([]ast.Expr{sel.X}, args...)
+			}
+		}
+		for _, arg := range args {
+			checkRuntimeArg(pass, arg, name)
+		}
+	}
+
+	for _, arg := range call.Args {
+		if named, ok := message(pass.TypesInfo.TypeOf(arg)); ok && copies(arg) {
+			pass.ReportRangef(arg, "call passes message %s by value, copying it; pass a *%s instead", qualified(pass, named), qualified(pass, named))
+		}
+	}
+}
+
+// checkRuntimeArg reports arg, passed to the protobuf runtime API name, if
+// it is or holds a value slice of messages
+func checkRuntimeArg(pass *analysis.Pass, arg ast.Expr, name string) {
+	t := pass.TypesInfo.TypeOf(arg)
+	if t == nil {
+		return
+	}
+	if elem, ok := valueSliceElem(t); ok {
+		pass.ReportRangef(arg, "value slice of message %s passed to %s: the protobuf runtime panics on value slices of messages; use []*%s", qualified(pass, elem), name, qualified(pass, elem))
+		return
+	}
+	if path, elem, ok := heldValueSlice(t); ok {
+		pass.ReportRangef(arg, "%s passed to %s holds a value slice of message %s in %s, which the protobuf runtime panics on; keep that field a []*%s", types.ExprString(arg), name, qualified(pass, elem), path, qualified(pass, elem))
+	}
+}
+
+// runtimeAPI reports whether callee belongs to the protobuf runtime, or is
+// the ProtoReflect method of a message, and how to name it
+func runtimeAPI(pass *analysis.Pass, callee types.Object) (string, bool) {
+	fn, ok := callee.(*types.Func)
+	if !ok || fn.Pkg() == nil {
+		return "", false
+	}
+	if recv := fn.Signature().Recv(); recv != nil && fn.Name() == "ProtoReflect" {
+		if ptr, ok := recv.Type().(*types.Pointer); ok {
+			if _, ok := message(ptr.Elem()); ok {
+				return "ProtoReflect", true
+			}
+		}
+	}
+	for _, prefix := range runtimePackages {
+		if strings.HasPrefix(fn.Pkg().Path()+"/", prefix) {
+			if fn.Signature().Recv() != nil {
+				return fn.Name(), true
+			}
+			return fn.Pkg().Name() + "." + fn.Name(), true
+		}
+	}
+	return "", false
+}
+
+// copies reports whether evaluating e as a value copies an existing
+// message: composite literals and call results are fresh values
+func copies(e ast.Expr) bool {
+	switch ast.Unparen(e).(type) {
+	case *ast.CompositeLit, *ast.CallExpr:
+		return false
+	}
+	return true
+}
+
+// checkAssign reports assignments that copy a message
+func checkAssign(pass *analysis.Pass, assign *ast.AssignStmt) {
+	if len(assign.Lhs) != len(assign.Rhs) {
+		return
+	}
+	for i, rhs := range assign.Rhs {
+		if isBlank(assign.Lhs[i]) {
+			continue
+		}
+		checkCopy(pass, rhs, assign.Tok == token.DEFINE)
+	}
+}
+
+// checkValueSpec reports variable declarations that copy a message
+func checkValueSpec(pass *analysis.Pass, spec *ast.ValueSpec) {
+	if len(spec.Names) != len(spec.Values) {
+		return
+	}
+	for i, value := range spec.Values {
+		if spec.Names[i].Name == "_" {
+			continue
+		}
+		checkCopy(pass, value, spec.Type == nil)
+	}
+}
+
+// checkCopy reports e if it copies a message. Where the variable it
+// initialises takes its type from e, a dereference is replaced by the
+// pointer, and a slice element by its address.
+func checkCopy(pass *analysis.Pass, e ast.Expr, inferred bool) {
+	named, ok := message(pass.TypesInfo.TypeOf(e))
+	if !ok || !copies(e) {
+		return
+	}
+	diagnostic := analysis.Diagnostic{
+		Pos:     e.Pos(),
+		End:     e.End(),
+		Message: fmt.Sprintf("assignment copies message %s by value; use a *%s, or proto.Clone for an independent copy", qualified(pass, named), qualified(pass, named)),
+	}
+	if inferred {
+		switch x := ast.Unparen(e).(type) {
+		case *ast.StarExpr:
+			diagnostic.SuggestedFixes = []analysis.SuggestedFix{{
+				Message:   "Use the pointer",
+				TextEdits: []analysis.TextEdit{{Pos: e.Pos(), End: x.X.Pos()}},
+			}}
+		case *ast.IndexExpr:
+			if _, ok := pass.TypesInfo.TypeOf(x.X).Underlying().(*types.Slice); ok {
+				diagnostic.SuggestedFixes = []analysis.SuggestedFix{{
+					Message:   "Take the address of the element",
+					TextEdits: []analysis.TextEdit{{Pos: e.Pos(), End: e.Pos(), NewText: []byte("&")}},
+				}}
+			}
+		}
+	}
+	pass.Report(diagnostic)
+}
+
+// checkRange reports range loops whose value variable copies each message.
+// Over a slice or array with no index variable, the fix indexes the
+// elements instead.
+func checkRange(pass *analysis.Pass, loop *ast.RangeStmt) {
+	if loop.Value == nil || isBlank(loop.Value) {
+		return
+	}
+	named, ok := message(pass.TypesInfo.TypeOf(loop.Value))
+	if !ok {
+		return
+	}
+	diagnostic := analysis.Diagnostic{
+		Pos:     loop.Value.Pos(),
+		End:     loop.Value.End(),
+		Message: fmt.Sprintf("range copies each message %s by value; index the elements, or range over []*%s", qualified(pass, named), qualified(pass, named)),
+	}
+	if fix, ok := rangeFix(pass, loop); ok {
+		diagnostic.SuggestedFixes = []analysis.SuggestedFix{fix}
+	}
+	pass.Report(diagnostic)
+}
+
+// rangeFix rewrites "for _, v := range xs {" over a slice as
+// "for i := range xs {\n\tv := &xs[i]"
+func rangeFix(pass *analysis.Pass, loop *ast.RangeStmt) (analysis.SuggestedFix, bool) {
+	value, ok := loop.Value.(*ast.Ident)
+	if !ok || loop.Tok != token.DEFINE || loop.Key == nil || !isBlank(loop.Key) || !sideEffectFree(loop.X) {
+		return analysis.SuggestedFix{}, false
+	}
+	if _, ok := pass.TypesInfo.TypeOf(loop.X).Underlying().(*types.Slice); !ok {
+		return analysis.SuggestedFix{}, false
+	}
+
+	scope := pass.TypesInfo.Scopes[loop]
+	index := ""
+	for _, name := range []string{"i", "j", "k", "idx"} {
+		if scope == nil {
+			break
+		}
+		if _, obj := scope.LookupParent(name, loop.Body.Lbrace); obj == nil && !usesName(loop.Body, name) {
+			index = name
+			break
+		}
+	}
+	if index == "" {
+		return analysis.SuggestedFix{}, false
+	}
+
+	var x bytes.Buffer
+	if err := format.Node(&x, pass.Fset, loop.X); err != nil {
+		return analysis.SuggestedFix{}, false
+	}
+	return analysis.SuggestedFix{
+		Message: "Index the elements",
+		TextEdits: []analysis.TextEdit{
+			{Pos: loop.Key.Pos(), End: value.End(), NewText: []byte(index)},
+			{Pos: loop.Body.Lbrace + 1, End: loop.Body.Lbrace + 1, NewText: fmt.Appendf(nil, "\n%s := &%s[%s]", value.Name, x.String(), index)},
+		},
+	}, true
+}
+
+// checkSignature reports parameters and results of message type
+func checkSignature(pass *analysis.Pass, fn *ast.FuncType) {
+	for _, list := range []*ast.FieldList{fn.Params, fn.Results} {
+		if list == nil {
+			continue
+		}
+		for _, field := range list.List {
+			named, ok := message(pass.TypesInfo.TypeOf(field.Type))
+			if !ok {
+				continue
+			}
+			what := "parameter"
+			if list == fn.Results {
+				what = "result"
+			}
+			// No fix is offered: it would break every caller
+			pass.ReportRangef(field.Type, "%s passes message %s by value, copying it; use *%s", what, qualified(pass, named), qualified(pass, named))
+		}
+	}
+}
+
+// qualified names a message type the way the package being analysed does
+func qualified(pass *analysis.Pass, named *types.Named) string {
+	return types.TypeString(named, func(pkg *types.Package) string {
+		if pkg == pass.Pkg {
+			return ""
+		}
+		return pkg.Name()
+	})
+}
+
+func isBlank(e ast.Expr) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && id.Name == "_"
+}
+
+// sideEffectFree reports whether e is a variable or a chain of field
+// selections on one, which can be evaluated again without effects
+func sideEffectFree(e ast.Expr) bool {
+	switch e := ast.Unparen(e).(type) {
+	case *ast.Ident:
+		return true
+	case *ast.SelectorExpr:
+		return sideEffectFree(e.X)
+	}
+	return false
+}
+
+// usesName reports whether an identifier called name occurs in n
+func usesName(n ast.Node, name string) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Name == name {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
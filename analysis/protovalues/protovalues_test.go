@@ -0,0 +1,20 @@
+package protovalues_test
+
+import (
+	"testing"
+
+	"github.com/benjamin-rood/protogo-values/analysis/protovalues"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), protovalues.Analyzer, "a")
+}
+
+func TestAnalyzerGenerated(t *testing.T) {
+	if err := protovalues.Analyzer.Flags.Set("generated", "true"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { protovalues.Analyzer.Flags.Set("generated", "false") })
+	analysistest.Run(t, analysistest.TestData(), protovalues.Analyzer, "gen")
+}
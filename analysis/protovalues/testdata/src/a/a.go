@@ -0,0 +1,57 @@
+package a
+
+import (
+	"example.com/pb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func marshal(list *pb.ValueList, env *pb.Envelope, ok *pb.UserList) {
+	proto.Marshal(list) // want `list passed to proto.Marshal holds a value slice of message pb.User in ValueList.Users, which the protobuf runtime panics on; keep that field a \[\]\*pb.User`
+	proto.Marshal(env)  // want `env passed to proto.Marshal holds a value slice of message pb.User in Envelope.List.Users`
+	proto.Marshal(ok)
+	list.ProtoReflect() // want `list passed to ProtoReflect holds a value slice`
+	ok.ProtoReflect()
+}
+
+func slices(list *pb.UserList) {
+	// Value slices and maps are what value_slice and value_map generate:
+	// only handing them to the runtime is reported
+	users := []pb.User{}
+	_ = users
+	byID := map[string]pb.User{}
+	_ = byID
+	values := list.Values()
+	for _, u := range values { // want `range copies each message pb.User by value; index the elements, or range over \[\]\*pb.User`
+		println(u.Name)
+	}
+	for i, u := range values { // want `range copies each message pb.User by value`
+		println(i, u.Name)
+	}
+	for i := range values {
+		println(values[i].Name)
+	}
+	v := values[0] // want `assignment copies message pb.User by value`
+	_ = v
+	protoreflect.ValueOf(values) // want `value slice of message pb.User passed to protoreflect.ValueOf: the protobuf runtime panics on value slices of messages; use \[\]\*pb.User`
+}
+
+func copies(u *pb.User, values []*pb.User) {
+	c := *u // want `assignment copies message pb.User by value; use a \*pb.User, or proto.Clone for an independent copy`
+	_ = c
+	var d pb.User = *u // want `assignment copies message pb.User by value`
+	d = *values[0]     // want `assignment copies message pb.User by value`
+	use(d)             // want `call passes message pb.User by value, copying it; pass a \*pb.User instead`
+	use(pb.User{Name: "fresh"})
+	fresh := pb.User{}
+	_ = fresh
+	p := proto.Clone(u).(*pb.User)
+	_ = p
+	_ = *u
+}
+
+func use(u pb.User) {} // want `parameter passes message pb.User by value, copying it; use \*pb.User`
+
+func first(list *pb.UserList) pb.User { // want `result passes message pb.User by value`
+	return pb.User{}
+}
@@ -0,0 +1,10 @@
+// Package protoreflect is a stub of the protobuf reflection API.
+package protoreflect
+
+type ProtoMessage interface{ ProtoReflect() Message }
+
+type Message interface{ Interface() ProtoMessage }
+
+type Value struct{ v any }
+
+func ValueOf(v any) Value { return Value{v} }
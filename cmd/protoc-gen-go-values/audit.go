@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/benjamin-rood/protogo-values/internal/audit"
+	"github.com/benjamin-rood/protogo-values/internal/migrate"
+)
+
+// runAudit implements the audit subcommand, which lists the repeated message
+// fields of a descriptor set and flags value-slice candidates
+func runAudit(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("audit", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	maxBytes := flags.Int("max-bytes", audit.DefaultOptions.MaxBytes, "largest estimated element size, in bytes, to flag as a candidate")
+	maxDepth := flags.Int("max-depth", audit.DefaultOptions.MaxDepth, "deepest nesting of message fields to flag as a candidate; 0 flags only flat elements")
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: %s audit [-max-bytes n] [-max-depth n] descriptors.binpb [file.proto ...]\n\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(stderr, "The descriptor set is written by protoc --include_imports --descriptor_set_out or buf build -o.\nWithout proto files, every file in the set is audited except the well-known types.\n\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("a descriptor set is required")
+	}
+
+	set, err := migrate.ReadDescriptorSet(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	opts := audit.Options{MaxBytes: *maxBytes, MaxDepth: *maxDepth}
+	findings, err := audit.Audit(set, opts, flags.Args()[1:]...)
+	if err != nil {
+		return err
+	}
+	return audit.WriteReport(stdout, findings, opts)
+}
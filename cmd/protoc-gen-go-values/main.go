@@ -2,16 +2,49 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 
+	"github.com/benjamin-rood/protogo-values/internal/diag"
 	"github.com/benjamin-rood/protogo-values/internal/plugin"
+	"github.com/benjamin-rood/protogo-values/internal/version"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/pluginpb"
 )
 
+// subcommands are the commands run from the command line rather than by
+// protoc
+var subcommands = map[string]func(args []string, stdout, stderr io.Writer) error{
+	"audit":   runAudit,
+	"migrate": runMigrate,
+}
+
 func main() {
+	// protoc runs the plugin without arguments; a first argument selects a
+	// subcommand
+	if len(os.Args) > 1 {
+		if subcommand, ok := subcommands[os.Args[1]]; ok {
+			if err := subcommand(os.Args[2:], os.Stdout, os.Stderr); err != nil {
+				if !errors.Is(err, flag.ErrHelp) {
+					fmt.Fprintf(os.Stderr, "%s %s: %v\n", filepath.Base(os.Args[0]), os.Args[1], err)
+				}
+				os.Exit(2)
+			}
+			return
+		}
+	}
+
+	showVersion := flag.Bool("version", false, "print the version of protoc-gen-go-values and of its protobuf runtime, then exit")
+	flag.Parse()
+	if *showVersion {
+		fmt.Printf("%s %s (protobuf runtime %s)\n", filepath.Base(os.Args[0]), version.String(), version.Protobuf())
+		return
+	}
+
 	if err := run(); err != nil {
 		resp := &pluginpb.CodeGeneratorResponse{
 			Error: proto.String(err.Error()),
@@ -37,6 +70,12 @@ func run() error {
 	// Process the request using our plugin
 	resp, err := plugin.ProcessRequest(&req)
 	if err != nil {
+		// Diagnostics are reported as they are, so protoc and editors can
+		// parse their positions
+		var d *diag.Diagnostic
+		if errors.As(err, &d) {
+			return err
+		}
 		return fmt.Errorf("failed to process request: %w", err)
 	}
 
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/benjamin-rood/protogo-values/internal/migrate"
+	"github.com/benjamin-rood/protogo-values/internal/parser"
+)
+
+// runMigrate implements the migrate subcommand, which rewrites Go call sites
+// of fields whose value_slice option changed between two descriptor sets
+func runMigrate(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	before := flags.String("before", "", "descriptor set of the proto files before the change")
+	after := flags.String("after", "", "descriptor set of the proto files after the change")
+	dir := flags.String("C", ".", "directory to load the Go packages from")
+	dryRun := flags.Bool("n", false, "print the edits without writing any files")
+	autoMaxBytes := flags.Int("auto-max-bytes", parser.DefaultAutoMaxBytes, "the plugin's auto_max_bytes parameter, for fields under value_slice_policy AUTO")
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: %s migrate -before old.binpb -after new.binpb [-C dir] [-n] [-auto-max-bytes n] [packages]\n\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(stderr, "Descriptor sets are written by protoc --include_imports --descriptor_set_out or buf build -o.\nPackages default to ./...\n\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *before == "" || *after == "" {
+		flags.Usage()
+		return errors.New("both -before and -after are required")
+	}
+	patterns := flags.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+
+	oldSet, err := migrate.ReadDescriptorSet(*before)
+	if err != nil {
+		return err
+	}
+	newSet, err := migrate.ReadDescriptorSet(*after)
+	if err != nil {
+		return err
+	}
+	toggles, err := migrate.Toggles(oldSet, newSet, *autoMaxBytes)
+	if err != nil {
+		return err
+	}
+	if len(toggles) == 0 {
+		fmt.Fprintln(stdout, "no value_slice options changed")
+		return nil
+	}
+
+	root, err := filepath.Abs(*dir)
+	if err != nil {
+		return err
+	}
+	result, err := migrate.Migrate(root, patterns, toggles)
+	if err != nil {
+		return err
+	}
+	if !*dryRun {
+		for name, content := range result.Files {
+			if err := os.WriteFile(name, content, 0o644); err != nil {
+				return fmt.Errorf("failed to write %s: %w", name, err)
+			}
+		}
+	}
+	result.WriteSummary(stdout, root)
+	return nil
+}
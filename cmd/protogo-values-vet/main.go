@@ -0,0 +1,18 @@
+// protogo-values-vet - Reports protobuf messages copied by value or held in value slices that reach the protobuf runtime
+//
+// Usage:
+//
+//	protogo-values-vet [-fix] ./...
+//
+// It runs the protovalues analyzer on its own. To combine it with other
+// analyzers, add protovalues.Analyzer to a multichecker.
+package main
+
+import (
+	"github.com/benjamin-rood/protogo-values/analysis/protovalues"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(protovalues.Analyzer)
+}
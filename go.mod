@@ -2,4 +2,11 @@ module github.com/benjamin-rood/protogo-values
 
 go 1.24.5
 
-require google.golang.org/protobuf v1.36.8
+require (
+	github.com/bufbuild/protocompile v0.14.1
+	golang.org/x/mod v0.27.0
+	golang.org/x/tools v0.36.0
+	google.golang.org/protobuf v1.36.8
+)
+
+require golang.org/x/sync v0.16.0 // indirect
@@ -0,0 +1,170 @@
+// Package audit lists the repeated message fields of a descriptor set with
+// an estimate of their element size, so that value_slice can be applied
+// where the numbers support it.
+//
+// A value slice stores its elements inline, which saves an allocation and a
+// pointer per element and keeps them contiguous, but every element is copied
+// whole when the slice grows or is ranged over by value. Small, flat
+// elements gain the most; large or deeply nested ones gain little, and
+// recursive ones cannot be stored inline at all.
+package audit
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/benjamin-rood/protogo-values/internal/parser"
+	"github.com/benjamin-rood/protogo-values/proto/protogo_values"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Options sets the limits for flagging a field as a value-slice candidate
+type Options struct {
+	// MaxBytes is the largest estimated element size to flag. Fields under
+	// value_slice_policy AUTO are decided as with auto_max_bytes=MaxBytes.
+	MaxBytes int
+	// MaxDepth is the deepest nesting of message fields to flag; 0 flags
+	// only flat elements
+	MaxDepth int
+}
+
+// DefaultOptions flags flat elements of up to 128 bytes, two 64-byte cache
+// lines
+var DefaultOptions = Options{MaxBytes: parser.DefaultAutoMaxBytes, MaxDepth: 0}
+
+// Finding is a repeated message field and what was estimated for it
+type Finding struct {
+	// File is the proto file declaring the field
+	File string
+	// Field is the field's full name, and Elem its element message's
+	Field protoreflect.FullName
+	Elem  protoreflect.FullName
+	// Size estimates the element's generated Go struct
+	Size parser.Size
+	// ValueSlice reports whether the field is generated as a value slice
+	ValueSlice bool
+	// Auto reports whether value_slice_policy AUTO decided ValueSlice
+	Auto bool
+	// Candidate reports whether the field is neither annotated nor left to
+	// the AUTO policy, and its element is within the limits
+	Candidate bool
+}
+
+// Audit returns a finding for every repeated message field of the named
+// files in set, sorted by field name. Map fields are not included. Without
+// names, every file is audited except the well-known types and the
+// protogo_values options. The set must include its imports.
+func Audit(set *descriptorpb.FileDescriptorSet, opts Options, names ...string) ([]Finding, error) {
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve descriptor set (were imports included?): %w", err)
+	}
+
+	var audited []protoreflect.FileDescriptor
+	if len(names) > 0 {
+		for _, name := range names {
+			file, err := files.FindFileByPath(name)
+			if err != nil {
+				return nil, fmt.Errorf("file %s is not in the descriptor set", name)
+			}
+			audited = append(audited, file)
+		}
+	} else {
+		files.RangeFiles(func(file protoreflect.FileDescriptor) bool {
+			switch file.Package() {
+			case "google.protobuf", protogo_values.File_proto_protogo_values_options_proto.Package():
+			default:
+				audited = append(audited, file)
+			}
+			return true
+		})
+	}
+
+	var findings []Finding
+	for _, file := range audited {
+		decisions, err := parser.AutoDecisions(protodesc.ToFileDescriptorProto(file), files, opts.MaxBytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Path(), err)
+		}
+		auto := make(map[protoreflect.FullName]bool, len(decisions))
+		for _, d := range decisions {
+			auto[d.Name] = d.ValueSlice
+		}
+		findings = appendFindings(findings, file, file.Messages(), auto, opts)
+	}
+	sort.Slice(findings, func(i, j int) bool { return findings[i].Field < findings[j].Field })
+	return findings, nil
+}
+
+// appendFindings appends the findings for messages and the messages nested
+// in them, given the AUTO decisions for the file. These are the messages
+// FindAnnotatedFields processes: all of them, nested ones included, but for
+// map entries, which have no struct of their own.
+func appendFindings(findings []Finding, file protoreflect.FileDescriptor, messages protoreflect.MessageDescriptors, auto map[protoreflect.FullName]bool, opts Options) []Finding {
+	for i := 0; i < messages.Len(); i++ {
+		md := messages.Get(i)
+		if md.IsMapEntry() {
+			continue
+		}
+		fields := md.Fields()
+		for j := 0; j < fields.Len(); j++ {
+			field := fields.Get(j)
+			if !field.IsList() || field.Message() == nil {
+				continue
+			}
+			size := parser.EstimateSize(field.Message())
+			valueSlice, isAuto := auto[field.FullName()]
+			if !isAuto {
+				valueSlice = parser.IsValueSlice(protodesc.ToFieldDescriptorProto(field))
+			}
+			findings = append(findings, Finding{
+				File:       file.Path(),
+				Field:      field.FullName(),
+				Elem:       field.Message().FullName(),
+				Size:       size,
+				ValueSlice: valueSlice,
+				Auto:       isAuto,
+				Candidate:  !valueSlice && !isAuto && !size.Recursive && size.Bytes <= opts.MaxBytes && size.Depth <= opts.MaxDepth,
+			})
+		}
+		findings = appendFindings(findings, file, md.Messages(), auto, opts)
+	}
+	return findings
+}
+
+// WriteReport writes findings as a table, followed by a count of value
+// slices and candidates
+func WriteReport(w io.Writer, findings []Finding, opts Options) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FIELD\tELEMENT\tBYTES\tDEPTH\tVALUE_SLICE\tCANDIDATE")
+	valueSlices, candidates := 0, 0
+	for _, f := range findings {
+		depth := fmt.Sprint(f.Size.Depth)
+		if f.Size.Recursive {
+			depth = "recursive"
+		}
+		annotated, candidate := "no", "no"
+		if f.ValueSlice {
+			annotated = "yes"
+			valueSlices++
+		}
+		if f.Auto {
+			annotated = "auto: " + annotated
+		}
+		if f.Candidate {
+			candidate = "yes"
+			candidates++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\n", f.Field, f.Elem, f.Size.Bytes, depth, annotated, candidate)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\nrepeated message fields: %d, value slices: %d, candidates: %d (at most %d bytes, depth %d)\n",
+		len(findings), valueSlices, candidates, opts.MaxBytes, opts.MaxDepth)
+	return err
+}
@@ -0,0 +1,230 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/benjamin-rood/protogo-values/internal/parser"
+	"github.com/benjamin-rood/protogo-values/proto/protogo_values"
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// shopSet compiles testdata/shop.proto into a descriptor set that includes
+// its imports, as protoc --include_imports writes it
+func shopSet(t *testing.T) *descriptorpb.FileDescriptorSet {
+	t.Helper()
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{ImportPaths: []string{"testdata", "../.."}}),
+	}
+	files, err := compiler.Compile(context.Background(), "shop.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	seen := make(map[string]bool)
+	var add func(file protoreflect.FileDescriptor)
+	add = func(file protoreflect.FileDescriptor) {
+		if seen[file.Path()] {
+			return
+		}
+		seen[file.Path()] = true
+		for i := 0; i < file.Imports().Len(); i++ {
+			add(file.Imports().Get(i).FileDescriptor)
+		}
+		set.File = append(set.File, protodesc.ToFileDescriptorProto(file))
+	}
+	add(files[0])
+
+	// Custom options compile to dynamic messages; pass the set through the
+	// wire format, as it is read from disk, so they decode as the generated
+	// types
+	data, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wire := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(data, wire); err != nil {
+		t.Fatal(err)
+	}
+	return wire
+}
+
+func TestAudit(t *testing.T) {
+	set := shopSet(t)
+	findings, err := Audit(set, DefaultOptions)
+	if err != nil {
+		t.Fatalf("Audit() failed: %v", err)
+	}
+
+	flat := func(bytes int) parser.Size { return parser.Size{Bytes: bytes} }
+	want := []Finding{
+		{Field: "shop.Category.children", Elem: "shop.Category", Size: parser.Size{Bytes: 80, Recursive: true}},
+		{Field: "shop.Shop.Shelf.slots", Elem: "shop.Point", Size: flat(56), Candidate: true},
+		{Field: "shop.Shop.addresses", Elem: "shop.Address", Size: flat(168)},
+		{Field: "shop.Shop.categories", Elem: "shop.Category", Size: parser.Size{Bytes: 80, Recursive: true}},
+		{Field: "shop.Shop.deliveries", Elem: "shop.Address", Size: flat(168), Auto: true},
+		{Field: "shop.Shop.orders", Elem: "shop.Order", Size: parser.Size{Bytes: 64, Depth: 1}},
+		{Field: "shop.Shop.route", Elem: "shop.Point", Size: flat(56), ValueSlice: true},
+		{Field: "shop.Shop.stops", Elem: "shop.Point", Size: flat(56), ValueSlice: true, Auto: true},
+		{Field: "shop.Shop.users", Elem: "shop.User", Size: flat(64), Candidate: true},
+	}
+	for i := range want {
+		want[i].File = "shop.proto"
+	}
+	if !reflect.DeepEqual(findings, want) {
+		t.Errorf("Audit() =\n%+v\nwant\n%+v", findings, want)
+	}
+
+	// Looser limits flag the nested and the larger elements too, and decide
+	// AUTO fields with the same limit
+	findings, err = Audit(set, Options{MaxBytes: 256, MaxDepth: 1}, "shop.proto")
+	if err != nil {
+		t.Fatalf("Audit() failed: %v", err)
+	}
+	var candidates []protoreflect.FullName
+	for _, f := range findings {
+		if f.Candidate {
+			candidates = append(candidates, f.Field)
+		}
+		if f.Field == "shop.Shop.deliveries" && !f.ValueSlice {
+			t.Error("deliveries is not a value slice under AUTO with a 256-byte limit")
+		}
+	}
+	wantCandidates := []protoreflect.FullName{"shop.Shop.Shelf.slots", "shop.Shop.addresses", "shop.Shop.orders", "shop.Shop.users"}
+	if !reflect.DeepEqual(candidates, wantCandidates) {
+		t.Errorf("candidates = %v, want %v", candidates, wantCandidates)
+	}
+
+	if _, err := Audit(set, DefaultOptions, "missing.proto"); err == nil {
+		t.Error("Audit() of a file missing from the set succeeded")
+	}
+	set.File = set.File[len(set.File)-1:]
+	if _, err := Audit(set, DefaultOptions); err == nil || !strings.Contains(err.Error(), "imports") {
+		t.Errorf("Audit() error = %v, want one mentioning imports", err)
+	}
+}
+
+// TestAuditMatchesParser checks that the audit covers the fields the plugin
+// rewrites: those it reports as value slices are annotated by
+// FindAnnotatedFields, and so is each candidate once value_slice is set on it
+func TestAuditMatchesParser(t *testing.T) {
+	findings, err := Audit(shopSet(t), DefaultOptions)
+	if err != nil {
+		t.Fatalf("Audit() failed: %v", err)
+	}
+
+	// annotated resolves the set as the plugin does, with value_slice set on
+	// the field named extra, if any, and reports whether the Go field
+	// generated for name is annotated
+	annotated := func(name, extra protoreflect.FullName) bool {
+		t.Helper()
+		req := &pluginpb.CodeGeneratorRequest{ProtoFile: shopSet(t).File}
+		if extra != "" {
+			field := findField(req.ProtoFile, extra)
+			if field == nil {
+				t.Fatalf("no field %s in the set", extra)
+			}
+			if field.Options == nil {
+				field.Options = &descriptorpb.FieldOptions{}
+			}
+			proto.SetExtension(field.Options, protogo_values.E_ValueSlice, true)
+		}
+		gen, err := protogen.Options{}.New(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var goName string
+		for _, file := range gen.Files {
+			for _, msg := range allMessages(file.Messages) {
+				for _, field := range msg.Fields {
+					if field.Desc.FullName() == name {
+						goName = field.GoName
+					}
+				}
+			}
+		}
+		if goName == "" {
+			t.Fatalf("no Go field generated for %s", name)
+		}
+		if _, err := parser.ApplyValueSlicePolicy(req, DefaultOptions.MaxBytes); err != nil {
+			t.Fatal(err)
+		}
+		fields, err := parser.FindAnnotatedFields(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fields.Contains(goName)
+	}
+
+	for _, f := range findings {
+		switch {
+		case f.ValueSlice && !annotated(f.Field, ""):
+			t.Errorf("%s is reported as a value slice but is not annotated", f.Field)
+		case f.Candidate && !annotated(f.Field, f.Field):
+			t.Errorf("%s is reported as a candidate but is not annotated once value_slice is set", f.Field)
+		}
+	}
+}
+
+// findField returns the field of files with the full name name
+func findField(files []*descriptorpb.FileDescriptorProto, name protoreflect.FullName) *descriptorpb.FieldDescriptorProto {
+	var find func(messages []*descriptorpb.DescriptorProto, prefix protoreflect.FullName) *descriptorpb.FieldDescriptorProto
+	find = func(messages []*descriptorpb.DescriptorProto, prefix protoreflect.FullName) *descriptorpb.FieldDescriptorProto {
+		for _, msg := range messages {
+			msgName := prefix.Append(protoreflect.Name(msg.GetName()))
+			for _, field := range msg.Field {
+				if msgName.Append(protoreflect.Name(field.GetName())) == name {
+					return field
+				}
+			}
+			if field := find(msg.NestedType, msgName); field != nil {
+				return field
+			}
+		}
+		return nil
+	}
+	for _, file := range files {
+		if field := find(file.MessageType, protoreflect.FullName(file.GetPackage())); field != nil {
+			return field
+		}
+	}
+	return nil
+}
+
+// allMessages returns messages and the messages nested in them
+func allMessages(messages []*protogen.Message) []*protogen.Message {
+	var all []*protogen.Message
+	for _, msg := range messages {
+		all = append(all, msg)
+		all = append(all, allMessages(msg.Messages)...)
+	}
+	return all
+}
+
+func TestWriteReport(t *testing.T) {
+	findings, err := Audit(shopSet(t), DefaultOptions)
+	if err != nil {
+		t.Fatalf("Audit() failed: %v", err)
+	}
+	var report bytes.Buffer
+	if err := WriteReport(&report, findings, DefaultOptions); err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("testdata/report.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.String() != string(want) {
+		t.Errorf("WriteReport() =\n%s\nwant\n%s", report.String(), want)
+	}
+}
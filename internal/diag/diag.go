@@ -0,0 +1,152 @@
+// Package diag reports problems found in proto sources in protoc's
+// file:line:col: message format, which protoc, buf and editors recognise.
+package diag
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Severity distinguishes errors, which fail generation, from warnings and
+// notes
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	// Note reports a decision the plugin made, such as an automatic option
+	Note
+)
+
+// Position is a location in a proto source file. Line and Column are 1-based
+// and zero when unknown, e.g. when the request carries no SourceCodeInfo.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+// String formats p as file:line:col, or just the file if the line is unknown
+func (p Position) String() string {
+	if p.Line == 0 {
+		return p.File
+	}
+	return p.File + ":" + strconv.Itoa(p.Line) + ":" + strconv.Itoa(p.Column)
+}
+
+// Diagnostic is an error or warning about a position in a proto source file
+type Diagnostic struct {
+	Position
+	Severity Severity
+	Err      error
+}
+
+// New returns an error diagnostic for err at pos
+func New(pos Position, err error) *Diagnostic {
+	return &Diagnostic{Position: pos, Severity: Error, Err: err}
+}
+
+// Warnf returns a warning diagnostic at pos
+func Warnf(pos Position, format string, args ...any) *Diagnostic {
+	return &Diagnostic{Position: pos, Severity: Warning, Err: fmt.Errorf(format, args...)}
+}
+
+// Notef returns a note diagnostic at pos
+func Notef(pos Position, format string, args ...any) *Diagnostic {
+	return &Diagnostic{Position: pos, Severity: Note, Err: fmt.Errorf(format, args...)}
+}
+
+// Error formats d as file:line:col: message, marking warnings as protoc does
+// and notes as compilers do
+func (d *Diagnostic) Error() string {
+	var b strings.Builder
+	b.WriteString(d.Position.String())
+	b.WriteString(": ")
+	switch d.Severity {
+	case Warning:
+		b.WriteString("warning: ")
+	case Note:
+		b.WriteString("note: ")
+	}
+	b.WriteString(d.Err.Error())
+	return b.String()
+}
+
+func (d *Diagnostic) Unwrap() error {
+	return d.Err
+}
+
+// Of returns the position of the declaration of desc
+func Of(desc protoreflect.Descriptor) Position {
+	file := desc.ParentFile()
+	if file == nil {
+		return Position{}
+	}
+	pos := Position{File: file.Path()}
+	if loc := file.SourceLocations().ByDescriptor(desc); loc.Path != nil {
+		pos.Line = loc.StartLine + 1
+		pos.Column = loc.StartColumn + 1
+	}
+	return pos
+}
+
+// Field numbers of descriptor.proto used in SourceCodeInfo paths
+const (
+	fileMessageTypeTag   = 4
+	messageFieldTag      = 2
+	messageNestedTypeTag = 3
+	messageOneofDeclTag  = 8
+)
+
+// SourcePositions maps the messages, fields and oneofs of file to the
+// positions of their declarations, taken from the file's SourceCodeInfo.
+// Descriptors without a recorded location map to the file alone.
+func SourcePositions(file *descriptorpb.FileDescriptorProto) map[proto.Message]Position {
+	spans := make(map[string][]int32)
+	for _, loc := range file.GetSourceCodeInfo().GetLocation() {
+		spans[pathKey(loc.GetPath())] = loc.GetSpan()
+	}
+
+	positions := make(map[proto.Message]Position)
+	add := func(desc proto.Message, path []int32) {
+		pos := Position{File: file.GetName()}
+		if span := spans[pathKey(path)]; len(span) >= 3 {
+			pos.Line = int(span[0]) + 1
+			pos.Column = int(span[1]) + 1
+		}
+		positions[desc] = pos
+	}
+
+	var walk func(msg *descriptorpb.DescriptorProto, path []int32)
+	walk = func(msg *descriptorpb.DescriptorProto, path []int32) {
+		add(msg, path)
+		for i, field := range msg.Field {
+			add(field, append(path[:len(path):len(path)], messageFieldTag, int32(i)))
+		}
+		for i, oneof := range msg.OneofDecl {
+			add(oneof, append(path[:len(path):len(path)], messageOneofDeclTag, int32(i)))
+		}
+		for i, nested := range msg.NestedType {
+			walk(nested, append(path[:len(path):len(path)], messageNestedTypeTag, int32(i)))
+		}
+	}
+	for i, msg := range file.MessageType {
+		walk(msg, []int32{fileMessageTypeTag, int32(i)})
+	}
+	return positions
+}
+
+// pathKey returns a map key for a SourceCodeInfo path
+func pathKey(path []int32) string {
+	var b strings.Builder
+	for _, p := range path {
+		b.WriteString(strconv.Itoa(int(p)))
+		b.WriteByte('.')
+	}
+	return b.String()
+}
@@ -0,0 +1,98 @@
+package diag
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func testFile() *descriptorpb.FileDescriptorProto {
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("shop/order.proto"),
+		Package: proto.String("shop"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Order"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:   proto.String("id"),
+				Number: proto.Int32(1),
+				Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:   descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+			}},
+			NestedType: []*descriptorpb.DescriptorProto{{Name: proto.String("Line")}},
+		}},
+		SourceCodeInfo: &descriptorpb.SourceCodeInfo{
+			Location: []*descriptorpb.SourceCodeInfo_Location{
+				{Path: []int32{4, 0}, Span: []int32{4, 0, 9, 1}},
+				{Path: []int32{4, 0, 2, 0}, Span: []int32{5, 2, 16}},
+				{Path: []int32{4, 0, 3, 0}, Span: []int32{7, 2, 20}},
+			},
+		},
+	}
+}
+
+func TestDiagnosticError(t *testing.T) {
+	cause := errors.New("bad option")
+	tests := []struct {
+		name string
+		diag *Diagnostic
+		want string
+	}{
+		{"error", New(Position{File: "a.proto", Line: 3, Column: 5}, cause), "a.proto:3:5: bad option"},
+		{"unknown line", New(Position{File: "a.proto"}, cause), "a.proto: bad option"},
+		{"warning", Warnf(Position{File: "a.proto", Line: 1, Column: 1}, "unused %s", "x"), "a.proto:1:1: warning: unused x"},
+		{"note", Notef(Position{File: "a.proto", Line: 2, Column: 3}, "chose %s", "y"), "a.proto:2:3: note: chose y"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.diag.Error(); got != tt.want {
+				t.Errorf("Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if !errors.Is(New(Position{}, cause), cause) {
+		t.Error("diagnostic does not unwrap to its cause")
+	}
+}
+
+func TestSourcePositions(t *testing.T) {
+	file := testFile()
+	positions := SourcePositions(file)
+
+	order := file.MessageType[0]
+	tests := []struct {
+		name string
+		desc proto.Message
+		want Position
+	}{
+		{"message", order, Position{File: "shop/order.proto", Line: 5, Column: 1}},
+		{"field", order.Field[0], Position{File: "shop/order.proto", Line: 6, Column: 3}},
+		{"nested message", order.NestedType[0], Position{File: "shop/order.proto", Line: 8, Column: 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := positions[tt.desc]; got != tt.want {
+				t.Errorf("position = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	file.SourceCodeInfo = nil
+	if got, want := SourcePositions(file)[order], (Position{File: "shop/order.proto"}); got != want {
+		t.Errorf("position without SourceCodeInfo = %+v, want %+v", got, want)
+	}
+}
+
+func TestOf(t *testing.T) {
+	fd, err := protodesc.NewFile(testFile(), nil)
+	if err != nil {
+		t.Fatalf("protodesc.NewFile() failed: %v", err)
+	}
+	field := fd.Messages().ByName("Order").Fields().ByName("id")
+	if got, want := Of(field), (Position{File: "shop/order.proto", Line: 6, Column: 3}); got != want {
+		t.Errorf("Of() = %+v, want %+v", got, want)
+	}
+}
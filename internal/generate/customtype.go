@@ -0,0 +1,196 @@
+package generate
+
+import (
+	"fmt"
+	"go/types"
+	"sort"
+
+	"github.com/benjamin-rood/protogo-values/internal/parser"
+	"golang.org/x/tools/go/packages"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	fromProtoSuffix = "FromProto"
+	toProtoSuffix   = "ToProto"
+)
+
+// customType describes a go_type override and the user-declared converters
+// between it and the field's proto Go type
+type customType struct {
+	goType    protogen.GoIdent
+	fromProto protogen.GoIdent
+	toProto   protogen.GoIdent
+}
+
+// customGoType returns the go_type override of field, if it has one
+func customGoType(field *protogen.Field) (customType, bool) {
+	opts := parser.StructuredOptions(fieldOptions(field))
+	if opts.GoType == nil || opts.GoConverter == nil {
+		return customType{}, false
+	}
+	typePath, typeName, err := parser.SplitGoName(opts.GetGoType())
+	if err != nil {
+		return customType{}, false
+	}
+	convPath, convName, err := parser.SplitGoName(opts.GetGoConverter())
+	if err != nil {
+		return customType{}, false
+	}
+	return customType{
+		goType:    protogen.GoImportPath(typePath).Ident(typeName),
+		fromProto: protogen.GoImportPath(convPath).Ident(convName + fromProtoSuffix),
+		toProto:   protogen.GoImportPath(convPath).Ident(convName + toProtoSuffix),
+	}, true
+}
+
+// customTypeFields returns the fields with a go_type override across all
+// files marked for generation
+func customTypeFields(gen *protogen.Plugin) []*protogen.Field {
+	var fields []*protogen.Field
+	for _, file := range gen.Files {
+		if !file.Generate {
+			continue
+		}
+		for _, msg := range allMessages(file.Messages) {
+			for _, field := range msg.Fields {
+				if _, ok := customGoType(field); ok {
+					fields = append(fields, field)
+				}
+			}
+		}
+	}
+	return fields
+}
+
+// checkConverters type-checks the custom types and converters referenced by
+// fields, loading their packages from the current working directory
+func checkConverters(fields []*protogen.Field) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	pathSet := make(map[string]bool)
+	for _, field := range fields {
+		if field.Desc.HasPresence() {
+			return locate(field.Desc, fmt.Errorf("field %s: go_type cannot be used on fields with explicit presence", field.Desc.FullName()))
+		}
+		ct, _ := customGoType(field)
+		pathSet[string(ct.goType.GoImportPath)] = true
+		pathSet[string(ct.fromProto.GoImportPath)] = true
+	}
+	paths := make([]string, 0, len(pathSet))
+	for path := range pathSet {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	// Type-check from source rather than compiler export data, so the plugin
+	// keeps working when built against a different Go release than the one
+	// on PATH
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedTypes | packages.NeedSyntax |
+			packages.NeedImports | packages.NeedDeps,
+	}
+	pkgs, err := packages.Load(cfg, paths...)
+	if err != nil {
+		return fmt.Errorf("failed to load go_type packages: %w", err)
+	}
+	loaded := make(map[string]*types.Package)
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			return fmt.Errorf("failed to load package %s: %v", pkg.PkgPath, pkg.Errors[0])
+		}
+		loaded[pkg.PkgPath] = pkg.Types
+	}
+
+	for _, field := range fields {
+		ct, _ := customGoType(field)
+		if err := checkCustomType(loaded, ct, protoGoType(field.Desc.Kind())); err != nil {
+			return locate(field.Desc, fmt.Errorf("field %s: %w", field.Desc.FullName(), err))
+		}
+	}
+	return nil
+}
+
+// checkCustomType verifies that the custom type exists and that both
+// converters exist with the expected signatures
+func checkCustomType(loaded map[string]*types.Package, ct customType, protoType types.Type) error {
+	obj, err := lookup(loaded, ct.goType)
+	if err != nil {
+		return err
+	}
+	typeName, ok := obj.(*types.TypeName)
+	if !ok {
+		return fmt.Errorf("%s is not a type", qualifiedName(ct.goType))
+	}
+	custom := typeName.Type()
+
+	if err := checkConverter(loaded, ct.fromProto, protoType, custom); err != nil {
+		return err
+	}
+	return checkConverter(loaded, ct.toProto, custom, protoType)
+}
+
+// checkConverter verifies that ident is a function of type func(param) result
+func checkConverter(loaded map[string]*types.Package, ident protogen.GoIdent, param, result types.Type) error {
+	obj, err := lookup(loaded, ident)
+	if err != nil {
+		return err
+	}
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return fmt.Errorf("%s is not a function", qualifiedName(ident))
+	}
+	sig := fn.Type().(*types.Signature)
+	if sig.Params().Len() != 1 || sig.Results().Len() != 1 || sig.Variadic() ||
+		!types.Identical(sig.Params().At(0).Type(), param) ||
+		!types.Identical(sig.Results().At(0).Type(), result) {
+		return fmt.Errorf("%s has type %s, expected func(%s) %s",
+			qualifiedName(ident), sig, param, result)
+	}
+	return nil
+}
+
+// lookup finds the package-level object named by ident
+func lookup(loaded map[string]*types.Package, ident protogen.GoIdent) (types.Object, error) {
+	pkg, ok := loaded[string(ident.GoImportPath)]
+	if !ok {
+		return nil, fmt.Errorf("package %s not found", ident.GoImportPath)
+	}
+	obj := pkg.Scope().Lookup(ident.GoName)
+	if obj == nil || !obj.Exported() {
+		return nil, fmt.Errorf("%s not found", qualifiedName(ident))
+	}
+	return obj, nil
+}
+
+// protoGoType returns the go/types type protoc-gen-go uses for a singular
+// scalar or bytes field of the given kind
+func protoGoType(kind protoreflect.Kind) types.Type {
+	switch kind {
+	case protoreflect.BoolKind:
+		return types.Typ[types.Bool]
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return types.Typ[types.Int32]
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return types.Typ[types.Uint32]
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return types.Typ[types.Int64]
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return types.Typ[types.Uint64]
+	case protoreflect.FloatKind:
+		return types.Typ[types.Float32]
+	case protoreflect.DoubleKind:
+		return types.Typ[types.Float64]
+	case protoreflect.StringKind:
+		return types.Typ[types.String]
+	}
+	return types.NewSlice(types.Universe.Lookup("byte").Type())
+}
+
+// qualifiedName formats ident as importpath.Name
+func qualifiedName(ident protogen.GoIdent) string {
+	return string(ident.GoImportPath) + "." + ident.GoName
+}
@@ -0,0 +1,120 @@
+package generate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/benjamin-rood/protogo-values/proto/protogo_values"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+const convertSource = `package convert
+
+type ID [16]byte
+
+func IDFromProto(v []byte) ID { var id ID; copy(id[:], v); return id }
+func IDToProto(v ID) []byte  { return v[:] }
+
+func BadFromProto(v string) ID { return ID{} }
+func BadToProto(v ID) []byte  { return v[:] }
+
+var NotAFuncFromProto = 1
+`
+
+// chdirConvertModule creates a module declaring example.com/convert and
+// makes it the working directory for go/packages
+func chdirConvertModule(t *testing.T) {
+	t.Helper()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":             "module example.com\n\ngo 1.24\n",
+		"convert/convert.go": convertSource,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+}
+
+func customTypeFile(converter string) *descriptorpb.FileDescriptorProto {
+	id := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String("id"),
+		JsonName: proto.String("id"),
+		Number:   proto.Int32(1),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     descriptorpb.FieldDescriptorProto_TYPE_BYTES.Enum(),
+		Options: fieldOpts(&protogo_values.FieldOptions{
+			GoType:      proto.String("example.com/convert.ID"),
+			GoConverter: proto.String(converter),
+		}),
+	}
+	return testFile(&descriptorpb.DescriptorProto{
+		Name:  proto.String("Account"),
+		Field: []*descriptorpb.FieldDescriptorProto{id, stringField("owner", 2)},
+	})
+}
+
+func TestGenerateCustomType(t *testing.T) {
+	chdirConvertModule(t)
+
+	content := runGenerator(t, customTypeFile("example.com/convert.ID"))["mirror"+FileSuffix]
+
+	expected := []string{
+		`convert "example.com/convert"`,
+		"Id    convert.ID",
+		"m.Id = convert.IDFromProto(x.Id)",
+		"x.Id = convert.IDToProto(m.Id)",
+		"x.Owner = m.Owner",
+	}
+	for _, want := range expected {
+		if !strings.Contains(content, want) {
+			t.Errorf("expected generated code to contain %q:\n%s", want, content)
+		}
+	}
+}
+
+func TestGenerateCustomTypeRejectsBadConverters(t *testing.T) {
+	chdirConvertModule(t)
+
+	tests := []struct {
+		name      string
+		converter string
+		wantErr   string
+	}{
+		{"signature mismatch", "example.com/convert.Bad", "expected func([]byte) example.com/convert.ID"},
+		{"missing converter", "example.com/convert.Missing", "example.com/convert.MissingFromProto not found"},
+		{"not a function", "example.com/convert.NotAFunc", "is not a function"},
+		{"missing package", "example.com/missing.ID", "example.com/missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := customTypeFile(tt.converter)
+			req := &pluginpb.CodeGeneratorRequest{
+				FileToGenerate: []string{file.GetName()},
+				ProtoFile:      []*descriptorpb.FileDescriptorProto{file},
+			}
+			gen, err := protogen.Options{}.New(req)
+			if err != nil {
+				t.Fatalf("protogen.Options.New() failed: %v", err)
+			}
+
+			err = GenerateFiles(gen)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("GenerateFiles() error = %v, expected error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
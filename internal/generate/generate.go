@@ -0,0 +1,154 @@
+package generate
+
+import (
+	"fmt"
+
+	"github.com/benjamin-rood/protogo-values/internal/parallel"
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// FileSuffix is appended to a proto file's generated filename prefix to name
+// the sibling file holding the code emitted by this package.
+const FileSuffix = "_values.pb.go"
+
+// GenerateFiles emits a sibling file for every proto file marked for
+// generation. Files with nothing to emit are skipped.
+func GenerateFiles(gen *protogen.Plugin) error {
+	if gen == nil {
+		return fmt.Errorf("plugin cannot be nil")
+	}
+
+	p, err := newPlan(gen)
+	if err != nil {
+		return err
+	}
+
+	// protogen.Plugin is not safe for concurrent use, so the output files are
+	// created up front; each is then filled in on its own goroutine, touching
+	// only its own state and the read-only plan
+	var files []*protogen.File
+	var outputs []*protogen.GeneratedFile
+	for _, file := range gen.Files {
+		if !file.Generate {
+			continue
+		}
+		files = append(files, file)
+		outputs = append(outputs, gen.NewGeneratedFile(file.GeneratedFilenamePrefix+FileSuffix, file.GoImportPath))
+	}
+	parallel.ForEach(len(files), func(i int) {
+		generateFile(outputs[i], files[i], p)
+	})
+	return nil
+}
+
+// plan holds the request-wide decisions about what to emit, made before any
+// file is written so that cross-file references stay consistent
+type plan struct {
+	mirrors         mirrorSet
+	validated       validatedSet
+	rules           fieldRules
+	codecs          map[*protogen.File][]sliceCodec
+	valueMapHelpers map[*protogen.File]bool
+	zero            zeroSet
+	oneofs          oneofSet
+}
+
+// newPlan checks the request and decides what to emit for it
+func newPlan(gen *protogen.Plugin) (*plan, error) {
+	taken := newPackageIdents(gen)
+	mirrors, err := planMirrors(gen, taken)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkConverters(customTypeFields(gen)); err != nil {
+		return nil, err
+	}
+	codecs, err := planSliceCodecs(gen, taken)
+	if err != nil {
+		return nil, err
+	}
+	valueMapHelpers, err := planValueMaps(gen, taken)
+	if err != nil {
+		return nil, err
+	}
+	zero, err := planIsZero(gen)
+	if err != nil {
+		return nil, err
+	}
+	oneofs, err := planValueOneofs(gen, taken)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := parseValidationRules(gen)
+	if err != nil {
+		return nil, err
+	}
+	validated, err := planValidation(gen, rules)
+	if err != nil {
+		return nil, err
+	}
+	return &plan{
+		mirrors:         mirrors,
+		validated:       validated,
+		rules:           rules,
+		codecs:          codecs,
+		valueMapHelpers: valueMapHelpers,
+		zero:            zero,
+		oneofs:          oneofs,
+	}, nil
+}
+
+// generateFile writes the sibling file for a single proto file to g
+func generateFile(g *protogen.GeneratedFile, file *protogen.File, p *plan) {
+	g.P("// Code generated by protoc-gen-go-values. DO NOT EDIT.")
+	g.P("// source: ", file.Desc.Path())
+	g.P()
+	g.P("package ", file.GoPackageName)
+	g.P()
+
+	emitted := false
+	for _, codec := range p.codecs[file] {
+		genSliceCodec(g, codec)
+		emitted = true
+	}
+	if p.valueMapHelpers[file] {
+		genValueMapHelpers(g)
+	}
+	for _, msg := range allMessages(file.Messages) {
+		if genValueMaps(g, msg) {
+			emitted = true
+		}
+		if genValueOneofs(g, msg, p.oneofs) {
+			emitted = true
+		}
+		if p.mirrors[msg.Desc.FullName()] {
+			genMirror(g, msg)
+			emitted = true
+		}
+		if p.validated[msg.Desc.FullName()] {
+			genValidate(g, msg, p.validated, p.rules)
+			emitted = true
+		}
+		if p.zero[msg.Desc.FullName()] {
+			genIsZero(g, msg)
+			emitted = true
+		}
+	}
+
+	if !emitted {
+		g.Skip()
+	}
+}
+
+// allMessages flattens nested messages depth-first, skipping map entries
+func allMessages(messages []*protogen.Message) []*protogen.Message {
+	var result []*protogen.Message
+	for _, msg := range messages {
+		if msg.Desc.IsMapEntry() {
+			continue
+		}
+		result = append(result, msg)
+		result = append(result, allMessages(msg.Messages)...)
+	}
+	return result
+}
@@ -0,0 +1,258 @@
+package generate
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/benjamin-rood/protogo-values/proto/protogo_values"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// runGenerator runs GenerateFiles over the given proto files, in dependency
+// order, and returns the generated files keyed by name
+func runGenerator(t *testing.T, files ...*descriptorpb.FileDescriptorProto) map[string]string {
+	t.Helper()
+
+	req := &pluginpb.CodeGeneratorRequest{
+		Parameter: proto.String("paths=source_relative"),
+		ProtoFile: files,
+	}
+	for _, file := range files {
+		req.FileToGenerate = append(req.FileToGenerate, file.GetName())
+	}
+	gen, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatalf("protogen.Options.New() failed: %v", err)
+	}
+	if err := GenerateFiles(gen); err != nil {
+		t.Fatalf("GenerateFiles() failed: %v", err)
+	}
+	resp := gen.Response()
+	if resp.Error != nil {
+		t.Fatalf("generator returned error: %s", resp.GetError())
+	}
+
+	generated := make(map[string]string)
+	for _, f := range resp.File {
+		if _, err := parser.ParseFile(token.NewFileSet(), f.GetName(), f.GetContent(), 0); err != nil {
+			t.Fatalf("generated file %s does not parse: %v\n%s", f.GetName(), err, f.GetContent())
+		}
+		generated[f.GetName()] = f.GetContent()
+	}
+	return generated
+}
+
+func fieldOpts(opts *protogo_values.FieldOptions) *descriptorpb.FieldOptions {
+	fieldOptions := &descriptorpb.FieldOptions{}
+	proto.SetExtension(fieldOptions, protogo_values.E_FieldOpts, opts)
+	return fieldOptions
+}
+
+func mapEntry(name string, valueType descriptorpb.FieldDescriptorProto_Type, valueTypeName string) *descriptorpb.DescriptorProto {
+	value := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String("value"),
+		JsonName: proto.String("value"),
+		Number:   proto.Int32(2),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     valueType.Enum(),
+	}
+	if valueTypeName != "" {
+		value.TypeName = proto.String(valueTypeName)
+	}
+	return &descriptorpb.DescriptorProto{
+		Name: proto.String(name),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			{
+				Name:     proto.String("key"),
+				JsonName: proto.String("key"),
+				Number:   proto.Int32(1),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+			},
+			value,
+		},
+		Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+	}
+}
+
+func mapsFile() *descriptorpb.FileDescriptorProto {
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("maps.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto3"),
+		Options: &descriptorpb.FileOptions{
+			GoPackage: proto.String("example.com/test;test"),
+		},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("User"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{
+						Name:     proto.String("id"),
+						JsonName: proto.String("id"),
+						Number:   proto.Int32(1),
+						Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+						Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+					},
+				},
+			},
+			{
+				Name: proto.String("Directory"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{
+						Name:     proto.String("users_by_id"),
+						JsonName: proto.String("usersById"),
+						Number:   proto.Int32(1),
+						Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+						Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+						TypeName: proto.String(".test.Directory.UsersByIdEntry"),
+						Options:  fieldOpts(&protogo_values.FieldOptions{ValueMap: proto.Bool(true)}),
+					},
+					{
+						Name:     proto.String("admins_by_id"),
+						JsonName: proto.String("adminsById"),
+						Number:   proto.Int32(2),
+						Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+						Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+						TypeName: proto.String(".test.Directory.AdminsByIdEntry"),
+					},
+				},
+				NestedType: []*descriptorpb.DescriptorProto{
+					mapEntry("UsersByIdEntry", descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".test.User"),
+					mapEntry("AdminsByIdEntry", descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".test.User"),
+				},
+			},
+		},
+	}
+}
+
+func TestGenerateValueMaps(t *testing.T) {
+	files := runGenerator(t, mapsFile())
+
+	content, ok := files["maps"+FileSuffix]
+	if !ok {
+		t.Fatalf("expected sibling file maps%s, got %v", FileSuffix, files)
+	}
+
+	expected := []string{
+		"// Code generated by protoc-gen-go-values. DO NOT EDIT.",
+		"package test",
+		"func (x *Directory) UsersByIdValues() map[string]User {",
+		"func (x *Directory) SetUsersByIdValues(m map[string]User) {",
+		"x.UsersById = make(map[string]*User, len(m))",
+	}
+	for _, want := range expected {
+		if !strings.Contains(content, want) {
+			t.Errorf("expected generated code to contain %q:\n%s", want, content)
+		}
+	}
+
+	if strings.Contains(content, "AdminsById") {
+		t.Errorf("map field without value_map should not get accessors:\n%s", content)
+	}
+}
+
+func TestGenerateValueMapConflict(t *testing.T) {
+	for _, name := range []string{"users_by_id_values", "set_users_by_id_values"} {
+		file := mapsFile()
+		directory := file.MessageType[1]
+		directory.Field = append(directory.Field, stringField(name, 3))
+
+		req := &pluginpb.CodeGeneratorRequest{
+			FileToGenerate: []string{file.GetName()},
+			ProtoFile:      []*descriptorpb.FileDescriptorProto{file},
+		}
+		gen, err := protogen.Options{}.New(req)
+		if err != nil {
+			t.Fatalf("protogen.Options.New() failed: %v", err)
+		}
+		err = GenerateFiles(gen)
+		if err == nil || !strings.Contains(err.Error(), "conflicts with field "+name) {
+			t.Errorf("with field %s: expected accessor conflict error, got %v", name, err)
+		}
+	}
+}
+
+func TestGenerateSkipsFilesWithoutOptions(t *testing.T) {
+	file := mapsFile()
+	file.MessageType[1].Field[0].Options = nil
+
+	files := runGenerator(t, file)
+	if len(files) != 0 {
+		t.Errorf("expected no sibling files, got %v", files)
+	}
+}
+
+func TestGenerateFilesNilPlugin(t *testing.T) {
+	if err := GenerateFiles(nil); err == nil {
+		t.Error("GenerateFiles(nil) expected error but got none")
+	}
+}
+
+// TestGenerateFilesDeterministic checks that filling in many files
+// concurrently yields identical responses on every run. Run it with -race
+// (make test-race) to also check that files share no mutable state.
+func TestGenerateFilesDeterministic(t *testing.T) {
+	newRequest := func() *pluginpb.CodeGeneratorRequest {
+		req := &pluginpb.CodeGeneratorRequest{Parameter: proto.String("paths=source_relative")}
+		for i := range 50 {
+			pkg := fmt.Sprintf("p%d", i)
+			file := testFile(
+				&descriptorpb.DescriptorProto{
+					Name:  proto.String("Item"),
+					Field: []*descriptorpb.FieldDescriptorProto{stringField("sku", 1)},
+				},
+				&descriptorpb.DescriptorProto{
+					Name: proto.String("Order"),
+					Field: []*descriptorpb.FieldDescriptorProto{
+						valueSliceField("items", 1, "."+pkg+".Item"),
+						messageField("first", 2, "."+pkg+".Item", nonNullable()),
+					},
+				},
+			)
+			file.Name = proto.String(pkg + ".proto")
+			file.Package = proto.String(pkg)
+			file.Options.GoPackage = proto.String("example.com/" + pkg)
+			file.MessageType[1].Field[0].Options = fieldOpts(&protogo_values.FieldOptions{
+				ValueSlice:     proto.Bool(true),
+				ValidationRule: proto.String("min_len=1"),
+			})
+			req.FileToGenerate = append(req.FileToGenerate, file.GetName())
+			req.ProtoFile = append(req.ProtoFile, file)
+		}
+		return req
+	}
+
+	var want []byte
+	for run := range 10 {
+		gen, err := protogen.Options{}.New(newRequest())
+		if err != nil {
+			t.Fatalf("protogen.Options.New() failed: %v", err)
+		}
+		if err := GenerateFiles(gen); err != nil {
+			t.Fatalf("GenerateFiles() failed: %v", err)
+		}
+		resp := gen.Response()
+		if resp.Error != nil {
+			t.Fatalf("generator returned error: %s", resp.GetError())
+		}
+		if len(resp.File) != 50 {
+			t.Fatalf("expected 50 files, got %d", len(resp.File))
+		}
+		got, err := proto.MarshalOptions{Deterministic: true}.Marshal(resp)
+		if err != nil {
+			t.Fatalf("failed to marshal response: %v", err)
+		}
+		if run == 0 {
+			want = got
+		} else if string(got) != string(want) {
+			t.Fatalf("run %d: output differs from run 0", run)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package generate
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// declaringFile returns the file declaring desc. Its Go import path and
+// package name are resolved by protogen from the file's go_package option,
+// overridden by any M<file>=<import path> mapping in the request parameter,
+// exactly as protoc-gen-go resolves them for the .pb.go files.
+func declaringFile(gen *protogen.Plugin, desc protoreflect.Descriptor) (*protogen.File, error) {
+	path := desc.ParentFile().Path()
+	file, ok := gen.FilesByPath[path]
+	if !ok {
+		return nil, fmt.Errorf("%s is declared in %s, which is not part of the request", desc.FullName(), path)
+	}
+	return file, nil
+}
+
+// declaringPackageName returns the Go package name of the file declaring msg
+func declaringPackageName(gen *protogen.Plugin, msg *protogen.Message) (protogen.GoPackageName, error) {
+	file, err := declaringFile(gen, msg.Desc)
+	if err != nil {
+		return "", err
+	}
+	return file.GoPackageName, nil
+}
@@ -0,0 +1,96 @@
+package generate
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// commonFile declares common.User, with a validation rule, in its own Go
+// package
+func commonFile() *descriptorpb.FileDescriptorProto {
+	id := stringField("id", 1)
+	id.Options = ruled(`pattern="^u[0-9]+$"`)
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("common/user.proto"),
+		Package: proto.String("common"),
+		Syntax:  proto.String("proto3"),
+		Options: &descriptorpb.FileOptions{
+			GoPackage: proto.String("example.com/common;common"),
+		},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name:  proto.String("User"),
+			Field: []*descriptorpb.FieldDescriptorProto{id},
+		}},
+	}
+}
+
+func TestGenerateForeignTypes(t *testing.T) {
+	file := testFile(
+		&descriptorpb.DescriptorProto{
+			Name:  proto.String("User"),
+			Field: []*descriptorpb.FieldDescriptorProto{stringField("name", 1)},
+		},
+		// Takes the name the codec of common.User would get
+		&descriptorpb.DescriptorProto{
+			Name:  proto.String("CommonUserSlice"),
+			Field: []*descriptorpb.FieldDescriptorProto{stringField("name", 1)},
+		},
+		&descriptorpb.DescriptorProto{
+			Name: proto.String("Team"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				valueSliceField("members", 1, ".common.User"),
+				valueSliceField("locals", 2, ".test.User"),
+				messageField("owner", 3, ".common.User", nil),
+			},
+		},
+	)
+	file.Dependency = []string{"common/user.proto"}
+
+	content := runGenerator(t, commonFile(), file)["mirror"+FileSuffix]
+
+	expected := []string{
+		`common "example.com/common"`,
+		"type CommonUserSlice1 []common.User",
+		"type UserSlice []User",
+		"func (x *Team) Validate() error {",
+		"for i := range x.Members {",
+		"if joined, ok := x.Members[i].Validate().(interface{ Unwrap() []error }); ok {",
+		`errs = append(errs, fmt.Errorf("%s%w", fmt.Sprintf("%smembers[%d].", prefix, i), err))`,
+		"if joined, ok := x.Owner.Validate().(interface{ Unwrap() []error }); ok {",
+	}
+	for _, want := range expected {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated content missing %q\n%s", want, content)
+		}
+	}
+	if strings.Contains(content, "x.Owner.validationErrors(") {
+		t.Errorf("unexported helper of a foreign type referenced\n%s", content)
+	}
+}
+
+func TestGenerateForeignMirrorMissing(t *testing.T) {
+	file := testFile(&descriptorpb.DescriptorProto{
+		Name:  proto.String("Team"),
+		Field: []*descriptorpb.FieldDescriptorProto{messageField("owner", 1, ".common.User", nonNullable())},
+	})
+	file.Dependency = []string{"common/user.proto"}
+
+	// common/user.proto is a dependency but is not generated
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{file.GetName()},
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{commonFile(), file},
+	}
+	gen, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatalf("protogen.Options.New() failed: %v", err)
+	}
+	err = GenerateFiles(gen)
+	if err == nil || !strings.Contains(err.Error(), "example.com/common.UserMirror, which does not exist") {
+		t.Errorf("expected missing mirror error, got %v", err)
+	}
+}
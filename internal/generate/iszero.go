@@ -0,0 +1,119 @@
+package generate
+
+import (
+	"fmt"
+
+	"github.com/benjamin-rood/protogo-values/internal/parser"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// zeroSet holds the messages that get an IsZero method
+type zeroSet map[protoreflect.FullName]bool
+
+// omitEmpty returns the omit_empty setting of field and whether it is set
+func omitEmpty(field *protogen.Field) (omit, ok bool) {
+	opts := parser.StructuredOptions(fieldOptions(field))
+	return opts.GetOmitEmpty(), opts.OmitEmpty != nil
+}
+
+// planIsZero returns the messages that get an IsZero method: those declaring
+// omit_empty on any field, and the types of singular message fields with
+// omit_empty = true, whose json tag uses omitzero. Targets declared in files
+// outside the request are left alone; omitzero then only omits nil.
+func planIsZero(gen *protogen.Plugin) (zeroSet, error) {
+	generated := make(map[protoreflect.FullName]bool)
+	var ordered []*protogen.Message
+	for _, file := range gen.Files {
+		if !file.Generate {
+			continue
+		}
+		for _, msg := range allMessages(file.Messages) {
+			generated[msg.Desc.FullName()] = true
+			ordered = append(ordered, msg)
+		}
+	}
+
+	zero := make(zeroSet)
+	for _, msg := range ordered {
+		for _, field := range msg.Fields {
+			omit, ok := omitEmpty(field)
+			if !ok {
+				continue
+			}
+			zero[msg.Desc.FullName()] = true
+			if omit && field.Message != nil && !field.Desc.IsList() && !field.Desc.IsMap() &&
+				generated[field.Message.Desc.FullName()] {
+				zero[field.Message.Desc.FullName()] = true
+			}
+		}
+	}
+
+	for _, msg := range ordered {
+		if !zero[msg.Desc.FullName()] {
+			continue
+		}
+		for _, field := range msg.Fields {
+			if field.GoName == "IsZero" {
+				return nil, locate(field.Desc, fmt.Errorf("cannot generate IsZero for %s: conflicts with field %s",
+					msg.Desc.FullName(), field.Desc.Name()))
+			}
+		}
+	}
+	return zero, nil
+}
+
+// genIsZero emits an IsZero method reporting whether no field of msg is
+// populated, matching proto3 presence: a set but empty submessage counts as
+// populated
+func genIsZero(g *protogen.GeneratedFile, msg *protogen.Message) {
+	var conds []string
+	seenOneofs := make(map[*protogen.Oneof]bool)
+	for _, field := range msg.Fields {
+		if field.Desc.IsWeak() {
+			continue
+		}
+		ref := "x." + field.GoName
+		switch {
+		case isOneofMember(field):
+			if !seenOneofs[field.Oneof] {
+				seenOneofs[field.Oneof] = true
+				conds = append(conds, "x."+field.Oneof.GoName+" == nil")
+			}
+		case field.Desc.IsList(), field.Desc.IsMap(), field.Desc.Kind() == protoreflect.BytesKind:
+			conds = append(conds, "len("+ref+") == 0")
+		case field.Desc.HasPresence():
+			conds = append(conds, ref+" == nil")
+		case field.Desc.Kind() == protoreflect.BoolKind:
+			conds = append(conds, "!"+ref)
+		case field.Desc.Kind() == protoreflect.StringKind:
+			conds = append(conds, ref+` == ""`)
+		default:
+			conds = append(conds, ref+" == 0")
+		}
+	}
+
+	g.P("// IsZero reports whether x is nil or has no populated fields; unknown")
+	g.P("// fields are ignored. encoding/json consults it for fields tagged omitzero.")
+	g.P("func (x *", msg.GoIdent.GoName, ") IsZero() bool {")
+	g.P("if x == nil {")
+	g.P("return true")
+	g.P("}")
+	if len(conds) == 0 {
+		g.P("return true")
+	}
+	for i, cond := range conds {
+		switch {
+		case len(conds) == 1:
+			g.P("return ", cond)
+		case i == 0:
+			g.P("return ", cond, " &&")
+		case i == len(conds)-1:
+			g.P(cond)
+		default:
+			g.P(cond, " &&")
+		}
+	}
+	g.P("}")
+	g.P()
+}
@@ -0,0 +1,65 @@
+package generate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/benjamin-rood/protogo-values/proto/protogo_values"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func omitEmptyOpts(omit bool) *descriptorpb.FieldOptions {
+	return fieldOpts(&protogo_values.FieldOptions{OmitEmpty: proto.Bool(omit)})
+}
+
+func TestGenerateIsZero(t *testing.T) {
+	tags := messageField("tags", 2, ".test.Tag", omitEmptyOpts(false))
+	tags.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	count := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String("count"),
+		JsonName: proto.String("count"),
+		Number:   proto.Int32(3),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(),
+	}
+
+	file := testFile(
+		&descriptorpb.DescriptorProto{
+			Name:  proto.String("Address"),
+			Field: []*descriptorpb.FieldDescriptorProto{stringField("street", 1)},
+		},
+		&descriptorpb.DescriptorProto{
+			Name:  proto.String("Tag"),
+			Field: []*descriptorpb.FieldDescriptorProto{stringField("name", 1)},
+		},
+		&descriptorpb.DescriptorProto{
+			Name: proto.String("Contact"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				messageField("address", 1, ".test.Address", omitEmptyOpts(true)),
+				tags,
+				count,
+			},
+		},
+	)
+
+	content := runGenerator(t, file)["mirror"+FileSuffix]
+
+	expected := []string{
+		"func (x *Contact) IsZero() bool {",
+		"return x.Address == nil &&",
+		"len(x.Tags) == 0 &&",
+		"x.Count == 0",
+		// Address is the target of an omit_empty message field
+		"func (x *Address) IsZero() bool {",
+		`return x.Street == ""`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated content missing %q\n%s", want, content)
+		}
+	}
+	if strings.Contains(content, "func (x *Tag) IsZero()") {
+		t.Errorf("Tag is only used in a repeated field and should not get IsZero\n%s", content)
+	}
+}
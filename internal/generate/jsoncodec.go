@@ -0,0 +1,148 @@
+package generate
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	bytesPackage     = protogen.GoImportPath("bytes")
+	jsonPackage      = protogen.GoImportPath("encoding/json")
+	protojsonPackage = protogen.GoImportPath("google.golang.org/protobuf/encoding/protojson")
+	structpbPackage  = protogen.GoImportPath("google.golang.org/protobuf/types/known/structpb")
+	syncPackage      = protogen.GoImportPath("sync")
+)
+
+// sliceCodec is a named value-slice type whose JSON methods match protojson
+type sliceCodec struct {
+	name  string
+	elem  *protogen.Message
+	field *protogen.Field // first field using the codec, for documentation
+}
+
+// planSliceCodecs assigns a codec type to every message used as the element
+// of a value slice. Each type is emitted once per Go package, in the first
+// generated file that declares such a field. Elements from other Go packages
+// get package-qualified codec names, deduplicated with a numeric suffix.
+func planSliceCodecs(gen *protogen.Plugin, taken packageIdents) (map[*protogen.File][]sliceCodec, error) {
+	type key struct {
+		pkg  protogen.GoImportPath
+		elem protoreflect.FullName
+	}
+
+	codecs := make(map[*protogen.File][]sliceCodec)
+	seen := make(map[key]bool)
+	for _, file := range gen.Files {
+		if !file.Generate {
+			continue
+		}
+		for _, msg := range allMessages(file.Messages) {
+			for _, field := range msg.Fields {
+				if !field.Desc.IsList() || field.Message == nil || !isValueSlice(field) {
+					continue
+				}
+				k := key{file.GoImportPath, field.Message.Desc.FullName()}
+				if seen[k] {
+					continue
+				}
+				seen[k] = true
+
+				name := field.Message.GoIdent.GoName + "Slice"
+				what := "the JSON codec of " + string(field.Message.Desc.FullName()) + " value slices"
+				if field.Message.GoIdent.GoImportPath == file.GoImportPath {
+					if err := taken.claim(file, field.Desc, name, what); err != nil {
+						return nil, err
+					}
+				} else {
+					// Foreign elements are named after their package, e.g.
+					// AnypbAnySlice, so they cannot clash with local ones
+					pkg, err := declaringPackageName(gen, field.Message)
+					if err != nil {
+						return nil, locate(field.Desc, fmt.Errorf("field %s: %w", field.Desc.FullName(), err))
+					}
+					name = taken.claimUnique(file, upperFirst(string(pkg))+name, what)
+				}
+				codecs[file] = append(codecs[file], sliceCodec{name: name, elem: field.Message, field: field})
+			}
+		}
+	}
+	return codecs, nil
+}
+
+// genSliceCodec emits a named value-slice type with MarshalJSON and
+// UnmarshalJSON methods. Elements are encoded by protojson itself, so int64
+// values, enums and well-known types come out exactly as they do when
+// protojson encodes the equivalent repeated field.
+func genSliceCodec(g *protogen.GeneratedFile, codec sliceCodec) {
+	elem := g.QualifiedGoIdent(codec.elem.GoIdent)
+	separator := lowerFirst(codec.name) + "Separator"
+
+	g.P("// ", codec.name, " is a value slice whose JSON encoding matches protojson's")
+	g.P("// encoding of a repeated ", codec.elem.Desc.Name(), " field, e.g. ", codec.name, "(x.", codec.field.GoName, ").")
+	g.P("type ", codec.name, " []", elem)
+	g.P()
+
+	g.P("// ", separator, " is the separator protojson writes between list elements,")
+	g.P("// which it deliberately varies between builds.")
+	g.P("var ", separator, " = ", syncPackage.Ident("OnceValue"), "(func() []byte {")
+	g.P("null := ", structpbPackage.Ident("NewNullValue"), "()")
+	g.P("b, _ := ", protojsonPackage.Ident("Marshal"), "(&", structpbPackage.Ident("ListValue"), "{Values: []*", structpbPackage.Ident("Value"), "{null, null}})")
+	g.P("return ", bytesPackage.Ident("TrimSuffix"), "(", bytesPackage.Ident("TrimPrefix"), "(b, []byte(\"[null\")), []byte(\"null]\"))")
+	g.P("})")
+	g.P()
+
+	g.P("// MarshalJSON implements json.Marshaler. Note that encoding/json compacts")
+	g.P("// the result, so call it directly where byte-for-byte output matters.")
+	g.P("func (s ", codec.name, ") MarshalJSON() ([]byte, error) {")
+	g.P("b := []byte{'['}")
+	g.P("for i := range s {")
+	g.P("if i > 0 {")
+	g.P("b = append(b, ", separator, "()...)")
+	g.P("}")
+	g.P("elem, err := ", protojsonPackage.Ident("Marshal"), "(&s[i])")
+	g.P("if err != nil {")
+	g.P("return nil, err")
+	g.P("}")
+	g.P("b = append(b, elem...)")
+	g.P("}")
+	g.P("return append(b, ']'), nil")
+	g.P("}")
+	g.P()
+
+	g.P("// UnmarshalJSON implements json.Unmarshaler. A JSON null yields a nil slice.")
+	g.P("func (s *", codec.name, ") UnmarshalJSON(b []byte) error {")
+	g.P("var elems []", jsonPackage.Ident("RawMessage"))
+	g.P("if err := ", jsonPackage.Ident("Unmarshal"), "(b, &elems); err != nil {")
+	g.P("return err")
+	g.P("}")
+	g.P("if elems == nil {")
+	g.P("*s = nil")
+	g.P("return nil")
+	g.P("}")
+	g.P("out := make(", codec.name, ", len(elems))")
+	g.P("for i, elem := range elems {")
+	g.P("if err := ", protojsonPackage.Ident("Unmarshal"), "(elem, &out[i]); err != nil {")
+	g.P("return ", fmtPackage.Ident("Errorf"), "(\"element %d: %w\", i, err)")
+	g.P("}")
+	g.P("}")
+	g.P("*s = out")
+	g.P("return nil")
+	g.P("}")
+	g.P()
+}
+
+// lowerFirst returns s with its first rune lowercased
+func lowerFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[size:]
+}
+
+// upperFirst returns s with its first rune upper-cased
+func upperFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
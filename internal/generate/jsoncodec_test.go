@@ -0,0 +1,104 @@
+package generate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/benjamin-rood/protogo-values/proto/protogo_values"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func valueSliceField(name string, number int32, typeName string) *descriptorpb.FieldDescriptorProto {
+	field := messageField(name, number, typeName, fieldOpts(&protogo_values.FieldOptions{ValueSlice: proto.Bool(true)}))
+	field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	return field
+}
+
+func TestGenerateSliceCodec(t *testing.T) {
+	file := testFile(
+		&descriptorpb.DescriptorProto{
+			Name:  proto.String("User"),
+			Field: []*descriptorpb.FieldDescriptorProto{stringField("id", 1)},
+		},
+		&descriptorpb.DescriptorProto{
+			Name: proto.String("Team"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				valueSliceField("members", 1, ".test.User"),
+				valueSliceField("admins", 2, ".test.User"),
+			},
+		},
+	)
+
+	content := runGenerator(t, file)["mirror"+FileSuffix]
+
+	expected := []string{
+		"type UserSlice []User",
+		"func (s UserSlice) MarshalJSON() ([]byte, error) {",
+		"elem, err := protojson.Marshal(&s[i])",
+		"b = append(b, userSliceSeparator()...)",
+		"func (s *UserSlice) UnmarshalJSON(b []byte) error {",
+		"if err := protojson.Unmarshal(elem, &out[i]); err != nil {",
+	}
+	for _, want := range expected {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated content missing %q\n%s", want, content)
+		}
+	}
+	if n := strings.Count(content, "type UserSlice "); n != 1 {
+		t.Errorf("expected UserSlice to be declared once, got %d", n)
+	}
+}
+
+func TestGenerateSliceCodecNameConflict(t *testing.T) {
+	file := testFile(
+		&descriptorpb.DescriptorProto{
+			Name:  proto.String("User"),
+			Field: []*descriptorpb.FieldDescriptorProto{stringField("id", 1)},
+		},
+		&descriptorpb.DescriptorProto{
+			Name:  proto.String("UserSlice"),
+			Field: []*descriptorpb.FieldDescriptorProto{valueSliceField("users", 1, ".test.User")},
+		},
+	)
+
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{file.GetName()},
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{file},
+	}
+	gen, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatalf("protogen.Options.New() failed: %v", err)
+	}
+	err = GenerateFiles(gen)
+	if err == nil || !strings.Contains(err.Error(), "conflicts with test.UserSlice") {
+		t.Errorf("expected name conflict error, got %v", err)
+	}
+}
+
+func TestGenerateSliceCodecGroup(t *testing.T) {
+	group := valueSliceField("result", 1, ".test.SearchResponse.Result")
+	group.Type = descriptorpb.FieldDescriptorProto_TYPE_GROUP.Enum()
+
+	file := testFile(&descriptorpb.DescriptorProto{
+		Name:  proto.String("SearchResponse"),
+		Field: []*descriptorpb.FieldDescriptorProto{group},
+		NestedType: []*descriptorpb.DescriptorProto{{
+			Name:  proto.String("Result"),
+			Field: []*descriptorpb.FieldDescriptorProto{stringField("url", 2)},
+		}},
+	})
+	file.Syntax = proto.String("proto2")
+
+	content := runGenerator(t, file)["mirror"+FileSuffix]
+	for _, want := range []string{
+		"type SearchResponse_ResultSlice []SearchResponse_Result",
+		"if err := protojson.Unmarshal(elem, &out[i]); err != nil {",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated content missing %q\n%s", want, content)
+		}
+	}
+}
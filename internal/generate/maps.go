@@ -0,0 +1,177 @@
+package generate
+
+import (
+	"fmt"
+
+	"github.com/benjamin-rood/protogo-values/internal/parser"
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+const protoPackage = protogen.GoImportPath("google.golang.org/protobuf/proto")
+
+// Helpers shared by the value map accessors of a Go package. A message value
+// held in a map cannot be built or read without copying the struct, runtime
+// state included, so each copy is made in one place: messageValue copies a
+// fresh clone out, and cloneMapValue clones a value read out of a map.
+const (
+	cloneMessageFunc  = "cloneMessage"
+	messageValueFunc  = "messageValue"
+	cloneMapValueFunc = "cloneMapValue"
+)
+
+// isValueMap reports whether field is a message-valued map with value_map set
+func isValueMap(field *protogen.Field) bool {
+	if !field.Desc.IsMap() || field.Message.Fields[1].Message == nil {
+		return false
+	}
+	return parser.StructuredOptions(fieldOptions(field)).GetValueMap()
+}
+
+// valueMapMethods returns the accessors emitted for a value_map field
+func valueMapMethods(field *protogen.Field) (getter, setter string) {
+	getter = field.GoName + "Values"
+	return getter, "Set" + getter
+}
+
+// planValueMaps checks that the accessors of every value_map field do not
+// clash with generated code, and returns the files declaring the helpers
+// they share: the first generated file of each Go package with such a field
+func planValueMaps(gen *protogen.Plugin, taken packageIdents) (map[*protogen.File]bool, error) {
+	helpers := make(map[*protogen.File]bool)
+	seen := make(map[protogen.GoImportPath]bool)
+	for _, file := range gen.Files {
+		if !file.Generate {
+			continue
+		}
+		for _, msg := range allMessages(file.Messages) {
+			for _, field := range msg.Fields {
+				if !isValueMap(field) {
+					continue
+				}
+				if err := checkValueMapMethods(msg, field); err != nil {
+					return nil, err
+				}
+				if seen[file.GoImportPath] {
+					continue
+				}
+				seen[file.GoImportPath] = true
+				for _, name := range []string{cloneMessageFunc, messageValueFunc, cloneMapValueFunc} {
+					if err := taken.claim(file, field.Desc, name, "the value map helpers"); err != nil {
+						return nil, err
+					}
+				}
+				helpers[file] = true
+			}
+		}
+	}
+	return helpers, nil
+}
+
+// checkValueMapMethods rejects value_map fields whose accessors would share
+// a name with a struct field of msg or the getter protoc-gen-go generates
+// for one
+func checkValueMapMethods(msg *protogen.Message, field *protogen.Field) error {
+	getter, setter := valueMapMethods(field)
+	for _, name := range []string{getter, setter} {
+		for _, other := range msg.Fields {
+			if other.GoName == name || "Get"+other.GoName == name || (other.Oneof != nil && other.Oneof.GoName == name) {
+				return locate(field.Desc, fmt.Errorf("cannot generate %s.%s for value_map field %s: conflicts with field %s",
+					msg.GoIdent.GoName, name, field.Desc.Name(), other.Desc.Name()))
+			}
+		}
+	}
+	return nil
+}
+
+// genValueMapHelpers emits the clone helpers used by the value map accessors
+// of a Go package
+func genValueMapHelpers(g *protogen.GeneratedFile) {
+	message := g.QualifiedGoIdent(protoPackage.Ident("Message"))
+	clone := g.QualifiedGoIdent(protoPackage.Ident("Clone"))
+
+	g.P("// ", cloneMessageFunc, " returns a deep copy of m.")
+	g.P("func ", cloneMessageFunc, "[P ", message, "](m P) P {")
+	g.P("return ", clone, "(m).(P)")
+	g.P("}")
+	g.P()
+
+	g.P("// ", messageValueFunc, " returns a deep copy of m by value. The value is copied out of a")
+	g.P("// fresh clone that nothing else refers to, but it still carries a copy of")
+	g.P("// the clone's runtime state, so it must not be used with proto APIs.")
+	g.P("func ", messageValueFunc, "[V any, P interface {")
+	g.P("*V")
+	g.P(message)
+	g.P("}](m P) V {")
+	g.P("return *", cloneMessageFunc, "(m)")
+	g.P("}")
+	g.P()
+
+	g.P("// ", cloneMapValueFunc, " returns a pointer to a deep copy of the message m holds at k.")
+	g.P("// A map value can only be read by copying it; the copy is the source of")
+	g.P("// the clone and never escapes, and the result is a fresh message.")
+	g.P("func ", cloneMapValueFunc, "[K comparable, V any, P interface {")
+	g.P("*V")
+	g.P(message)
+	g.P("}](m map[K]V, k K) P {")
+	g.P("v := m[k]")
+	g.P("return ", cloneMessageFunc, "(P(&v))")
+	g.P("}")
+	g.P()
+}
+
+// genValueMaps emits value-typed accessors for every map field of msg marked
+// with value_map. It reports whether anything was emitted.
+func genValueMaps(g *protogen.GeneratedFile, msg *protogen.Message) bool {
+	emitted := false
+	for _, field := range msg.Fields {
+		if isValueMap(field) {
+			genValueMap(g, msg, field)
+			emitted = true
+		}
+	}
+	return emitted
+}
+
+// genValueMap emits the getter and setter for a single value_map field
+func genValueMap(g *protogen.GeneratedFile, msg *protogen.Message, field *protogen.Field) {
+	keyType, _ := fieldGoType(g, field.Message.Fields[0])
+	valueType := g.QualifiedGoIdent(field.Message.Fields[1].Message.GoIdent)
+	mapType := "map[" + keyType + "]" + valueType
+	getter, setter := valueMapMethods(field)
+
+	g.P("// ", getter, " returns a copy of ", field.GoName, " with the message values")
+	g.P("// dereferenced. Each value is a deep copy; nil entries are returned as")
+	g.P("// zero values. The values are plain Go values for reading fields and for")
+	g.P("// passing to ", setter, ": they hold a copy of protobuf runtime state, so")
+	g.P("// they, and pointers to them, must not be used with proto APIs such as")
+	g.P("// proto.Marshal or proto.Equal. Use ", field.GoName, " for those.")
+	g.P("func (x *", msg.GoIdent.GoName, ") ", getter, "() ", mapType, " {")
+	g.P("if x == nil || x.", field.GoName, " == nil {")
+	g.P("return nil")
+	g.P("}")
+	g.P("m := make(", mapType, ", len(x.", field.GoName, "))")
+	g.P("for k, v := range x.", field.GoName, " {")
+	g.P("if v == nil {")
+	g.P("m[k] = ", valueType, "{}")
+	g.P("continue")
+	g.P("}")
+	g.P("m[k] = ", messageValueFunc, "(v)")
+	g.P("}")
+	g.P("return m")
+	g.P("}")
+	g.P()
+
+	g.P("// ", setter, " replaces ", field.GoName, " with pointers to deep copies of the values in m.")
+	g.P("// The copies are fresh messages, safe to use with proto APIs whatever m holds.")
+	g.P("func (x *", msg.GoIdent.GoName, ") ", setter, "(m ", mapType, ") {")
+	g.P("if m == nil {")
+	g.P("x.", field.GoName, " = nil")
+	g.P("return")
+	g.P("}")
+	g.P("x.", field.GoName, " = make(map[", keyType, "]*", valueType, ", len(m))")
+	g.P("for k := range m {")
+	g.P("x.", field.GoName, "[k] = ", cloneMapValueFunc, "(m, k)")
+	g.P("}")
+	g.P("}")
+	g.P()
+}
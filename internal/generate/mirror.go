@@ -0,0 +1,420 @@
+package generate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/benjamin-rood/protogo-values/internal/parser"
+	"github.com/benjamin-rood/protogo-values/proto/protogo_values"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// mirrorSuffix is appended to a message's Go name to name its mirror struct
+const mirrorSuffix = "Mirror"
+
+// mirrorSet holds the messages that get a plain Go mirror struct
+type mirrorSet map[protoreflect.FullName]bool
+
+// planMirrors returns the messages that need a mirror struct: every message
+// that sets mirror or has a field requiring one, plus the targets of
+// non-nullable fields, transitively.
+// Chains of non-nullable fields leading back to their origin are rejected
+// since they cannot be embedded by value, and so are mirror names already
+// declared in their package.
+func planMirrors(gen *protogen.Plugin, taken packageIdents) (mirrorSet, error) {
+	var ordered []*protogen.Message
+	generated := make(map[protoreflect.FullName]*protogen.Message)
+	for _, file := range gen.Files {
+		if !file.Generate {
+			continue
+		}
+		for _, msg := range allMessages(file.Messages) {
+			ordered = append(ordered, msg)
+			generated[msg.Desc.FullName()] = msg
+		}
+	}
+
+	mirrors := make(mirrorSet)
+	var queue []*protogen.Message
+	for _, msg := range ordered {
+		if needsMirror(msg) {
+			queue = append(queue, msg)
+		}
+	}
+
+	for len(queue) > 0 {
+		msg := queue[0]
+		queue = queue[1:]
+		if mirrors[msg.Desc.FullName()] {
+			continue
+		}
+		mirrors[msg.Desc.FullName()] = true
+
+		for _, field := range nonNullableFields(msg) {
+			target, ok := generated[field.Message.Desc.FullName()]
+			if !ok {
+				ident := mirrorIdent(field.Message)
+				return nil, locate(field.Desc, fmt.Errorf("field %s: nullable = false refers to %s.%s, which does not exist: %s must be generated in the same request",
+					field.Desc.FullName(), string(ident.GoImportPath), ident.GoName, field.Message.Desc.FullName()))
+			}
+			queue = append(queue, target)
+		}
+	}
+
+	if err := checkMirrorCycles(ordered, generated, mirrors); err != nil {
+		return nil, err
+	}
+	for _, file := range gen.Files {
+		if !file.Generate {
+			continue
+		}
+		for _, msg := range allMessages(file.Messages) {
+			if !mirrors[msg.Desc.FullName()] {
+				continue
+			}
+			what := "the mirror of " + string(msg.Desc.FullName())
+			if err := taken.claim(file, msg.Desc, mirrorIdent(msg).GoName, what); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, msg := range ordered {
+		if err := checkPresenceStyle(msg, mirrors); err != nil {
+			return nil, err
+		}
+		if mirrors[msg.Desc.FullName()] {
+			if err := checkMirrorMethods(msg); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return mirrors, nil
+}
+
+// checkMirrorMethods rejects fields and oneofs sharing a name with the
+// converters: ToMirror is declared on the message and ToProto on the mirror,
+// and both structs have a field for each of them
+func checkMirrorMethods(msg *protogen.Message) error {
+	methods := map[string]string{
+		"ToMirror": msg.GoIdent.GoName,
+		"ToProto":  mirrorIdent(msg).GoName,
+	}
+	for _, field := range msg.Fields {
+		receiver, ok := methods[mirrorFieldName(field)]
+		if !ok {
+			continue
+		}
+		if isOneofMember(field) {
+			return locate(field.Oneof.Desc, fmt.Errorf("cannot generate %s.%s for the mirror of %s: conflicts with oneof %s",
+				receiver, mirrorFieldName(field), msg.Desc.FullName(), field.Oneof.Desc.Name()))
+		}
+		return locate(field.Desc, fmt.Errorf("cannot generate %s.%s for the mirror of %s: conflicts with field %s",
+			receiver, field.GoName, msg.Desc.FullName(), field.Desc.Name()))
+	}
+	return nil
+}
+
+// needsMirror reports whether msg asks for a mirror struct or any of its
+// fields requires one
+func needsMirror(msg *protogen.Message) bool {
+	if parser.MessageLevelOptions(messageOptions(msg)).GetMirror() {
+		return true
+	}
+	if len(nonNullableFields(msg)) > 0 {
+		return true
+	}
+	for _, field := range msg.Fields {
+		if _, ok := nativeWKT(field); ok {
+			return true
+		}
+		if _, ok := customGoType(field); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// nonNullableFields returns the fields of msg embedded by value in its mirror
+func nonNullableFields(msg *protogen.Message) []*protogen.Field {
+	var fields []*protogen.Field
+	for _, field := range msg.Fields {
+		if isMirrorEmbedded(field) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// checkMirrorCycles rejects recursive chains of non-nullable fields, which
+// would produce mirror structs of infinite size
+func checkMirrorCycles(ordered []*protogen.Message, generated map[protoreflect.FullName]*protogen.Message, mirrors mirrorSet) error {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[protoreflect.FullName]int)
+	var path []string
+
+	var visit func(msg *protogen.Message) error
+	visit = func(msg *protogen.Message) error {
+		switch state[msg.Desc.FullName()] {
+		case visiting:
+			return locate(msg.Desc, fmt.Errorf("nullable = false fields form a cycle that cannot be embedded by value: %s",
+				strings.Join(path, " -> ")))
+		case done:
+			return nil
+		}
+		state[msg.Desc.FullName()] = visiting
+		for _, field := range nonNullableFields(msg) {
+			path = append(path, string(field.Desc.FullName()))
+			if err := visit(generated[field.Message.Desc.FullName()]); err != nil {
+				return err
+			}
+			path = path[:len(path)-1]
+		}
+		state[msg.Desc.FullName()] = done
+		return nil
+	}
+
+	for _, msg := range ordered {
+		if !mirrors[msg.Desc.FullName()] {
+			continue
+		}
+		if err := visit(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mirrorIdent returns the Go identifier of the mirror struct for msg
+func mirrorIdent(msg *protogen.Message) protogen.GoIdent {
+	return protogen.GoIdent{
+		GoName:       msg.GoIdent.GoName + mirrorSuffix,
+		GoImportPath: msg.GoIdent.GoImportPath,
+	}
+}
+
+// genMirror emits the mirror struct for msg and its converters
+func genMirror(g *protogen.GeneratedFile, msg *protogen.Message) {
+	name := mirrorIdent(msg).GoName
+
+	g.P("// ", name, " is a plain Go mirror of ", msg.GoIdent.GoName, " without protobuf runtime")
+	g.P("// state. Non-nullable message fields are embedded by value, mapped")
+	g.P("// well-known types use native Go types and go_type fields use their custom")
+	g.P("// types. Scalars with explicit presence are copied; other message fields")
+	g.P("// share their values with the source message.")
+	g.P("type ", name, " struct {")
+	forEachMirrorField(msg, func(field *protogen.Field) {
+		g.P(mirrorFieldName(field), " ", mirrorFieldType(g, field))
+		if hasPresenceFlag(field) {
+			g.P(presenceFlagName(field), " bool")
+		}
+	})
+	g.P("}")
+	g.P()
+
+	g.P("// ToMirror converts x to its plain Go mirror. A nil x yields the zero value.")
+	g.P("func (x *", msg.GoIdent.GoName, ") ToMirror() ", name, " {")
+	g.P("var m ", name)
+	g.P("if x == nil {")
+	g.P("return m")
+	g.P("}")
+	forEachMirrorField(msg, func(field *protogen.Field) {
+		genFieldToMirror(g, field)
+	})
+	g.P("return m")
+	g.P("}")
+	g.P()
+
+	g.P("// ToProto converts m back to a newly allocated ", msg.GoIdent.GoName, ".")
+	var zeroIsNil []string
+	forEachMirrorField(msg, func(field *protogen.Field) {
+		if mapping, ok := nativeWKT(field); ok && mapping.zeroIsNil {
+			zeroIsNil = append(zeroIsNil, field.GoName)
+		}
+	})
+	if n := len(zeroIsNil); n > 0 {
+		fields := zeroIsNil[n-1]
+		if n > 1 {
+			fields = strings.Join(zeroIsNil[:n-1], ", ") + " and " + fields
+		}
+		g.P("// Zero values of ", fields, " convert to nil, so a set Timestamp or")
+		g.P("// Duration holding the zero value does not survive ToMirror and ToProto.")
+	}
+	g.P("func (m *", name, ") ToProto() *", msg.GoIdent.GoName, " {")
+	g.P("if m == nil {")
+	g.P("return nil")
+	g.P("}")
+	g.P("x := &", msg.GoIdent.GoName, "{}")
+	forEachMirrorField(msg, func(field *protogen.Field) {
+		genFieldToProto(g, field)
+	})
+	g.P("return x")
+	g.P("}")
+	g.P()
+}
+
+// genFieldToMirror emits the statements copying field from the message x
+// to the mirror m
+func genFieldToMirror(g *protogen.GeneratedFile, field *protogen.Field) {
+	fieldName := mirrorFieldName(field)
+	if mapping, ok := nativeWKT(field); ok {
+		mapping.toNative(g, "m."+fieldName, "x."+fieldName)
+		return
+	}
+	if ct, ok := customGoType(field); ok {
+		g.P("m.", fieldName, " = ", ct.fromProto, "(x.", fieldName, ")")
+		return
+	}
+	if isMirrorEmbedded(field) {
+		g.P("m.", fieldName, " = x.", fieldName, ".ToMirror()")
+		return
+	}
+	if hasPresenceFlag(field) {
+		// The getter yields the proto2 default for unset fields
+		g.P("m.", fieldName, " = x.Get", fieldName, "()")
+		g.P("m.", presenceFlagName(field), " = x.", fieldName, " != nil")
+		return
+	}
+	if isPresenceScalar(field) {
+		genCopyPointer(g, "m."+fieldName, "x."+fieldName)
+		return
+	}
+	g.P("m.", fieldName, " = x.", fieldName)
+}
+
+// genFieldToProto emits the statements copying field from the mirror m back
+// to the message x
+func genFieldToProto(g *protogen.GeneratedFile, field *protogen.Field) {
+	fieldName := mirrorFieldName(field)
+	if mapping, ok := nativeWKT(field); ok {
+		mapping.toProto(g, "x."+fieldName, "m."+fieldName)
+		return
+	}
+	if ct, ok := customGoType(field); ok {
+		g.P("x.", fieldName, " = ", ct.toProto, "(m.", fieldName, ")")
+		return
+	}
+	if isMirrorEmbedded(field) {
+		g.P("x.", fieldName, " = m.", fieldName, ".ToProto()")
+		return
+	}
+	if hasPresenceFlag(field) {
+		g.P("if m.", presenceFlagName(field), " {")
+		g.P("v := m.", fieldName)
+		g.P("x.", fieldName, " = &v")
+		g.P("}")
+		return
+	}
+	if isPresenceScalar(field) {
+		genCopyPointer(g, "x."+fieldName, "m."+fieldName)
+		return
+	}
+	g.P("x.", fieldName, " = m.", fieldName)
+}
+
+// genCopyPointer emits statements pointing dst at a copy of the value src
+// points to, leaving dst nil when src is
+func genCopyPointer(g *protogen.GeneratedFile, dst, src string) {
+	g.P("if ", src, " != nil {")
+	g.P("v := *", src)
+	g.P(dst, " = &v")
+	g.P("}")
+}
+
+// forEachMirrorField calls fn for each struct field of msg in declaration
+// order, visiting each real oneof once through its first member
+func forEachMirrorField(msg *protogen.Message, fn func(field *protogen.Field)) {
+	for _, field := range msg.Fields {
+		if isOneofMember(field) && field.Oneof.Fields[0] != field {
+			continue
+		}
+		fn(field)
+	}
+}
+
+// mirrorFieldName returns the struct field name shared by the message and
+// its mirror. Oneofs are represented by a single field named after the oneof.
+func mirrorFieldName(field *protogen.Field) string {
+	if isOneofMember(field) {
+		return field.Oneof.GoName
+	}
+	return field.GoName
+}
+
+// mirrorFieldType returns the Go type of field in a mirror struct
+func mirrorFieldType(g *protogen.GeneratedFile, field *protogen.Field) string {
+	if isOneofMember(field) {
+		return oneofInterfaceName(field.Oneof)
+	}
+	if mapping, ok := nativeWKT(field); ok {
+		return mapping.goType(g)
+	}
+	if ct, ok := customGoType(field); ok {
+		return g.QualifiedGoIdent(ct.goType)
+	}
+	if isMirrorEmbedded(field) {
+		return g.QualifiedGoIdent(mirrorIdent(field.Message))
+	}
+	goType, pointer := fieldGoType(g, field)
+	if pointer && !hasPresenceFlag(field) {
+		goType = "*" + goType
+	}
+	return goType
+}
+
+// isPresenceScalar reports whether field is a scalar with explicit presence,
+// held by protoc-gen-go as a pointer. Bytes are excluded since nil already
+// means unset, and oneof members are represented by their oneof.
+func isPresenceScalar(field *protogen.Field) bool {
+	return field.Desc.HasPresence() && field.Message == nil && !isOneofMember(field) &&
+		field.Desc.Kind() != protoreflect.BytesKind
+}
+
+// hasPresenceFlag reports whether the mirror of field's message holds field
+// by value next to a Has<Field> flag rather than as a pointer
+func hasPresenceFlag(field *protogen.Field) bool {
+	style := parser.MessageLevelOptions(messageOptions(field.Parent)).GetPresence()
+	return isPresenceScalar(field) && style == protogo_values.PresenceStyle_PRESENCE_STYLE_HAS_FLAG
+}
+
+// presenceFlagName returns the mirror field recording whether field is set
+func presenceFlagName(field *protogen.Field) string {
+	return "Has" + field.GoName
+}
+
+// checkPresenceStyle rejects a presence setting on a message that gets no
+// mirror struct, and Has<Field> flags clashing with other mirror fields
+func checkPresenceStyle(msg *protogen.Message, mirrors mirrorSet) error {
+	if parser.MessageLevelOptions(messageOptions(msg)).Presence == nil {
+		return nil
+	}
+	if !mirrors[msg.Desc.FullName()] {
+		return locate(msg.Desc, fmt.Errorf("message %s: presence only applies to mirror structs; set mirror = true",
+			msg.Desc.FullName()))
+	}
+	for _, field := range msg.Fields {
+		if !hasPresenceFlag(field) {
+			continue
+		}
+		for _, other := range msg.Fields {
+			if mirrorFieldName(other) == presenceFlagName(field) {
+				return locate(field.Desc, fmt.Errorf("message %s: presence flag %s for field %s conflicts with field %s",
+					msg.Desc.FullName(), presenceFlagName(field), field.Desc.Name(), other.Desc.Name()))
+			}
+		}
+	}
+	return nil
+}
+
+// isMirrorEmbedded reports whether field is held by value in a mirror struct
+func isMirrorEmbedded(field *protogen.Field) bool {
+	if _, ok := nativeWKT(field); ok {
+		return false
+	}
+	return field.Message != nil && !field.Desc.IsList() && !field.Desc.IsMap() &&
+		parser.IsNonNullable(fieldOptions(field))
+}
@@ -0,0 +1,227 @@
+package generate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/benjamin-rood/protogo-values/proto/protogo_values"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func messageField(name string, number int32, typeName string, opts *descriptorpb.FieldOptions) *descriptorpb.FieldDescriptorProto {
+	return &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(name),
+		Number:   proto.Int32(number),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+		TypeName: proto.String(typeName),
+		Options:  opts,
+	}
+}
+
+func stringField(name string, number int32) *descriptorpb.FieldDescriptorProto {
+	return &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(name),
+		Number:   proto.Int32(number),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+	}
+}
+
+func nonNullable() *descriptorpb.FieldOptions {
+	return fieldOpts(&protogo_values.FieldOptions{Nullable: proto.Bool(false)})
+}
+
+func testFile(messages ...*descriptorpb.DescriptorProto) *descriptorpb.FileDescriptorProto {
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("mirror.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto3"),
+		Options: &descriptorpb.FileOptions{
+			GoPackage: proto.String("example.com/test;test"),
+		},
+		MessageType: messages,
+	}
+}
+
+func TestGenerateMirror(t *testing.T) {
+	file := testFile(
+		&descriptorpb.DescriptorProto{
+			Name:  proto.String("User"),
+			Field: []*descriptorpb.FieldDescriptorProto{stringField("id", 1)},
+		},
+		&descriptorpb.DescriptorProto{
+			Name: proto.String("Order"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				stringField("id", 1),
+				messageField("customer", 2, ".test.User", nonNullable()),
+				messageField("referrer", 3, ".test.User", nil),
+			},
+		},
+		&descriptorpb.DescriptorProto{
+			Name:  proto.String("Unrelated"),
+			Field: []*descriptorpb.FieldDescriptorProto{stringField("id", 1)},
+		},
+	)
+
+	content := runGenerator(t, file)["mirror"+FileSuffix]
+
+	expected := []string{
+		"type UserMirror struct {",
+		"type OrderMirror struct {",
+		"Customer UserMirror",
+		"Referrer *User",
+		"func (x *Order) ToMirror() OrderMirror {",
+		"m.Customer = x.Customer.ToMirror()",
+		"func (m *OrderMirror) ToProto() *Order {",
+		"x.Customer = m.Customer.ToProto()",
+		"x.Referrer = m.Referrer",
+	}
+	for _, want := range expected {
+		if !strings.Contains(content, want) {
+			t.Errorf("expected generated code to contain %q:\n%s", want, content)
+		}
+	}
+
+	if strings.Contains(content, "UnrelatedMirror") {
+		t.Errorf("messages without non-nullable fields should not get a mirror:\n%s", content)
+	}
+}
+
+func TestGenerateMirrorRejectsCycles(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []*descriptorpb.DescriptorProto
+	}{
+		{
+			name: "self reference",
+			messages: []*descriptorpb.DescriptorProto{
+				{
+					Name:  proto.String("Node"),
+					Field: []*descriptorpb.FieldDescriptorProto{messageField("next", 1, ".test.Node", nonNullable())},
+				},
+			},
+		},
+		{
+			name: "indirect reference",
+			messages: []*descriptorpb.DescriptorProto{
+				{
+					Name:  proto.String("A"),
+					Field: []*descriptorpb.FieldDescriptorProto{messageField("b", 1, ".test.B", nonNullable())},
+				},
+				{
+					Name:  proto.String("B"),
+					Field: []*descriptorpb.FieldDescriptorProto{messageField("a", 1, ".test.A", nonNullable())},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &pluginpb.CodeGeneratorRequest{
+				FileToGenerate: []string{"mirror.proto"},
+				ProtoFile:      []*descriptorpb.FileDescriptorProto{testFile(tt.messages...)},
+			}
+			gen, err := protogen.Options{}.New(req)
+			if err != nil {
+				t.Fatalf("protogen.Options.New() failed: %v", err)
+			}
+
+			err = GenerateFiles(gen)
+			if err == nil || !strings.Contains(err.Error(), "cycle") {
+				t.Errorf("GenerateFiles() error = %v, expected cycle error", err)
+			}
+		})
+	}
+}
+
+func TestGenerateMirrorNameConflict(t *testing.T) {
+	file := testFile(
+		&descriptorpb.DescriptorProto{
+			Name:  proto.String("User"),
+			Field: []*descriptorpb.FieldDescriptorProto{stringField("id", 1)},
+		},
+		&descriptorpb.DescriptorProto{
+			Name:  proto.String("UserMirror"),
+			Field: []*descriptorpb.FieldDescriptorProto{stringField("id", 1)},
+		},
+		&descriptorpb.DescriptorProto{
+			Name:  proto.String("Order"),
+			Field: []*descriptorpb.FieldDescriptorProto{messageField("customer", 1, ".test.User", nonNullable())},
+		},
+	)
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{file.GetName()},
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{file},
+	}
+	gen, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatalf("protogen.Options.New() failed: %v", err)
+	}
+
+	err = GenerateFiles(gen)
+	if err == nil || !strings.Contains(err.Error(), "cannot declare UserMirror for the mirror of test.User: conflicts with test.UserMirror") {
+		t.Errorf("GenerateFiles() error = %v, expected a mirror name conflict", err)
+	}
+}
+
+func TestGenerateMirrorMethodConflict(t *testing.T) {
+	// The fields are held by the message, which declares ToMirror, and by
+	// the mirror, which declares ToProto
+	tests := map[string]string{
+		"to_mirror": "cannot generate Account.ToMirror for the mirror of test.Account: conflicts with field to_mirror",
+		"to_proto":  "cannot generate AccountMirror.ToProto for the mirror of test.Account: conflicts with field to_proto",
+	}
+	for name, want := range tests {
+		t.Run(name, func(t *testing.T) {
+			file := testFile(&descriptorpb.DescriptorProto{
+				Name:  proto.String("Account"),
+				Field: []*descriptorpb.FieldDescriptorProto{stringField(name, 1), messageField("owner", 2, ".test.User", nonNullable())},
+			}, &descriptorpb.DescriptorProto{
+				Name:  proto.String("User"),
+				Field: []*descriptorpb.FieldDescriptorProto{stringField("id", 1)},
+			})
+			req := &pluginpb.CodeGeneratorRequest{
+				FileToGenerate: []string{file.GetName()},
+				ProtoFile:      []*descriptorpb.FileDescriptorProto{file},
+			}
+			gen, err := protogen.Options{}.New(req)
+			if err != nil {
+				t.Fatalf("protogen.Options.New() failed: %v", err)
+			}
+
+			err = GenerateFiles(gen)
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("GenerateFiles() error = %v, expected %q", err, want)
+			}
+		})
+	}
+}
+
+func TestGenerateMirrorAllowsNullableCycles(t *testing.T) {
+	// A recursive type is fine as long as the recursion goes through a pointer
+	file := testFile(
+		&descriptorpb.DescriptorProto{
+			Name: proto.String("Node"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				messageField("next", 1, ".test.Node", nil),
+				messageField("label", 2, ".test.Label", nonNullable()),
+			},
+		},
+		&descriptorpb.DescriptorProto{
+			Name:  proto.String("Label"),
+			Field: []*descriptorpb.FieldDescriptorProto{stringField("text", 1)},
+		},
+	)
+
+	content := runGenerator(t, file)["mirror"+FileSuffix]
+	if !strings.Contains(content, "Next  *Node") {
+		t.Errorf("expected nullable recursive field to stay a pointer:\n%s", content)
+	}
+}
@@ -0,0 +1,149 @@
+package generate
+
+import (
+	"fmt"
+
+	"github.com/benjamin-rood/protogo-values/internal/parser"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// oneofSet holds the oneofs that get value-typed accessors
+type oneofSet map[protoreflect.FullName]bool
+
+// isValueOneof reports whether value_oneof is set on oneof
+func isValueOneof(oneof *protogen.Oneof) bool {
+	opts, _ := oneof.Desc.Options().(*descriptorpb.OneofOptions)
+	return !oneof.Desc.IsSynthetic() && parser.OneofLevelOptions(opts).GetValueOneof()
+}
+
+// valueOneofName returns the sealed interface emitted for oneof
+func valueOneofName(oneof *protogen.Oneof) string {
+	return oneof.GoIdent.GoName + "Value"
+}
+
+// valueCaseName returns the value-typed case emitted for a oneof member,
+// named after protoc-gen-go's wrapper type
+func valueCaseName(field *protogen.Field) string {
+	return field.GoIdent.GoName + "Value"
+}
+
+// planValueOneofs collects the oneofs with value_oneof set and checks that
+// the types and methods emitted for them do not clash with generated code
+func planValueOneofs(gen *protogen.Plugin, taken packageIdents) (oneofSet, error) {
+	oneofs := make(oneofSet)
+	for _, file := range gen.Files {
+		if !file.Generate {
+			continue
+		}
+		for _, msg := range allMessages(file.Messages) {
+			for _, oneof := range msg.Oneofs {
+				if !isValueOneof(oneof) {
+					continue
+				}
+				what := "the value cases of " + string(oneof.Desc.FullName())
+				if err := taken.claim(file, oneof.Desc, valueOneofName(oneof), what); err != nil {
+					return nil, err
+				}
+				for _, field := range oneof.Fields {
+					if err := taken.claim(file, field.Desc, valueCaseName(field), what); err != nil {
+						return nil, err
+					}
+				}
+				if err := checkOneofMethods(msg, oneof); err != nil {
+					return nil, err
+				}
+				oneofs[oneof.Desc.FullName()] = true
+			}
+		}
+	}
+	return oneofs, nil
+}
+
+// checkOneofMethods rejects oneofs whose accessors would share a name with
+// a struct field of msg
+func checkOneofMethods(msg *protogen.Message, oneof *protogen.Oneof) error {
+	getter := oneof.GoName + "Value"
+	for _, name := range []string{getter, "Set" + getter} {
+		for _, field := range msg.Fields {
+			if field.GoName == name || (field.Oneof != nil && field.Oneof.GoName == name) {
+				return locate(oneof.Desc, fmt.Errorf("cannot generate %s.%s for oneof %s: conflicts with field %s",
+					msg.GoIdent.GoName, name, oneof.Desc.Name(), field.Desc.Name()))
+			}
+		}
+	}
+	return nil
+}
+
+// genValueOneofs emits the value-typed accessors for the oneofs of msg in
+// oneofs, reporting whether anything was emitted
+func genValueOneofs(g *protogen.GeneratedFile, msg *protogen.Message, oneofs oneofSet) bool {
+	emitted := false
+	for _, oneof := range msg.Oneofs {
+		if oneofs[oneof.Desc.FullName()] {
+			genValueOneof(g, msg, oneof)
+			emitted = true
+		}
+	}
+	return emitted
+}
+
+// genValueOneof emits a sealed interface with a value-typed case per member
+// of oneof, and accessors converting to and from protoc-gen-go's wrappers.
+// The cases hold message members by pointer, as the wrappers do: messages
+// carry a lock, so go vet rejects copying them by value.
+func genValueOneof(g *protogen.GeneratedFile, msg *protogen.Message, oneof *protogen.Oneof) {
+	iface := valueOneofName(oneof)
+	marker := "is" + iface
+	name := oneof.Desc.Name()
+
+	g.P("// ", iface, " is a value-typed case of the ", name, " oneof of ", msg.GoIdent.GoName, ".")
+	g.P("// It is sealed: the ", msg.GoIdent.GoName, "_*Value case types are its only implementations.")
+	g.P("type ", iface, " interface {")
+	g.P(marker, "()")
+	g.P("}")
+	g.P()
+
+	for _, field := range oneof.Fields {
+		caseName := valueCaseName(field)
+		goType, _ := fieldGoType(g, field)
+		g.P("// ", caseName, " holds the ", field.Desc.Name(), " case of the ", name, " oneof by value.")
+		g.P("type ", caseName, " struct {")
+		g.P(field.GoName, " ", goType)
+		g.P("}")
+		g.P()
+		g.P("func (", caseName, ") ", marker, "() {}")
+		g.P()
+	}
+
+	g.P("// ", oneof.GoName, "Value returns the case set in the ", name, " oneof by value, or nil")
+	g.P("// if no case is set.")
+	g.P("func (x *", msg.GoIdent.GoName, ") ", oneof.GoName, "Value() ", iface, " {")
+	g.P("if x == nil {")
+	g.P("return nil")
+	g.P("}")
+	g.P("switch v := x.", oneof.GoName, ".(type) {")
+	for _, field := range oneof.Fields {
+		g.P("case *", field.GoIdent, ":")
+		g.P("return ", valueCaseName(field), "{", field.GoName, ": v.", field.GoName, "}")
+	}
+	g.P("}")
+	g.P("return nil")
+	g.P("}")
+	g.P()
+
+	g.P("// Set", oneof.GoName, "Value sets the ", name, " oneof from a value-typed case.")
+	g.P("// A nil case clears the oneof.")
+	g.P("func (x *", msg.GoIdent.GoName, ") Set", oneof.GoName, "Value(v ", iface, ") {")
+	g.P("switch v := v.(type) {")
+	for _, field := range oneof.Fields {
+		g.P("case ", valueCaseName(field), ":")
+		g.P("x.", oneof.GoName, " = &", field.GoIdent, "{", field.GoName, ": v.", field.GoName, "}")
+	}
+	g.P("default:")
+	g.P("x.", oneof.GoName, " = nil")
+	g.P("}")
+	g.P("}")
+	g.P()
+}
@@ -0,0 +1,103 @@
+package generate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/benjamin-rood/protogo-values/proto/protogo_values"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func valueOneofFile(oneofName string) *descriptorpb.FileDescriptorProto {
+	opts := &descriptorpb.OneofOptions{}
+	proto.SetExtension(opts, protogo_values.E_OneofOpts, &protogo_values.OneofOptions{ValueOneof: proto.Bool(true)})
+
+	card := messageField("card", 1, ".test.Card", nil)
+	card.OneofIndex = proto.Int32(0)
+	voucher := stringField("voucher", 2)
+	voucher.OneofIndex = proto.Int32(0)
+
+	return testFile(
+		&descriptorpb.DescriptorProto{
+			Name:  proto.String("Card"),
+			Field: []*descriptorpb.FieldDescriptorProto{stringField("number", 1)},
+		},
+		&descriptorpb.DescriptorProto{
+			Name:      proto.String("Payment"),
+			Field:     []*descriptorpb.FieldDescriptorProto{card, voucher},
+			OneofDecl: []*descriptorpb.OneofDescriptorProto{{Name: proto.String(oneofName), Options: opts}},
+		},
+	)
+}
+
+func TestGenerateValueOneof(t *testing.T) {
+	content := runGenerator(t, valueOneofFile("method"))["mirror"+FileSuffix]
+
+	expected := []string{
+		"type Payment_MethodValue interface {",
+		"isPayment_MethodValue()",
+		"type Payment_CardValue struct {",
+		"Card *Card",
+		"func (Payment_CardValue) isPayment_MethodValue() {}",
+		"Voucher string",
+		"func (x *Payment) MethodValue() Payment_MethodValue {",
+		"case *Payment_Card:",
+		"return Payment_CardValue{Card: v.Card}",
+		"return Payment_VoucherValue{Voucher: v.Voucher}",
+		"func (x *Payment) SetMethodValue(v Payment_MethodValue) {",
+		"x.Method = &Payment_Card{Card: v.Card}",
+		"x.Method = &Payment_Voucher{Voucher: v.Voucher}",
+		"x.Method = nil",
+	}
+	for _, want := range expected {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated content missing %q\n%s", want, content)
+		}
+	}
+}
+
+func TestGenerateValueOneofConflict(t *testing.T) {
+	file := valueOneofFile("method")
+	payment := file.MessageType[1]
+	payment.Field = append(payment.Field, stringField("method_value", 3))
+
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{file.GetName()},
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{file},
+	}
+	gen, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatalf("protogen.Options.New() failed: %v", err)
+	}
+	err = GenerateFiles(gen)
+	if err == nil || !strings.Contains(err.Error(), "conflicts with field method_value") {
+		t.Errorf("expected accessor conflict error, got %v", err)
+	}
+}
+
+func TestGenerateValueOneofConflictPosition(t *testing.T) {
+	file := valueOneofFile("method")
+	payment := file.MessageType[1]
+	payment.Field = append(payment.Field, stringField("method_value", 3))
+	file.SourceCodeInfo = &descriptorpb.SourceCodeInfo{
+		Location: []*descriptorpb.SourceCodeInfo_Location{
+			{Path: []int32{4, 1, 8, 0}, Span: []int32{13, 2, 17, 3}},
+		},
+	}
+
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{file.GetName()},
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{file},
+	}
+	gen, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatalf("protogen.Options.New() failed: %v", err)
+	}
+	err = GenerateFiles(gen)
+	if err == nil || !strings.HasPrefix(err.Error(), "mirror.proto:14:3: ") {
+		t.Errorf("expected error at the oneof declaration, got %v", err)
+	}
+}
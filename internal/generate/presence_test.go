@@ -0,0 +1,110 @@
+package generate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/benjamin-rood/protogo-values/proto/protogo_values"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func presenceFile(style *protogo_values.PresenceStyle, extra ...*descriptorpb.FieldDescriptorProto) *descriptorpb.FileDescriptorProto {
+	opts := &descriptorpb.MessageOptions{}
+	proto.SetExtension(opts, protogo_values.E_MessageOpts, &protogo_values.MessageOptions{
+		Mirror:   proto.Bool(true),
+		Presence: style,
+	})
+
+	// proto2 optional scalar with a declared default
+	quota := &descriptorpb.FieldDescriptorProto{
+		Name:         proto.String("quota"),
+		JsonName:     proto.String("quota"),
+		Number:       proto.Int32(1),
+		Label:        descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:         descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(),
+		DefaultValue: proto.String("10"),
+	}
+	file := testFile(&descriptorpb.DescriptorProto{
+		Name:    proto.String("Account"),
+		Field:   append([]*descriptorpb.FieldDescriptorProto{quota}, extra...),
+		Options: opts,
+	})
+	file.Syntax = proto.String("proto2")
+	return file
+}
+
+func TestGenerateMirrorPresencePointer(t *testing.T) {
+	content := runGenerator(t, presenceFile(nil))["mirror"+FileSuffix]
+
+	expected := []string{
+		"type AccountMirror struct {",
+		"Quota *int32",
+		"if x.Quota != nil {",
+		"v := *x.Quota",
+		"m.Quota = &v",
+		"if m.Quota != nil {",
+		"x.Quota = &v",
+	}
+	for _, want := range expected {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated content missing %q\n%s", want, content)
+		}
+	}
+}
+
+func TestGenerateMirrorPresenceFlag(t *testing.T) {
+	content := runGenerator(t, presenceFile(protogo_values.PresenceStyle_PRESENCE_STYLE_HAS_FLAG.Enum()))["mirror"+FileSuffix]
+
+	expected := []string{
+		"Quota    int32",
+		"HasQuota bool",
+		"m.Quota = x.GetQuota()",
+		"m.HasQuota = x.Quota != nil",
+		"if m.HasQuota {",
+		"v := m.Quota",
+		"x.Quota = &v",
+	}
+	for _, want := range expected {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated content missing %q\n%s", want, content)
+		}
+	}
+}
+
+func TestGenerateMirrorPresenceErrors(t *testing.T) {
+	hasQuota := stringField("has_quota", 2)
+
+	noMirror := presenceFile(protogo_values.PresenceStyle_PRESENCE_STYLE_HAS_FLAG.Enum())
+	proto.SetExtension(noMirror.MessageType[0].Options, protogo_values.E_MessageOpts, &protogo_values.MessageOptions{
+		Presence: protogo_values.PresenceStyle_PRESENCE_STYLE_HAS_FLAG.Enum(),
+	})
+
+	tests := []struct {
+		name string
+		file *descriptorpb.FileDescriptorProto
+		want string
+	}{
+		{"flag conflict", presenceFile(protogo_values.PresenceStyle_PRESENCE_STYLE_HAS_FLAG.Enum(), hasQuota), "conflicts with field has_quota"},
+		{"no mirror", noMirror, "set mirror = true"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &pluginpb.CodeGeneratorRequest{
+				FileToGenerate: []string{tt.file.GetName()},
+				ProtoFile:      []*descriptorpb.FileDescriptorProto{tt.file},
+			}
+			gen, err := protogen.Options{}.New(req)
+			if err != nil {
+				t.Fatalf("protogen.Options.New() failed: %v", err)
+			}
+			err = GenerateFiles(gen)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package generate
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/benjamin-rood/protogo-values/internal/diag"
+	"github.com/benjamin-rood/protogo-values/internal/parser"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// fieldGoType returns the Go type of a message struct field as generated by
+// protoc-gen-go and rewritten by this plugin, and whether it is a pointer.
+// It mirrors fieldGoType in protoc-gen-go's internal_gengo package.
+func fieldGoType(g *protogen.GeneratedFile, field *protogen.Field) (goType string, pointer bool) {
+	pointer = field.Desc.HasPresence()
+	switch field.Desc.Kind() {
+	case protoreflect.BoolKind:
+		goType = "bool"
+	case protoreflect.EnumKind:
+		goType = g.QualifiedGoIdent(field.Enum.GoIdent)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		goType = "int32"
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		goType = "uint32"
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		goType = "int64"
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		goType = "uint64"
+	case protoreflect.FloatKind:
+		goType = "float32"
+	case protoreflect.DoubleKind:
+		goType = "float64"
+	case protoreflect.StringKind:
+		goType = "string"
+	case protoreflect.BytesKind:
+		goType = "[]byte"
+		pointer = false
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		goType = "*" + g.QualifiedGoIdent(field.Message.GoIdent)
+		if field.Desc.IsList() && isValueSlice(field) {
+			goType = g.QualifiedGoIdent(field.Message.GoIdent)
+		}
+		pointer = false
+	}
+	switch {
+	case field.Desc.IsList():
+		return "[]" + goType, false
+	case field.Desc.IsMap():
+		keyType, _ := fieldGoType(g, field.Message.Fields[0])
+		valType, _ := fieldGoType(g, field.Message.Fields[1])
+		return fmt.Sprintf("map[%v]%v", keyType, valType), false
+	}
+	return goType, pointer
+}
+
+// locate reports err at the declaration of desc in its proto source file
+func locate(desc protoreflect.Descriptor, err error) error {
+	return diag.New(diag.Of(desc), err)
+}
+
+// fieldOptions returns the descriptor options of field, or nil if unset
+func fieldOptions(field *protogen.Field) *descriptorpb.FieldOptions {
+	opts, _ := field.Desc.Options().(*descriptorpb.FieldOptions)
+	return opts
+}
+
+// messageOptions returns the descriptor options of msg, or nil if unset
+func messageOptions(msg *protogen.Message) *descriptorpb.MessageOptions {
+	opts, _ := msg.Desc.Options().(*descriptorpb.MessageOptions)
+	return opts
+}
+
+// fileOptions returns the descriptor options of the file declaring field,
+// or nil if unset
+func fileOptions(field *protogen.Field) *descriptorpb.FileOptions {
+	opts, _ := field.Desc.ParentFile().Options().(*descriptorpb.FileOptions)
+	return opts
+}
+
+// isValueSlice reports whether the transformer rewrites field to a value slice
+func isValueSlice(field *protogen.Field) bool {
+	return parser.IsValueSlice(protodesc.ToFieldDescriptorProto(field.Desc))
+}
+
+// isOneofMember reports whether field belongs to a real (non-synthetic) oneof
+func isOneofMember(field *protogen.Field) bool {
+	return field.Oneof != nil && !field.Oneof.Desc.IsSynthetic()
+}
+
+// oneofInterfaceName returns the unexported interface protoc-gen-go
+// generates for a oneof
+func oneofInterfaceName(oneof *protogen.Oneof) string {
+	return "is" + oneof.GoIdent.GoName
+}
+
+// packageIdents maps each generated Go package to the identifiers declared
+// in it and what declares them, so that emitted types can be checked for
+// conflicts
+type packageIdents map[protogen.GoImportPath]map[string]string
+
+// newPackageIdents records the types protoc-gen-go declares for the files
+// being generated: messages, enums and oneof wrappers
+func newPackageIdents(gen *protogen.Plugin) packageIdents {
+	taken := make(packageIdents)
+	for _, file := range gen.Files {
+		if !file.Generate {
+			continue
+		}
+		for _, enum := range file.Enums {
+			taken.add(file.GoImportPath, enum.GoIdent.GoName, string(enum.Desc.FullName()))
+		}
+		for _, msg := range allMessages(file.Messages) {
+			taken.add(file.GoImportPath, msg.GoIdent.GoName, string(msg.Desc.FullName()))
+			for _, enum := range msg.Enums {
+				taken.add(file.GoImportPath, enum.GoIdent.GoName, string(enum.Desc.FullName()))
+			}
+			for _, field := range msg.Fields {
+				if isOneofMember(field) {
+					taken.add(file.GoImportPath, field.GoIdent.GoName, "the oneof wrapper of "+string(field.Desc.FullName()))
+				}
+			}
+		}
+	}
+	return taken
+}
+
+func (p packageIdents) add(pkg protogen.GoImportPath, name, what string) {
+	if p[pkg] == nil {
+		p[pkg] = make(map[string]string)
+	}
+	p[pkg][name] = what
+}
+
+// claim reserves name in the package of file for what, failing at the
+// declaration of desc if it is already declared
+func (p packageIdents) claim(file *protogen.File, desc protoreflect.Descriptor, name, what string) error {
+	if other, ok := p[file.GoImportPath][name]; ok {
+		return locate(desc, fmt.Errorf("cannot declare %s for %s: conflicts with %s", name, what, other))
+	}
+	p.add(file.GoImportPath, name, what)
+	return nil
+}
+
+// claimUnique reserves name in the package of file for what, appending the
+// lowest free numeric suffix if it is already declared, much as protogen
+// deduplicates import aliases
+func (p packageIdents) claimUnique(file *protogen.File, name, what string) string {
+	unique := name
+	for i := 1; ; i++ {
+		if _, ok := p[file.GoImportPath][unique]; !ok {
+			break
+		}
+		unique = name + strconv.Itoa(i)
+	}
+	p.add(file.GoImportPath, unique, what)
+	return unique
+}
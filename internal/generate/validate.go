@@ -0,0 +1,264 @@
+package generate
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/benjamin-rood/protogo-values/internal/parser"
+	"github.com/benjamin-rood/protogo-values/internal/validation"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	errorsPackage = protogen.GoImportPath("errors")
+	fmtPackage    = protogen.GoImportPath("fmt")
+	regexpPackage = protogen.GoImportPath("regexp")
+	sortPackage   = protogen.GoImportPath("sort")
+)
+
+// validatedSet holds the messages that get a Validate method
+type validatedSet map[protoreflect.FullName]bool
+
+// fieldRules holds the parsed validation_rule of each field declaring one
+type fieldRules map[*protogen.Field]*validation.Rules
+
+// parseValidationRules parses the validation_rule of every field in the
+// files to generate, reporting the first that does not parse
+func parseValidationRules(gen *protogen.Plugin) (fieldRules, error) {
+	rules := make(fieldRules)
+	for _, file := range gen.Files {
+		if !file.Generate {
+			continue
+		}
+		for _, msg := range allMessages(file.Messages) {
+			for _, field := range msg.Fields {
+				opts := parser.StructuredOptions(fieldOptions(field))
+				if opts.ValidationRule == nil {
+					continue
+				}
+				parsed, err := validation.Parse(opts.GetValidationRule())
+				if err != nil {
+					return nil, fmt.Errorf("field %s: invalid validation_rule: %w", field.Desc.FullName(), err)
+				}
+				rules[field] = parsed
+			}
+		}
+	}
+	return rules, nil
+}
+
+// planValidation returns the messages that get a Validate method: those
+// declaring validation rules, plus those with message fields leading to one,
+// so nested violations are reported too. A message with a field or oneof
+// generated as Validate cannot also have the method.
+func planValidation(gen *protogen.Plugin, rules fieldRules) (validatedSet, error) {
+	var ordered []*protogen.Message
+	for _, file := range gen.Files {
+		if file.Generate {
+			ordered = append(ordered, allMessages(file.Messages)...)
+		}
+	}
+
+	validated := make(validatedSet)
+	for changed := true; changed; {
+		changed = false
+		for _, msg := range ordered {
+			if validated[msg.Desc.FullName()] {
+				continue
+			}
+			for _, field := range msg.Fields {
+				if rules[field] != nil || validatedTarget(validated, field) != nil {
+					validated[msg.Desc.FullName()] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	for _, msg := range ordered {
+		if !validated[msg.Desc.FullName()] {
+			continue
+		}
+		for _, field := range msg.Fields {
+			if field.GoName == "Validate" {
+				return nil, locate(field.Desc, fmt.Errorf("cannot generate Validate for %s: conflicts with field %s",
+					msg.Desc.FullName(), field.Desc.Name()))
+			}
+		}
+		for _, oneof := range msg.Oneofs {
+			if oneof.GoName == "Validate" {
+				return nil, locate(oneof.Desc, fmt.Errorf("cannot generate Validate for %s: conflicts with oneof %s",
+					msg.Desc.FullName(), oneof.Desc.Name()))
+			}
+		}
+	}
+	return validated, nil
+}
+
+// validatedTarget returns the message type of field, or of its map values,
+// if it gets a Validate method in this request. Targets in files outside the
+// request are not descended into, since their methods cannot be checked.
+func validatedTarget(validated validatedSet, field *protogen.Field) *protogen.Message {
+	target := field.Message
+	if field.Desc.IsMap() {
+		target = field.Message.Fields[1].Message
+	}
+	if target == nil || !validated[target.Desc.FullName()] {
+		return nil
+	}
+	return target
+}
+
+// genValidate emits the Validate method of msg and the helper collecting
+// violations with their field paths
+func genValidate(g *protogen.GeneratedFile, msg *protogen.Message, validated validatedSet, rules fieldRules) {
+	name := msg.GoIdent.GoName
+
+	for _, field := range msg.Fields {
+		if rules := rules[field]; rules != nil && rules.Pattern != nil {
+			g.P("var ", patternVarName(msg, field), " = ", regexpPackage.Ident("MustCompile"), "(", strconv.Quote(*rules.Pattern), ")")
+			g.P()
+		}
+	}
+
+	g.P("// Validate checks x against the validation rules declared in its proto")
+	g.P("// definition. All violations are returned joined, each qualified with its")
+	g.P("// field path.")
+	g.P("func (x *", name, ") Validate() error {")
+	g.P("return ", errorsPackage.Ident("Join"), "(x.validationErrors(\"\")...)")
+	g.P("}")
+	g.P()
+
+	g.P("// validationErrors returns the rule violations of x, prefixing field paths with prefix.")
+	g.P("func (x *", name, ") validationErrors(prefix string) []error {")
+	g.P("if x == nil {")
+	g.P("return nil")
+	g.P("}")
+	g.P("var errs []error")
+	for _, field := range msg.Fields {
+		if rules := rules[field]; rules != nil {
+			genFieldRules(g, msg, field, rules)
+		}
+		if target := validatedTarget(validated, field); target != nil {
+			genNestedValidation(g, field, target.GoIdent.GoImportPath != msg.GoIdent.GoImportPath)
+		}
+	}
+	g.P("return errs")
+	g.P("}")
+	g.P()
+}
+
+// genFieldRules emits the checks for the rules declared on a single field
+func genFieldRules(g *protogen.GeneratedFile, msg *protogen.Message, field *protogen.Field, rules *validation.Rules) {
+	path := string(field.Desc.Name())
+	errorf := g.QualifiedGoIdent(fmtPackage.Ident("Errorf"))
+	ref := "x." + field.GoName
+
+	if rules.Required {
+		g.P("if ", ref, " == nil {")
+		g.P("errs = append(errs, ", errorf, "(\"%s", path, ": is required\", prefix))")
+		g.P("}")
+	}
+	if rules.MinLen != nil {
+		n := strconv.FormatUint(*rules.MinLen, 10)
+		g.P("if len(", ref, ") < ", n, " {")
+		g.P("errs = append(errs, ", errorf, "(\"%s", path, ": must have at least ", n, " elements, got %d\", prefix, len(", ref, ")))")
+		g.P("}")
+	}
+	if rules.MaxLen != nil {
+		n := strconv.FormatUint(*rules.MaxLen, 10)
+		g.P("if len(", ref, ") > ", n, " {")
+		g.P("errs = append(errs, ", errorf, "(\"%s", path, ": must have at most ", n, " elements, got %d\", prefix, len(", ref, ")))")
+		g.P("}")
+	}
+	if rules.Pattern == nil && len(rules.Bounds) == 0 {
+		return
+	}
+
+	// Scalars with explicit presence are only checked when set
+	value := ref
+	if field.Desc.HasPresence() {
+		g.P("if ", ref, " != nil {")
+		value = "*" + ref
+	}
+	if rules.Pattern != nil {
+		g.P("if !", patternVarName(msg, field), ".MatchString(", value, ") {")
+		g.P("errs = append(errs, ", errorf, "(\"%s", path, ": must match %q, got %q\", prefix, ",
+			strconv.Quote(*rules.Pattern), ", ", value, "))")
+		g.P("}")
+	}
+	for _, bound := range rules.Bounds {
+		g.P("if !(", value, " ", bound.Op, " ", bound.Value, ") {")
+		g.P("errs = append(errs, ", errorf, "(\"%s", path, ": must be ", bound.Op, " ", bound.Value, ", got %v\", prefix, ", value, "))")
+		g.P("}")
+	}
+	if field.Desc.HasPresence() {
+		g.P("}")
+	}
+}
+
+// genNestedValidation emits the recursion into a message field whose type
+// has a Validate method, extending the field path. The unexported
+// validationErrors helper of a type in another Go package is out of reach,
+// so foreign violations are unpacked from its exported Validate instead.
+func genNestedValidation(g *protogen.GeneratedFile, field *protogen.Field, foreign bool) {
+	path := string(field.Desc.Name())
+	sprintf := g.QualifiedGoIdent(fmtPackage.Ident("Sprintf"))
+	ref := "x." + field.GoName
+
+	appendErrs := func(recv, prefix string) {
+		if !foreign {
+			g.P("errs = append(errs, ", recv, ".validationErrors(", prefix, ")...)")
+			return
+		}
+		g.P("if joined, ok := ", recv, ".Validate().(interface{ Unwrap() []error }); ok {")
+		g.P("for _, err := range joined.Unwrap() {")
+		g.P("errs = append(errs, ", fmtPackage.Ident("Errorf"), "(\"%s%w\", ", prefix, ", err))")
+		g.P("}")
+		g.P("}")
+	}
+
+	switch {
+	case isOneofMember(field):
+		g.P("if v, ok := x.", field.Oneof.GoName, ".(*", field.GoIdent, "); ok {")
+		appendErrs("v."+field.GoName, "prefix+\""+path+".\"")
+		g.P("}")
+	case field.Desc.IsList():
+		g.P("for i := range ", ref, " {")
+		appendErrs(ref+"[i]", sprintf+"(\"%s"+path+"[%d].\", prefix, i)")
+		g.P("}")
+	case field.Desc.IsMap():
+		// Visit keys in order so violations are reported deterministically.
+		// Each map gets its own block, so the key slices of several map
+		// fields do not collide.
+		keyType, _ := fieldGoType(g, field.Message.Fields[0])
+		if field.Message.Fields[0].Desc.Kind() == protoreflect.BoolKind {
+			g.P("for _, k := range []bool{false, true} {")
+			g.P("if v, ok := ", ref, "[k]; ok {")
+			appendErrs("v", sprintf+"(\"%s"+path+"[%v].\", prefix, k)")
+			g.P("}")
+			g.P("}")
+			return
+		}
+		g.P("{")
+		g.P("keys := make([]", keyType, ", 0, len(", ref, "))")
+		g.P("for k := range ", ref, " {")
+		g.P("keys = append(keys, k)")
+		g.P("}")
+		g.P(sortPackage.Ident("Slice"), "(keys, func(i, j int) bool { return keys[i] < keys[j] })")
+		g.P("for _, k := range keys {")
+		appendErrs(ref+"[k]", sprintf+"(\"%s"+path+"[%v].\", prefix, k)")
+		g.P("}")
+		g.P("}")
+	default:
+		appendErrs(ref, "prefix+\""+path+".\"")
+	}
+}
+
+// patternVarName returns the package-level variable holding the compiled
+// pattern rule of field
+func patternVarName(msg *protogen.Message, field *protogen.Field) string {
+	return "pattern_" + msg.GoIdent.GoName + "_" + field.GoName
+}
@@ -0,0 +1,132 @@
+package generate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/benjamin-rood/protogo-values/proto/protogo_values"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func ruled(rule string) *descriptorpb.FieldOptions {
+	return fieldOpts(&protogo_values.FieldOptions{ValidationRule: proto.String(rule)})
+}
+
+func TestGenerateValidate(t *testing.T) {
+	sku := stringField("sku", 1)
+	sku.Options = ruled(`pattern="^[A-Z]+$"`)
+	quantity := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String("quantity"),
+		JsonName: proto.String("quantity"),
+		Number:   proto.Int32(2),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(),
+		Options:  ruled("gte=1,lte=10"),
+	}
+	items := messageField("items", 2, ".test.Item", ruled("min_len=1"))
+	items.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+
+	file := testFile(
+		&descriptorpb.DescriptorProto{
+			Name:  proto.String("Item"),
+			Field: []*descriptorpb.FieldDescriptorProto{sku, quantity},
+		},
+		&descriptorpb.DescriptorProto{
+			Name: proto.String("Order"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				messageField("customer", 1, ".test.Customer", ruled("required")),
+				items,
+			},
+		},
+		&descriptorpb.DescriptorProto{
+			Name: proto.String("Wrapper"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				messageField("order", 1, ".test.Order", nil),
+			},
+		},
+		&descriptorpb.DescriptorProto{
+			Name:  proto.String("Customer"),
+			Field: []*descriptorpb.FieldDescriptorProto{stringField("name", 1)},
+		},
+	)
+
+	content := runGenerator(t, file)["mirror"+FileSuffix]
+
+	expected := []string{
+		`var pattern_Item_Sku = regexp.MustCompile("^[A-Z]+$")`,
+		"func (x *Item) Validate() error {",
+		"if !pattern_Item_Sku.MatchString(x.Sku) {",
+		"if !(x.Quantity >= 1) {",
+		"if !(x.Quantity <= 10) {",
+		"if x.Customer == nil {",
+		`fmt.Errorf("%scustomer: is required", prefix)`,
+		"if len(x.Items) < 1 {",
+		`x.Items[i].validationErrors(fmt.Sprintf("%sitems[%d].", prefix, i))`,
+		// Wrapper has no rules of its own but reaches Order
+		"func (x *Wrapper) Validate() error {",
+		`x.Order.validationErrors(prefix+"order.")`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated content missing %q\n%s", want, content)
+		}
+	}
+
+	if strings.Contains(content, "func (x *Customer) Validate()") {
+		t.Errorf("Customer has no rules and should not get a Validate method\n%s", content)
+	}
+	if strings.Contains(content, "x.Customer.validationErrors") {
+		t.Errorf("Order should not recurse into Customer, which has no Validate method\n%s", content)
+	}
+}
+
+func TestGenerateValidatePresence(t *testing.T) {
+	score := &descriptorpb.FieldDescriptorProto{
+		Name:           proto.String("score"),
+		JsonName:       proto.String("score"),
+		Number:         proto.Int32(1),
+		Label:          descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:           descriptorpb.FieldDescriptorProto_TYPE_DOUBLE.Enum(),
+		Proto3Optional: proto.Bool(true),
+		OneofIndex:     proto.Int32(0),
+		Options:        ruled("gt=0"),
+	}
+	file := testFile(&descriptorpb.DescriptorProto{
+		Name:      proto.String("Result"),
+		Field:     []*descriptorpb.FieldDescriptorProto{score},
+		OneofDecl: []*descriptorpb.OneofDescriptorProto{{Name: proto.String("_score")}},
+	})
+
+	content := runGenerator(t, file)["mirror"+FileSuffix]
+
+	for _, want := range []string{"if x.Score != nil {", "if !(*x.Score > 0) {"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated content missing %q\n%s", want, content)
+		}
+	}
+}
+
+func TestGenerateValidateRejectsBadRule(t *testing.T) {
+	sku := stringField("sku", 1)
+	sku.Options = ruled("bogus_rule")
+	file := testFile(&descriptorpb.DescriptorProto{
+		Name:  proto.String("Item"),
+		Field: []*descriptorpb.FieldDescriptorProto{sku},
+	})
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{file.GetName()},
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{file},
+	}
+	gen, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatalf("protogen.Options.New() failed: %v", err)
+	}
+
+	err = GenerateFiles(gen)
+	if err == nil || !strings.Contains(err.Error(), "invalid validation_rule") {
+		t.Errorf("GenerateFiles() error = %v, expected an invalid validation_rule error", err)
+	}
+}
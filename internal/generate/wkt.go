@@ -0,0 +1,123 @@
+package generate
+
+import (
+	"github.com/benjamin-rood/protogo-values/internal/parser"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	timePackage        = protogen.GoImportPath("time")
+	timestamppbPackage = protogen.GoImportPath("google.golang.org/protobuf/types/known/timestamppb")
+	durationpbPackage  = protogen.GoImportPath("google.golang.org/protobuf/types/known/durationpb")
+	wrapperspbPackage  = protogen.GoImportPath("google.golang.org/protobuf/types/known/wrapperspb")
+)
+
+// wktMapping describes how a well-known type is represented in a mirror
+// struct. The converters emit statements assigning the converted form of
+// the expression src to dst.
+type wktMapping struct {
+	goType   func(g *protogen.GeneratedFile) string
+	toNative func(g *protogen.GeneratedFile, dst, src string)
+	toProto  func(g *protogen.GeneratedFile, dst, src string)
+	// zeroIsNil is set if the native type has no room for absence, so both
+	// nil and a set message holding the zero value map to the zero value,
+	// which converts back to nil
+	zeroIsNil bool
+}
+
+// wktMappings holds a mapping for every type accepted by parser.IsNativeWKT
+var wktMappings = map[protoreflect.FullName]wktMapping{
+	"google.protobuf.Timestamp": {
+		goType: func(g *protogen.GeneratedFile) string {
+			return g.QualifiedGoIdent(timePackage.Ident("Time"))
+		},
+		toNative: func(g *protogen.GeneratedFile, dst, src string) {
+			g.P("if ", src, " != nil {")
+			g.P(dst, " = ", src, ".AsTime()")
+			g.P("}")
+		},
+		toProto: func(g *protogen.GeneratedFile, dst, src string) {
+			g.P("if !", src, ".IsZero() {")
+			g.P(dst, " = ", timestamppbPackage.Ident("New"), "(", src, ")")
+			g.P("}")
+		},
+		zeroIsNil: true,
+	},
+	"google.protobuf.Duration": {
+		goType: func(g *protogen.GeneratedFile) string {
+			return g.QualifiedGoIdent(timePackage.Ident("Duration"))
+		},
+		toNative: func(g *protogen.GeneratedFile, dst, src string) {
+			g.P("if ", src, " != nil {")
+			g.P(dst, " = ", src, ".AsDuration()")
+			g.P("}")
+		},
+		toProto: func(g *protogen.GeneratedFile, dst, src string) {
+			g.P("if ", src, " != 0 {")
+			g.P(dst, " = ", durationpbPackage.Ident("New"), "(", src, ")")
+			g.P("}")
+		},
+		zeroIsNil: true,
+	},
+	"google.protobuf.DoubleValue": wrapperMapping("float64", "Double"),
+	"google.protobuf.FloatValue":  wrapperMapping("float32", "Float"),
+	"google.protobuf.Int64Value":  wrapperMapping("int64", "Int64"),
+	"google.protobuf.UInt64Value": wrapperMapping("uint64", "UInt64"),
+	"google.protobuf.Int32Value":  wrapperMapping("int32", "Int32"),
+	"google.protobuf.UInt32Value": wrapperMapping("uint32", "UInt32"),
+	"google.protobuf.BoolValue":   wrapperMapping("bool", "Bool"),
+	"google.protobuf.StringValue": wrapperMapping("string", "String"),
+	"google.protobuf.BytesValue": {
+		goType: func(g *protogen.GeneratedFile) string {
+			return "[]byte"
+		},
+		toNative: func(g *protogen.GeneratedFile, dst, src string) {
+			g.P("if ", src, " != nil {")
+			g.P(dst, " = append([]byte{}, ", src, ".GetValue()...)")
+			g.P("}")
+		},
+		toProto: func(g *protogen.GeneratedFile, dst, src string) {
+			g.P("if ", src, " != nil {")
+			g.P(dst, " = ", wrapperspbPackage.Ident("Bytes"), "(", src, ")")
+			g.P("}")
+		},
+	},
+}
+
+// wrapperMapping maps a scalar wrapper type to a pointer to its Go type,
+// keeping nil for an absent wrapper
+func wrapperMapping(goType, constructor string) wktMapping {
+	return wktMapping{
+		goType: func(g *protogen.GeneratedFile) string {
+			return "*" + goType
+		},
+		toNative: func(g *protogen.GeneratedFile, dst, src string) {
+			g.P("if ", src, " != nil {")
+			g.P("v := ", src, ".GetValue()")
+			g.P(dst, " = &v")
+			g.P("}")
+		},
+		toProto: func(g *protogen.GeneratedFile, dst, src string) {
+			g.P("if ", src, " != nil {")
+			g.P(dst, " = ", wrapperspbPackage.Ident(constructor), "(*", src, ")")
+			g.P("}")
+		},
+	}
+}
+
+// nativeWKT returns the mapping for field if it is a singular well-known-type
+// field with native_wkt enabled
+func nativeWKT(field *protogen.Field) (wktMapping, bool) {
+	if field.Message == nil || field.Desc.IsList() || field.Desc.IsMap() || isOneofMember(field) {
+		return wktMapping{}, false
+	}
+	if !parser.IsNativeWKT(string(field.Message.Desc.FullName())) {
+		return wktMapping{}, false
+	}
+	if !parser.UsesNativeWKT(fileOptions(field), fieldOptions(field)) {
+		return wktMapping{}, false
+	}
+	mapping, ok := wktMappings[field.Message.Desc.FullName()]
+	return mapping, ok
+}
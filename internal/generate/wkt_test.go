@@ -0,0 +1,127 @@
+package generate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/benjamin-rood/protogo-values/proto/protogo_values"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func wktFile(fileOpts *protogo_values.FileOptions) *descriptorpb.FileDescriptorProto {
+	file := testFile(&descriptorpb.DescriptorProto{
+		Name: proto.String("Event"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			messageField("created_at", 1, ".google.protobuf.Timestamp", nil),
+			messageField("ttl", 2, ".google.protobuf.Duration", nil),
+			messageField("retries", 3, ".google.protobuf.Int64Value", nil),
+			messageField("payload", 4, ".google.protobuf.BytesValue", nil),
+			messageField("raw_deadline", 5, ".google.protobuf.Timestamp",
+				fieldOpts(&protogo_values.FieldOptions{NativeWkt: proto.Bool(false)})),
+		},
+	})
+	file.Dependency = []string{
+		"google/protobuf/timestamp.proto",
+		"google/protobuf/duration.proto",
+		"google/protobuf/wrappers.proto",
+	}
+	if fileOpts != nil {
+		proto.SetExtension(file.Options, protogo_values.E_FileOpts, fileOpts)
+	}
+	return file
+}
+
+// runWKTGenerator runs the generator over file with the well-known type
+// files it depends on
+func runWKTGenerator(t *testing.T, file *descriptorpb.FileDescriptorProto) map[string]string {
+	t.Helper()
+
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{file.GetName()},
+		Parameter:      proto.String("paths=source_relative"),
+		ProtoFile: []*descriptorpb.FileDescriptorProto{
+			protodesc.ToFileDescriptorProto(timestamppb.File_google_protobuf_timestamp_proto),
+			protodesc.ToFileDescriptorProto(durationpb.File_google_protobuf_duration_proto),
+			protodesc.ToFileDescriptorProto(wrapperspb.File_google_protobuf_wrappers_proto),
+			file,
+		},
+	}
+	gen, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatalf("protogen.Options.New() failed: %v", err)
+	}
+	if err := GenerateFiles(gen); err != nil {
+		t.Fatalf("GenerateFiles() failed: %v", err)
+	}
+
+	files := make(map[string]string)
+	for _, f := range gen.Response().File {
+		files[f.GetName()] = f.GetContent()
+	}
+	return files
+}
+
+func TestGenerateNativeWKT(t *testing.T) {
+	files := runWKTGenerator(t, wktFile(&protogo_values.FileOptions{NativeWkt: proto.Bool(true)}))
+	content := files["mirror"+FileSuffix]
+
+	expected := []string{
+		"CreatedAt   time.Time",
+		"Ttl         time.Duration",
+		"Retries     *int64",
+		"Payload     []byte",
+		"RawDeadline *timestamppb.Timestamp",
+		"m.CreatedAt = x.CreatedAt.AsTime()",
+		"x.CreatedAt = timestamppb.New(m.CreatedAt)",
+		"m.Ttl = x.Ttl.AsDuration()",
+		"x.Ttl = durationpb.New(m.Ttl)",
+		"x.Retries = wrapperspb.Int64(*m.Retries)",
+		"x.Payload = wrapperspb.Bytes(m.Payload)",
+		"x.RawDeadline = m.RawDeadline",
+	}
+	for _, want := range expected {
+		if !strings.Contains(content, want) {
+			t.Errorf("expected generated code to contain %q:\n%s", want, content)
+		}
+	}
+}
+
+func TestGenerateNativeWKTFieldLevel(t *testing.T) {
+	file := wktFile(nil)
+	file.MessageType[0].Field[0].Options = fieldOpts(&protogo_values.FieldOptions{NativeWkt: proto.Bool(true)})
+
+	content := runWKTGenerator(t, file)["mirror"+FileSuffix]
+
+	if !strings.Contains(content, "CreatedAt   time.Time") {
+		t.Errorf("expected annotated field to be mapped:\n%s", content)
+	}
+	if !strings.Contains(content, "Ttl         *durationpb.Duration") {
+		t.Errorf("expected unannotated field to keep its proto type:\n%s", content)
+	}
+}
+
+func TestGenerateNativeWKTDisabled(t *testing.T) {
+	files := runWKTGenerator(t, wktFile(nil))
+	if len(files) != 0 {
+		t.Errorf("expected no sibling files without native_wkt, got %v", files)
+	}
+}
+
+func TestWKTMappingsCoverParser(t *testing.T) {
+	for name := range wktMappings {
+		mapping := wktMappings[name]
+		if mapping.goType == nil || mapping.toNative == nil || mapping.toProto == nil {
+			t.Errorf("incomplete mapping for %s", name)
+		}
+	}
+	if len(wktMappings) != 11 {
+		t.Errorf("expected 11 well-known type mappings, got %d", len(wktMappings))
+	}
+}
@@ -0,0 +1,628 @@
+// Package migrate rewrites Go code that uses repeated message fields whose
+// value_slice option was turned on or off, so that it compiles against the
+// regenerated code and keeps its meaning where it can.
+//
+// Turning value_slice on changes a field from []*T to []T; turning it off
+// changes it back. Uses of the field and of its getter are rewritten by
+// what they do with it: elements get an & or a * added or removed, range
+// loops index the slice, appended values and slice literals are converted.
+// Uses that cannot be rewritten safely, such as passing the whole slice to
+// a function, are reported for migration by hand.
+package migrate
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/packages"
+)
+
+// Edit describes a change made at a use of a toggled field, or a use that
+// has to be migrated by hand
+type Edit struct {
+	Pos     token.Position
+	Message string
+}
+
+// Result is the outcome of a migration
+type Result struct {
+	// Edits are the changes made, in file order
+	Edits []Edit
+	// Manual are the uses that could not be rewritten, in file order
+	Manual []Edit
+	// Files maps the path of each changed file to its new content
+	Files map[string][]byte
+}
+
+// Migrate loads the packages matching patterns from dir and rewrites their
+// uses of the toggled fields. Files are not written; their new content is
+// returned in the result.
+func Migrate(dir string, patterns []string, toggles []Toggle) (*Result, error) {
+	result := &Result{Files: make(map[string][]byte)}
+	if len(toggles) == 0 {
+		return result, nil
+	}
+
+	// Type-check from source, as the code may not compile against the
+	// regenerated package until it is migrated; type errors are expected
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedSyntax |
+			packages.NeedTypes | packages.NeedTypesInfo | packages.NeedImports | packages.NeedDeps,
+		Dir:   dir,
+		Tests: true,
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load packages: %w", err)
+	}
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("no packages match %v", patterns)
+	}
+
+	// Test variants of a package share its files; each is migrated once
+	done := make(map[string]bool)
+	for _, pkg := range pkgs {
+		if pkg.TypesInfo == nil {
+			return nil, fmt.Errorf("failed to load package %s: %v", pkg.PkgPath, pkg.Errors)
+		}
+		for _, file := range pkg.Syntax {
+			name := pkg.Fset.File(file.Pos()).Name()
+			if done[name] || ast.IsGenerated(file) {
+				continue
+			}
+			done[name] = true
+			src, err := os.ReadFile(name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", name, err)
+			}
+			m := &fileMigration{
+				fset:    pkg.Fset,
+				info:    pkg.TypesInfo,
+				src:     src,
+				file:    pkg.Fset.File(file.Pos()),
+				toggles: toggles,
+				result:  result,
+			}
+			m.migrate(file)
+			if len(m.edits) > 0 {
+				result.Files[name] = m.apply()
+			}
+		}
+	}
+
+	byPosition := func(edits []Edit) {
+		sort.SliceStable(edits, func(i, j int) bool {
+			a, b := edits[i].Pos, edits[j].Pos
+			if a.Filename != b.Filename {
+				return a.Filename < b.Filename
+			}
+			return a.Offset < b.Offset
+		})
+	}
+	byPosition(result.Edits)
+	byPosition(result.Manual)
+	return result, nil
+}
+
+// WriteSummary prints the edits and the uses left for migration by hand,
+// with file names relative to dir
+func (r *Result) WriteSummary(w io.Writer, dir string) {
+	position := func(pos token.Position) string {
+		if rel, err := filepath.Rel(dir, pos.Filename); err == nil {
+			pos.Filename = rel
+		}
+		return pos.String()
+	}
+	for _, edit := range r.Edits {
+		fmt.Fprintf(w, "%s: %s\n", position(edit.Pos), edit.Message)
+	}
+	fmt.Fprintf(w, "%d %s in %d %s\n", len(r.Edits), plural(len(r.Edits), "edit", "edits"), len(r.Files), plural(len(r.Files), "file", "files"))
+	if len(r.Manual) == 0 {
+		return
+	}
+	for _, edit := range r.Manual {
+		fmt.Fprintf(w, "%s: %s\n", position(edit.Pos), edit.Message)
+	}
+	fmt.Fprintf(w, "%d %s to migrate by hand\n", len(r.Manual), plural(len(r.Manual), "use", "uses"))
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+// textEdit replaces the source between two offsets
+type textEdit struct {
+	start, end int
+	text       string
+}
+
+// fileMigration rewrites the uses of toggled fields in one file
+type fileMigration struct {
+	fset    *token.FileSet
+	info    *types.Info
+	src     []byte
+	file    *token.File
+	toggles []Toggle
+	result  *Result
+	edits   []textEdit
+}
+
+func (m *fileMigration) migrate(file *ast.File) {
+	in := inspector.New([]*ast.File{file})
+	filter := []ast.Node{(*ast.SelectorExpr)(nil), (*ast.CallExpr)(nil), (*ast.CompositeLit)(nil)}
+	in.WithStack(filter, func(n ast.Node, push bool, stack []ast.Node) bool {
+		if !push {
+			return true
+		}
+		parents := stack[:len(stack)-1]
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			if t, ok := m.fieldUse(n); ok {
+				m.use(n, t, parents)
+			}
+		case *ast.CallExpr:
+			if t, ok := m.getterUse(n); ok {
+				m.use(n, t, parents)
+			}
+		case *ast.CompositeLit:
+			m.structLiteral(n)
+		}
+		return true
+	})
+}
+
+// toggleOf returns the toggle for the named field of the struct recv, or a
+// pointer to it
+func (m *fileMigration) toggleOf(recv types.Type, field string) (*Toggle, bool) {
+	if ptr, ok := recv.(*types.Pointer); ok {
+		recv = ptr.Elem()
+	}
+	named, ok := types.Unalias(recv).(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return nil, false
+	}
+	for i := range m.toggles {
+		t := &m.toggles[i]
+		if t.Field == field && t.Struct == named.Obj().Name() && t.ImportPath == named.Obj().Pkg().Path() {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// fieldUse reports whether sel selects a toggled field
+func (m *fileMigration) fieldUse(sel *ast.SelectorExpr) (*Toggle, bool) {
+	selection, ok := m.info.Selections[sel]
+	if !ok || selection.Kind() != types.FieldVal {
+		return nil, false
+	}
+	return m.toggleOf(selection.Recv(), sel.Sel.Name)
+}
+
+// getterUse reports whether call calls the getter of a toggled field
+func (m *fileMigration) getterUse(call *ast.CallExpr) (*Toggle, bool) {
+	sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+	if !ok || len(call.Args) != 0 || len(sel.Sel.Name) <= len("Get") || sel.Sel.Name[:len("Get")] != "Get" {
+		return nil, false
+	}
+	selection, ok := m.info.Selections[sel]
+	if !ok || selection.Kind() != types.MethodVal {
+		return nil, false
+	}
+	return m.toggleOf(selection.Recv(), sel.Sel.Name[len("Get"):])
+}
+
+// structLiteral converts the values given to toggled fields in a literal of
+// their message struct
+func (m *fileMigration) structLiteral(lit *ast.CompositeLit) {
+	t := m.info.TypeOf(lit)
+	if t == nil {
+		return
+	}
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		key, ok := kv.Key.(*ast.Ident)
+		if !ok {
+			continue
+		}
+		if toggle, ok := m.toggleOf(t, key.Name); ok {
+			m.sliceValue(kv.Value, toggle, key.Name)
+		}
+	}
+}
+
+// use rewrites a use of a toggled field, or of its getter, by what its
+// parents do with it
+func (m *fileMigration) use(use ast.Expr, t *Toggle, parents []ast.Node) {
+	child, parent, parents := enclosing(use, parents)
+	switch p := parent.(type) {
+	case *ast.IndexExpr:
+		if p.X == child {
+			m.element(p, t, parents)
+			return
+		}
+	case *ast.RangeStmt:
+		if p.X == child {
+			m.rangeLoop(p, use, t)
+			return
+		}
+	case *ast.CallExpr:
+		if m.builtin(p, "len") || m.builtin(p, "cap") {
+			return
+		}
+		if m.builtin(p, "append") && p.Args[0] == child {
+			m.appendArgs(p, t)
+			return
+		}
+	case *ast.BinaryExpr:
+		if isNil(p.X) || isNil(p.Y) {
+			return
+		}
+	case *ast.AssignStmt:
+		if i := indexOf(p.Lhs, child); i >= 0 && len(p.Lhs) == len(p.Rhs) {
+			m.sliceValue(p.Rhs[i], t, m.text(use))
+			return
+		}
+	}
+	m.manual(use, "%s is used as a whole; its type changes from %s, so migrate it by hand", m.text(use), t.change())
+}
+
+// change describes how the type of a toggled field changes
+func (t *Toggle) change() string {
+	if t.ValueSlice {
+		return fmt.Sprintf("[]*%s to []%s", t.Elem, t.Elem)
+	}
+	return fmt.Sprintf("[]%s to []*%s", t.Elem, t.Elem)
+}
+
+// element rewrites the use of an element of a toggled field so that it
+// keeps its type: *T when the field becomes a value slice, T otherwise
+func (m *fileMigration) element(el *ast.IndexExpr, t *Toggle, parents []ast.Node) {
+	child, parent, _ := enclosing(el, parents)
+	switch p := parent.(type) {
+	case *ast.SelectorExpr:
+		// Fields and methods are selected the same way on T and *T
+		return
+	case *ast.StarExpr:
+		if t.ValueSlice {
+			m.replace(p.Pos(), child.Pos(), "", el, "dropped the dereference of "+m.text(el))
+			return
+		}
+	case *ast.UnaryExpr:
+		if p.Op == token.AND && !t.ValueSlice {
+			m.replace(p.Pos(), child.Pos(), "", el, "dropped the address of "+m.text(el)+", now a pointer")
+			return
+		}
+		if p.Op == token.AND {
+			m.manual(el, "the address of %s changes type; migrate it by hand", m.text(el))
+			return
+		}
+	case *ast.AssignStmt:
+		if i := indexOf(p.Lhs, child); i >= 0 {
+			if p.Tok == token.ASSIGN && len(p.Lhs) == len(p.Rhs) {
+				m.elementValue(p.Rhs[i], t, "assigned to "+m.text(el))
+			} else {
+				m.manual(el, "%s is assigned a value of the old element type; migrate it by hand", m.text(el))
+			}
+			return
+		}
+	}
+	if t.ValueSlice {
+		m.insert(el.Pos(), "&", el, "took the address of "+m.text(el))
+	} else {
+		m.insert(el.Pos(), "*", el, "dereferenced "+m.text(el))
+	}
+}
+
+// elementValue converts a value stored in a toggled field, of the old
+// element type, to the new one
+func (m *fileMigration) elementValue(v ast.Expr, t *Toggle, what string) {
+	v = ast.Unparen(v)
+	if t.ValueSlice {
+		if addr, ok := v.(*ast.UnaryExpr); ok && addr.Op == token.AND {
+			m.replace(addr.Pos(), addr.X.Pos(), "", v, "dropped the address of "+m.text(addr.X)+", "+what)
+			return
+		}
+		if isNil(v) {
+			m.manual(v, "nil is %s, which cannot hold it any more; migrate it by hand", what)
+			return
+		}
+		m.deref(v, what)
+		return
+	}
+	if lit, ok := v.(*ast.CompositeLit); ok && lit.Type != nil {
+		m.insert(v.Pos(), "&", v, "took the address of "+m.text(v)+", "+what)
+		return
+	}
+	m.manual(v, "%s is %s; store a pointer to a copy of it by hand", m.text(v), what)
+}
+
+// deref dereferences v, parenthesising it unless it is an operand
+func (m *fileMigration) deref(v ast.Expr, what string) {
+	switch v.(type) {
+	case *ast.Ident, *ast.SelectorExpr, *ast.CallExpr, *ast.IndexExpr, *ast.ParenExpr:
+		m.insert(v.Pos(), "*", v, "dereferenced "+m.text(v)+", "+what)
+	default:
+		m.change(v, "dereferenced "+m.text(v)+", "+what,
+			textEdit{start: m.offset(v.Pos()), end: m.offset(v.Pos()), text: "*("},
+			textEdit{start: m.offset(v.End()), end: m.offset(v.End()), text: ")"})
+	}
+}
+
+// appendArgs converts the values appended to a toggled field
+func (m *fileMigration) appendArgs(call *ast.CallExpr, t *Toggle) {
+	if call.Ellipsis.IsValid() {
+		m.manual(call, "%s appends a whole slice; convert it by hand", m.text(call))
+		return
+	}
+	for _, arg := range call.Args[1:] {
+		m.elementValue(arg, t, "appended to "+m.text(call.Args[0]))
+	}
+}
+
+// sliceValue converts a whole slice stored in a toggled field: nil, a
+// result of append to the same field, a slice literal or a make call
+func (m *fileMigration) sliceValue(v ast.Expr, t *Toggle, field string) {
+	v = ast.Unparen(v)
+	switch v := v.(type) {
+	case *ast.Ident:
+		if isNil(v) {
+			return
+		}
+	case *ast.CallExpr:
+		if m.builtin(v, "append") {
+			if _, ok := m.fieldOrGetter(v.Args[0]); ok {
+				return // the use inside the call is rewritten on its own
+			}
+		}
+		if m.builtin(v, "make") {
+			if array, ok := v.Args[0].(*ast.ArrayType); ok && array.Len == nil {
+				m.sliceType(array, t, field)
+				return
+			}
+		}
+	case *ast.CompositeLit:
+		if array, ok := v.Type.(*ast.ArrayType); ok && array.Len == nil {
+			m.sliceType(array, t, field)
+			for _, elt := range v.Elts {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					elt = kv.Value
+				}
+				if lit, ok := elt.(*ast.CompositeLit); ok && lit.Type == nil {
+					continue // an elided literal suits either element type
+				}
+				m.elementValue(elt, t, "an element of "+field)
+			}
+			return
+		}
+	}
+	m.manual(v, "%s is stored in %s, whose type changes from %s; convert it by hand", m.text(v), field, t.change())
+}
+
+// sliceType converts the element type of a slice type expression
+func (m *fileMigration) sliceType(array *ast.ArrayType, t *Toggle, field string) {
+	if t.ValueSlice {
+		if star, ok := array.Elt.(*ast.StarExpr); ok {
+			m.replace(star.Pos(), star.X.Pos(), "", array, "made "+m.text(array)+" a value slice for "+field)
+		}
+		return
+	}
+	m.insert(array.Elt.Pos(), "*", array, "made "+m.text(array)+" a pointer slice for "+field)
+}
+
+// rangeLoop keeps the type of the value variable of a range loop over a
+// toggled field: a value slice is ranged over by index, taking the address
+// of each element, and a pointer slice dereferences each element
+func (m *fileMigration) rangeLoop(loop *ast.RangeStmt, use ast.Expr, t *Toggle) {
+	if loop.Value == nil || isNil(loop.Value) || isBlankIdent(loop.Value) {
+		return
+	}
+	value, ok := loop.Value.(*ast.Ident)
+	if !ok || loop.Tok != token.DEFINE {
+		m.manual(loop, "range over %s assigns to existing variables; migrate it by hand", m.text(use))
+		return
+	}
+	body := m.offset(loop.Body.Lbrace) + 1
+	if !t.ValueSlice {
+		m.change(loop, "dereferenced each element of "+m.text(use)+" in the range loop",
+			textEdit{start: body, end: body, text: fmt.Sprintf("\n%s := *%s", value.Name, value.Name)})
+		return
+	}
+	if !repeatable(use) {
+		m.manual(loop, "range over %s copies each element; index the slice by hand", m.text(use))
+		return
+	}
+
+	index := ""
+	var header textEdit
+	if key, ok := loop.Key.(*ast.Ident); ok && key.Name != "_" {
+		index = key.Name
+		header = textEdit{start: m.offset(key.End()), end: m.offset(value.End())}
+	} else {
+		index = m.freshName(loop)
+		if index == "" {
+			m.manual(loop, "range over %s copies each element; index the slice by hand", m.text(use))
+			return
+		}
+		header = textEdit{start: m.offset(loop.Key.Pos()), end: m.offset(value.End()), text: index}
+	}
+	m.change(loop, "ranged over the indexes of "+m.text(use), header,
+		textEdit{start: body, end: body, text: fmt.Sprintf("\n%s := &%s[%s]", value.Name, m.text(use), index)})
+}
+
+// freshName returns a name for an index variable that neither shadows nor
+// is shadowed by a name used in the loop
+func (m *fileMigration) freshName(loop *ast.RangeStmt) string {
+	scope := m.info.Scopes[loop]
+	for _, name := range []string{"i", "j", "k", "idx"} {
+		if scope != nil {
+			if _, obj := scope.LookupParent(name, loop.Body.Lbrace); obj != nil {
+				continue
+			}
+		}
+		if !usesName(loop.Body, name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// fieldOrGetter reports whether e uses a toggled field or its getter
+func (m *fileMigration) fieldOrGetter(e ast.Expr) (*Toggle, bool) {
+	switch e := ast.Unparen(e).(type) {
+	case *ast.SelectorExpr:
+		return m.fieldUse(e)
+	case *ast.CallExpr:
+		return m.getterUse(e)
+	}
+	return nil, false
+}
+
+// builtin reports whether call calls the named built-in function
+func (m *fileMigration) builtin(call *ast.CallExpr, name string) bool {
+	id, ok := ast.Unparen(call.Fun).(*ast.Ident)
+	if !ok || id.Name != name {
+		return false
+	}
+	_, ok = m.info.Uses[id].(*types.Builtin)
+	return ok && len(call.Args) > 0
+}
+
+func (m *fileMigration) offset(pos token.Pos) int {
+	return m.file.Offset(pos)
+}
+
+// text returns the source of n
+func (m *fileMigration) text(n ast.Node) string {
+	return string(m.src[m.offset(n.Pos()):m.offset(n.End())])
+}
+
+// change makes the text edits of one change and reports it at n. If any of
+// them overlaps an edit already made, or starts where one does, the two
+// cannot both apply: the change is not made and n is left to migrate by
+// hand instead.
+func (m *fileMigration) change(n ast.Node, message string, edits ...textEdit) {
+	for _, edit := range edits {
+		for _, made := range m.edits {
+			if edit.start == made.start || (edit.start < made.end && made.start < edit.end) {
+				m.manual(n, "%s conflicts with another edit there, so it was not made: %s; migrate it by hand", m.text(n), message)
+				return
+			}
+		}
+	}
+	m.edits = append(m.edits, edits...)
+	m.result.Edits = append(m.result.Edits, Edit{
+		Pos:     m.fset.Position(n.Pos()),
+		Message: message,
+	})
+}
+
+// insert adds text at pos and reports the edit at n
+func (m *fileMigration) insert(pos token.Pos, text string, n ast.Node, message string) {
+	m.replace(pos, pos, text, n, message)
+}
+
+// replace replaces the source between two positions and reports the edit
+// at n
+func (m *fileMigration) replace(start, end token.Pos, text string, n ast.Node, message string) {
+	m.change(n, message, textEdit{start: m.offset(start), end: m.offset(end), text: text})
+}
+
+func (m *fileMigration) manual(n ast.Node, format string, args ...any) {
+	m.result.Manual = append(m.result.Manual, Edit{
+		Pos:     m.fset.Position(n.Pos()),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// apply returns the source with the edits applied, formatted if it parses.
+// change keeps the edits from overlapping.
+func (m *fileMigration) apply() []byte {
+	sort.SliceStable(m.edits, func(i, j int) bool { return m.edits[i].start < m.edits[j].start })
+	var out bytes.Buffer
+	last := 0
+	for _, edit := range m.edits {
+		out.Write(m.src[last:edit.start])
+		out.WriteString(edit.text)
+		last = edit.end
+	}
+	out.Write(m.src[last:])
+	if formatted, err := format.Source(out.Bytes()); err == nil {
+		return formatted
+	}
+	return out.Bytes()
+}
+
+// enclosing returns the outermost parenthesised form of n among its
+// parents, the node enclosing that, and the parents of the latter
+func enclosing(n ast.Node, parents []ast.Node) (child, parent ast.Node, rest []ast.Node) {
+	child = n
+	for i := len(parents) - 1; i >= 0; i-- {
+		if _, ok := parents[i].(*ast.ParenExpr); ok {
+			child = parents[i]
+			continue
+		}
+		return child, parents[i], parents[:i]
+	}
+	return child, nil, nil
+}
+
+func indexOf(exprs []ast.Expr, n ast.Node) int {
+	for i, e := range exprs {
+		if e == n {
+			return i
+		}
+	}
+	return -1
+}
+
+func isNil(e ast.Expr) bool {
+	id, ok := ast.Unparen(e).(*ast.Ident)
+	return ok && id.Name == "nil"
+}
+
+func isBlankIdent(e ast.Expr) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && id.Name == "_"
+}
+
+// repeatable reports whether e can be evaluated again without effects: a
+// variable, a chain of field selections, or a getter call on one
+func repeatable(e ast.Expr) bool {
+	switch e := ast.Unparen(e).(type) {
+	case *ast.Ident:
+		return true
+	case *ast.SelectorExpr:
+		return repeatable(e.X)
+	case *ast.CallExpr:
+		return len(e.Args) == 0 && repeatable(e.Fun)
+	}
+	return false
+}
+
+// usesName reports whether an identifier called name occurs in n
+func usesName(n ast.Node, name string) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Name == name {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,115 @@
+package migrate
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// copyTree copies a testdata module into a temporary directory
+func copyTree(t *testing.T, src string) string {
+	t.Helper()
+	dst := t.TempDir()
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		if d.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0o755)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dst, rel), data, 0o644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dst
+}
+
+// TestMigrate migrates each testdata module, compares the changed files and
+// the summary with the .golden files, then checks that the migrated code
+// builds against the regenerated package in pb/shop.pb.go.after
+func TestMigrate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("loads packages with the go command")
+	}
+	tests := []struct {
+		dir        string
+		valueSlice bool
+	}{
+		{dir: "on", valueSlice: true},
+		{dir: "off", valueSlice: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dir, func(t *testing.T) {
+			src := filepath.Join("testdata", tt.dir)
+			dir := copyTree(t, src)
+			toggles := []Toggle{{ImportPath: "example.com/shop/pb", Struct: "Team", Field: "Users", Elem: "User", ValueSlice: tt.valueSlice}}
+
+			result, err := Migrate(dir, []string{"./..."}, toggles)
+			if err != nil {
+				t.Fatalf("Migrate() failed: %v", err)
+			}
+
+			goldens, err := filepath.Glob(filepath.Join(src, "*", "*.go.golden"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Files) != len(goldens) {
+				t.Errorf("Migrate() changed %d files, want %d", len(result.Files), len(goldens))
+			}
+			for _, golden := range goldens {
+				rel, _ := filepath.Rel(src, strings.TrimSuffix(golden, ".golden"))
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, ok := result.Files[filepath.Join(dir, rel)]
+				if !ok {
+					t.Errorf("Migrate() left %s unchanged", rel)
+					continue
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("Migrate() rewrote %s as:\n%s\nwant:\n%s", rel, got, want)
+				}
+				if err := os.WriteFile(filepath.Join(dir, rel), got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var summary bytes.Buffer
+			result.WriteSummary(&summary, dir)
+			want, err := os.ReadFile(filepath.Join(src, "summary.golden"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if summary.String() != string(want) {
+				t.Errorf("summary:\n%s\nwant:\n%s", summary.String(), want)
+			}
+
+			// Regenerate, and build what was migrated
+			after, err := os.ReadFile(filepath.Join(dir, "pb", "shop.pb.go.after"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "pb", "shop.pb.go"), after, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			build := exec.Command("go", "build", "./use")
+			build.Dir = dir
+			build.Env = append(os.Environ(), "GOFLAGS=", "GOWORK=off")
+			if out, err := build.CombinedOutput(); err != nil {
+				t.Errorf("migrated code does not build against the regenerated package: %v\n%s", err, out)
+			}
+		})
+	}
+}
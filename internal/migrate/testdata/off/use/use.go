@@ -0,0 +1,27 @@
+package use
+
+import "example.com/shop/pb"
+
+func First(t *pb.Team) pb.User {
+	return t.Users[0]
+}
+
+func Ptr(t *pb.Team) *pb.User {
+	return &t.Users[0]
+}
+
+func Names(t *pb.Team) []string {
+	var names []string
+	for _, u := range t.GetUsers() {
+		names = append(names, u.Name)
+	}
+	return names
+}
+
+func Add(t *pb.Team) {
+	t.Users = append(t.Users, pb.User{Name: "new"})
+}
+
+func New() *pb.Team {
+	return &pb.Team{Users: []pb.User{{Name: "a"}, pb.User{Name: "b"}}}
+}
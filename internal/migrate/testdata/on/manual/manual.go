@@ -0,0 +1,18 @@
+package manual
+
+import "example.com/shop/pb"
+
+func Users(t *pb.Team) []*pb.User {
+	return t.Users
+}
+
+func Set(t *pb.Team, users []*pb.User) {
+	t.Users = users
+	t.Users[0] = nil
+}
+
+// The element keeps its old type and is then converted to the new one, two
+// edits at the same place
+func Duplicate(t *pb.Team) {
+	t.Users = append(t.Users, t.Users[0])
+}
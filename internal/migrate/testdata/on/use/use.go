@@ -0,0 +1,49 @@
+package use
+
+import "example.com/shop/pb"
+
+func First(t *pb.Team) *pb.User {
+	return t.Users[0]
+}
+
+func Copy(t *pb.Team) pb.User {
+	return *t.Users[0]
+}
+
+func Name(t *pb.Team, i int) string {
+	return t.Users[i].Name
+}
+
+func Names(t *pb.Team) []string {
+	var names []string
+	for _, u := range t.GetUsers() {
+		names = append(names, u.GetName())
+	}
+	for n, u := range t.Users {
+		names[n] = u.Name
+	}
+	return names
+}
+
+func Count(t *pb.Team) int {
+	if t.Users == nil {
+		return 0
+	}
+	return len(t.GetUsers())
+}
+
+func Add(t *pb.Team, u *pb.User) {
+	t.Users = append(t.Users, u, &pb.User{Name: "new"})
+}
+
+func Replace(t *pb.Team, u *pb.User) {
+	t.Users[0] = u
+}
+
+func New() *pb.Team {
+	return &pb.Team{Users: []*pb.User{{Name: "a"}, &pb.User{Name: "b"}}}
+}
+
+func Reset(t *pb.Team) {
+	t.Users = make([]*pb.User, 0, 4)
+}
@@ -0,0 +1,168 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+
+	"github.com/benjamin-rood/protogo-values/internal/parser"
+	"github.com/benjamin-rood/protogo-values/internal/parser/types"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// Toggle is a repeated message field whose value_slice option differs
+// between two versions of its proto file
+type Toggle struct {
+	// ImportPath is the Go import path of the package generated for the
+	// field's file
+	ImportPath string
+	// Struct and Field are the Go names of the message struct and the field
+	Struct string
+	Field  string
+	// Elem is the Go name of the field's element message
+	Elem string
+	// ValueSlice reports whether the field becomes a value slice, []T, as
+	// opposed to a pointer slice, []*T
+	ValueSlice bool
+}
+
+// ReadDescriptorSet reads a binary FileDescriptorSet, as written by
+// protoc --descriptor_set_out or buf build -o
+func ReadDescriptorSet(path string) (*descriptorpb.FileDescriptorSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read descriptor set: %w", err)
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(data, set); err != nil {
+		return nil, fmt.Errorf("failed to parse descriptor set %s: %w", path, err)
+	}
+	return set, nil
+}
+
+// Toggles compares two descriptor sets and returns the repeated message
+// fields present in both whose value_slice option changed, sorted by Go
+// import path, struct and field. Fields under value_slice_policy AUTO are
+// decided with autoMaxBytes, as the plugin's auto_max_bytes parameter would.
+// Both sets must include their imports.
+func Toggles(before, after *descriptorpb.FileDescriptorSet, autoMaxBytes int) ([]Toggle, error) {
+	old, err := valueSlices(before, autoMaxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("before: %w", err)
+	}
+	current, err := goFields(after, autoMaxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("after: %w", err)
+	}
+
+	var toggles []Toggle
+	for name, field := range current {
+		was, ok := old[name]
+		if !ok || was == field.valueSlice {
+			continue
+		}
+		toggles = append(toggles, Toggle{
+			ImportPath: string(field.message.GoIdent.GoImportPath),
+			Struct:     field.message.GoIdent.GoName,
+			Field:      field.field.GoName,
+			Elem:       field.field.Message.GoIdent.GoName,
+			ValueSlice: field.valueSlice,
+		})
+	}
+	sort.Slice(toggles, func(i, j int) bool {
+		a, b := toggles[i], toggles[j]
+		if a.ImportPath != b.ImportPath {
+			return a.ImportPath < b.ImportPath
+		}
+		if a.Struct != b.Struct {
+			return a.Struct < b.Struct
+		}
+		return a.Field < b.Field
+	})
+	return toggles, nil
+}
+
+// goField is a repeated message field with its Go names
+type goField struct {
+	message    *protogen.Message
+	field      *protogen.Field
+	valueSlice bool
+}
+
+// goFields returns the repeated message fields of set by full name. The set
+// is resolved as the plugin resolves a request: value_slice_policy AUTO is
+// decided first, and FindAnnotatedFields then picks the fields the
+// transformer rewrites, so exactly the messages the plugin processes count.
+func goFields(set *descriptorpb.FileDescriptorSet, autoMaxBytes int) (map[protoreflect.FullName]goField, error) {
+	// Deciding AUTO rewrites field options, so work on a copy
+	req := &pluginpb.CodeGeneratorRequest{ProtoFile: proto.Clone(set).(*descriptorpb.FileDescriptorSet).File}
+	if _, err := parser.ApplyValueSlicePolicy(req, autoMaxBytes); err != nil {
+		return nil, err
+	}
+	annotated, err := parser.FindAnnotatedFields(req)
+	if err != nil {
+		return nil, err
+	}
+	gen, err := protogen.Options{}.New(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve Go names (is go_package set, and were imports included?): %w", err)
+	}
+
+	fields := make(map[protoreflect.FullName]goField)
+	var walk func(messages []*protogen.Message)
+	walk = func(messages []*protogen.Message) {
+		for _, message := range messages {
+			for _, field := range message.Fields {
+				if !field.Desc.IsList() || field.Message == nil {
+					continue
+				}
+				fields[field.Desc.FullName()] = goField{
+					message:    message,
+					field:      field,
+					valueSlice: rewritten(annotated, field),
+				}
+			}
+			walk(message.Messages)
+		}
+	}
+	for _, file := range gen.Files {
+		walk(file.Messages)
+	}
+	return fields, nil
+}
+
+// rewritten reports whether the transformer makes field a value slice: its
+// Go name is annotated, with its element type among those recorded for the
+// name, if any are
+func rewritten(annotated *types.AnnotatedFields, field *protogen.Field) bool {
+	if !annotated.Contains(field.GoName) {
+		return false
+	}
+	elems, ok := annotated.ElemTypes(field.GoName)
+	if !ok {
+		return true
+	}
+	return slices.Contains(elems, types.GoIdent{
+		ImportPath: string(field.Message.GoIdent.GoImportPath),
+		Name:       field.Message.GoIdent.GoName,
+	})
+}
+
+// valueSlices reports by full name whether each repeated message field of
+// set is a value slice
+func valueSlices(set *descriptorpb.FileDescriptorSet, autoMaxBytes int) (map[protoreflect.FullName]bool, error) {
+	fields, err := goFields(set, autoMaxBytes)
+	if err != nil {
+		return nil, err
+	}
+	valueSlices := make(map[protoreflect.FullName]bool, len(fields))
+	for name, field := range fields {
+		valueSlices[name] = field.valueSlice
+	}
+	return valueSlices, nil
+}
@@ -0,0 +1,106 @@
+package migrate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/benjamin-rood/protogo-values/internal/parser"
+	"github.com/benjamin-rood/protogo-values/proto/protogo_values"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// shopSet returns a descriptor set for a Team message, with a nested Squad,
+// whose repeated User fields are value slices as listed
+func shopSet(valueSlices map[string]bool) *descriptorpb.FileDescriptorSet {
+	repeated := func(name string, number int32) *descriptorpb.FieldDescriptorProto {
+		field := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			Number:   proto.Int32(number),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+			TypeName: proto.String(".shop.User"),
+			JsonName: proto.String(name),
+		}
+		if valueSlices[name] {
+			field.Options = &descriptorpb.FieldOptions{}
+			proto.SetExtension(field.Options, protogo_values.E_ValueSlice, true)
+		}
+		return field
+	}
+	return &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:    proto.String("shop.proto"),
+		Package: proto.String("shop"),
+		Syntax:  proto.String("proto3"),
+		Options: &descriptorpb.FileOptions{GoPackage: proto.String("example.com/shop/pb")},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("User")},
+			{
+				Name:  proto.String("Team"),
+				Field: []*descriptorpb.FieldDescriptorProto{repeated("users", 1), repeated("admin_users", 2), repeated("guests", 3)},
+				NestedType: []*descriptorpb.DescriptorProto{{
+					Name:  proto.String("Squad"),
+					Field: []*descriptorpb.FieldDescriptorProto{repeated("members", 1)},
+				}},
+			},
+		},
+	}}}
+}
+
+func TestToggles(t *testing.T) {
+	before := shopSet(map[string]bool{"admin_users": true, "guests": true})
+	after := shopSet(map[string]bool{"users": true, "guests": true})
+
+	got, err := Toggles(before, after, parser.DefaultAutoMaxBytes)
+	if err != nil {
+		t.Fatalf("Toggles() failed: %v", err)
+	}
+	want := []Toggle{
+		{ImportPath: "example.com/shop/pb", Struct: "Team", Field: "AdminUsers", Elem: "User", ValueSlice: false},
+		{ImportPath: "example.com/shop/pb", Struct: "Team", Field: "Users", Elem: "User", ValueSlice: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Toggles() = %+v, want %+v", got, want)
+	}
+
+	// Fields of nested messages are toggled like top-level ones
+	got, err = Toggles(after, shopSet(map[string]bool{"users": true, "guests": true, "members": true}), parser.DefaultAutoMaxBytes)
+	if err != nil {
+		t.Fatalf("Toggles() failed: %v", err)
+	}
+	want = []Toggle{{ImportPath: "example.com/shop/pb", Struct: "Team_Squad", Field: "Members", Elem: "User", ValueSlice: true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Toggles() in a nested message = %+v, want %+v", got, want)
+	}
+
+	// Under a file-level AUTO policy, fields of the small User become value
+	// slices, in nested messages too
+	fileOpts := &descriptorpb.FileOptions{GoPackage: proto.String("example.com/shop/pb")}
+	proto.SetExtension(fileOpts, protogo_values.E_FileOpts, &protogo_values.FileOptions{
+		ValueSlicePolicy: protogo_values.ValueSlicePolicy_VALUE_SLICE_POLICY_AUTO.Enum(),
+	})
+	auto := shopSet(map[string]bool{"guests": true})
+	auto.File[0].Options = fileOpts
+	got, err = Toggles(before, auto, parser.DefaultAutoMaxBytes)
+	if err != nil {
+		t.Fatalf("Toggles() failed: %v", err)
+	}
+	want = []Toggle{
+		{ImportPath: "example.com/shop/pb", Struct: "Team", Field: "Users", Elem: "User", ValueSlice: true},
+		{ImportPath: "example.com/shop/pb", Struct: "Team_Squad", Field: "Members", Elem: "User", ValueSlice: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Toggles() with AUTO = %+v, want %+v", got, want)
+	}
+	// Over the limit, AUTO leaves them pointer slices
+	if got, err := Toggles(before, auto, 16); err != nil || len(got) != 1 || got[0].Field != "AdminUsers" {
+		t.Errorf("Toggles() with AUTO and a 16-byte limit = %+v, %v, want AdminUsers turned off", got, err)
+	}
+
+	// Without a Go package the Go names cannot be resolved
+	before.File[0].Options = nil
+	if _, err := Toggles(before, after, parser.DefaultAutoMaxBytes); err == nil || !strings.Contains(err.Error(), "go_package") {
+		t.Errorf("Toggles() error = %v, want one mentioning go_package", err)
+	}
+}
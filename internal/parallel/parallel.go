@@ -0,0 +1,31 @@
+// Package parallel runs independent per-file work on a bounded pool of
+// goroutines.
+package parallel
+
+import (
+	"runtime"
+	"sync"
+)
+
+// ForEach calls fn(i) for every i in [0, n) using at most GOMAXPROCS
+// goroutines and returns once all calls have finished. Calls may run in any
+// order, so fn must only write state owned by index i; results collected per
+// index are then as deterministic as a sequential loop.
+func ForEach(n int, fn func(i int)) {
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(runtime.GOMAXPROCS(0), n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+	for i := range n {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
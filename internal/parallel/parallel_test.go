@@ -0,0 +1,31 @@
+package parallel
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestForEach(t *testing.T) {
+	const n = 1000
+	results := make([]int, n)
+	var calls atomic.Int64
+	ForEach(n, func(i int) {
+		calls.Add(1)
+		results[i] = i * i
+	})
+
+	if calls.Load() != n {
+		t.Errorf("expected %d calls, got %d", n, calls.Load())
+	}
+	for i, got := range results {
+		if got != i*i {
+			t.Fatalf("results[%d] = %d, want %d", i, got, i*i)
+		}
+	}
+}
+
+func TestForEachEmpty(t *testing.T) {
+	ForEach(0, func(i int) {
+		t.Errorf("fn called with %d for an empty range", i)
+	})
+}
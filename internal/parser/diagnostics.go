@@ -0,0 +1,42 @@
+package parser
+
+import (
+	"errors"
+
+	"github.com/benjamin-rood/protogo-values/internal/diag"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// sourceError attributes an error to the message, field or oneof it
+// concerns, so that the file-level caller can report it at the position of
+// that declaration
+type sourceError struct {
+	desc proto.Message
+	err  error
+}
+
+func (e *sourceError) Error() string {
+	return e.err.Error()
+}
+
+func (e *sourceError) Unwrap() error {
+	return e.err
+}
+
+// at attributes err to the declaration of desc
+func at(desc proto.Message, err error) error {
+	return &sourceError{desc: desc, err: err}
+}
+
+// locate turns err, returned while processing file, into a diagnostic at the
+// declaration it was attributed to, or at the file if it was not
+func locate(file *descriptorpb.FileDescriptorProto, positions map[proto.Message]diag.Position, err error) error {
+	var src *sourceError
+	if errors.As(err, &src) {
+		if pos, ok := positions[src.desc]; ok {
+			return diag.New(pos, src.err)
+		}
+	}
+	return diag.New(diag.Position{File: file.GetName()}, err)
+}
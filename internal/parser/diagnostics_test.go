@@ -0,0 +1,65 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/benjamin-rood/protogo-values/internal/diag"
+	"github.com/benjamin-rood/protogo-values/proto/protogo_values"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func TestFindAnnotatedFieldsPosition(t *testing.T) {
+	opts := &descriptorpb.FieldOptions{}
+	proto.SetExtension(opts, protogo_values.E_FieldOpts, &protogo_values.FieldOptions{GoTags: proto.String(`db:"unterminated`)})
+
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{"order.proto"},
+		ProtoFile: []*descriptorpb.FileDescriptorProto{{
+			Name:    proto.String("order.proto"),
+			Package: proto.String("shop"),
+			Syntax:  proto.String("proto3"),
+			MessageType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("Order"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{
+						Name:   proto.String("id"),
+						Number: proto.Int32(1),
+						Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+						Type:   descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+					},
+					{
+						Name:    proto.String("note"),
+						Number:  proto.Int32(2),
+						Label:   descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+						Type:    descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+						Options: opts,
+					},
+				},
+			}},
+			SourceCodeInfo: &descriptorpb.SourceCodeInfo{
+				Location: []*descriptorpb.SourceCodeInfo_Location{
+					{Path: []int32{4, 0, 2, 1}, Span: []int32{11, 2, 60}},
+				},
+			},
+		}},
+	}
+
+	_, err := FindAnnotatedFields(req)
+	var d *diag.Diagnostic
+	if !errors.As(err, &d) {
+		t.Fatalf("FindAnnotatedFields() error = %v, want a diagnostic", err)
+	}
+	if want := (diag.Position{File: "order.proto", Line: 12, Column: 3}); d.Position != want {
+		t.Errorf("diagnostic position = %+v, want %+v", d.Position, want)
+	}
+
+	// Without SourceCodeInfo the diagnostic still names the file
+	req.ProtoFile[0].SourceCodeInfo = nil
+	_, err = FindAnnotatedFields(req)
+	if !errors.As(err, &d) || d.Position != (diag.Position{File: "order.proto"}) {
+		t.Errorf("FindAnnotatedFields() error = %v, want a diagnostic at order.proto", err)
+	}
+}
@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// addDescriptorSeeds seeds f with the wire encoding of every proto file in
+// testdata, compiled with source info as protoc would send it
+func addDescriptorSeeds(f *testing.F) {
+	paths, err := filepath.Glob("../../testdata/proto/*.proto")
+	if err != nil {
+		f.Fatal(err)
+	}
+	compiler := protocompile.Compiler{
+		Resolver:       protocompile.WithStandardImports(&protocompile.SourceResolver{ImportPaths: []string{"../.."}}),
+		SourceInfoMode: protocompile.SourceInfoStandard,
+	}
+	// Each file is compiled alone, as some fixtures declare the same messages
+	for _, path := range paths {
+		files, err := compiler.Compile(context.Background(), "testdata/proto/"+filepath.Base(path))
+		if err != nil {
+			f.Fatalf("failed to compile %s: %v", path, err)
+		}
+		data, err := proto.Marshal(protodesc.ToFileDescriptorProto(files[0]))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+
+	text, err := os.ReadFile("../../testdata/delegate/field_options_test.txtpb")
+	if err != nil {
+		f.Fatal(err)
+	}
+	file := &descriptorpb.FileDescriptorProto{}
+	if err := prototext.Unmarshal(text, file); err != nil {
+		f.Fatal(err)
+	}
+	data, err := proto.Marshal(file)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(data)
+}
+
+// FuzzFindAnnotatedFields feeds arbitrary file descriptors to
+// FindAnnotatedFields, which must not panic and must reach the same result,
+// or the same error, every time
+func FuzzFindAnnotatedFields(f *testing.F) {
+	addDescriptorSeeds(f)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		file := &descriptorpb.FileDescriptorProto{}
+		if err := proto.Unmarshal(data, file); err != nil {
+			return
+		}
+		request := func() *pluginpb.CodeGeneratorRequest {
+			return &pluginpb.CodeGeneratorRequest{
+				FileToGenerate: []string{file.GetName()},
+				ProtoFile:      []*descriptorpb.FileDescriptorProto{proto.Clone(file).(*descriptorpb.FileDescriptorProto)},
+			}
+		}
+
+		first, err1 := FindAnnotatedFields(request())
+		for range 3 {
+			again, err2 := FindAnnotatedFields(request())
+			if (err1 == nil) != (err2 == nil) || err1 != nil && err1.Error() != err2.Error() {
+				t.Fatalf("FindAnnotatedFields() errors differ between runs: %v, then %v", err1, err2)
+			}
+			if !reflect.DeepEqual(first, again) {
+				t.Fatalf("FindAnnotatedFields() results differ between runs:\n%+v\n%+v", first, again)
+			}
+		}
+	})
+}
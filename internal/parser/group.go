@@ -0,0 +1,17 @@
+package parser
+
+import (
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// isMessageField reports whether a field holds a message, either as a
+// message field or as a proto2 group. protoc-gen-go generates the same Go
+// types for both: *Msg_Group, []*Msg_Group and so on.
+func isMessageField(field *descriptorpb.FieldDescriptorProto) bool {
+	switch field.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_MESSAGE,
+		descriptorpb.FieldDescriptorProto_TYPE_GROUP:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/benjamin-rood/protogo-values/internal/parser/types"
+	"github.com/benjamin-rood/protogo-values/proto/protogo_values"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestProcessMessageGroups(t *testing.T) {
+	valueSlice := &descriptorpb.FieldOptions{}
+	proto.SetExtension(valueSlice, protogo_values.E_ValueSlice, true)
+
+	// repeated group SearchResult = 1 [(protogo_values.value_slice) = true] {
+	//   repeated group Snippet = 1 [(protogo_values.value_slice) = true] { ... }
+	// }
+	msg := &descriptorpb.DescriptorProto{
+		Name: proto.String("SearchResponse"),
+		Field: []*descriptorpb.FieldDescriptorProto{{
+			Name:     proto.String("searchresult"),
+			Number:   proto.Int32(1),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_GROUP.Enum(),
+			TypeName: proto.String(".test.SearchResponse.SearchResult"),
+			Options:  valueSlice,
+		}},
+		NestedType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("SearchResult"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("snippet"),
+				Number:   proto.Int32(2),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_GROUP.Enum(),
+				TypeName: proto.String(".test.SearchResponse.SearchResult.Snippet"),
+				Options:  valueSlice,
+			}},
+			NestedType: []*descriptorpb.DescriptorProto{{Name: proto.String("Snippet")}},
+		}},
+	}
+
+	fields := types.NewAnnotatedFields()
+	if err := processMessage(msg, fields); err != nil {
+		t.Fatalf("processMessage() unexpected error: %v", err)
+	}
+	// protoc-gen-go names a group field after the lowercased group name
+	if !fields.Contains("Searchresult") {
+		t.Error("expected the Searchresult group field to be found")
+	}
+	if !fields.Contains("Snippet") {
+		t.Error("expected the Snippet group nested in a group body to be found")
+	}
+}
+
+func TestGroupFieldOptions(t *testing.T) {
+	group := func(label descriptorpb.FieldDescriptorProto_Label, opts *protogo_values.FieldOptions) *descriptorpb.FieldDescriptorProto {
+		fieldOpts := &descriptorpb.FieldOptions{}
+		proto.SetExtension(fieldOpts, protogo_values.E_FieldOpts, opts)
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String("result"),
+			Number:   proto.Int32(1),
+			Label:    label.Enum(),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_GROUP.Enum(),
+			TypeName: proto.String(".test.Msg.Result"),
+			Options:  fieldOpts,
+		}
+	}
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+
+	if err := validateNullable(group(optional, &protogo_values.FieldOptions{Nullable: proto.Bool(false)})); err != nil {
+		t.Errorf("validateNullable() on a group: unexpected error: %v", err)
+	}
+	if err := validateOmitEmpty(group(optional, &protogo_values.FieldOptions{OmitEmpty: proto.Bool(true)})); err != nil {
+		t.Errorf("validateOmitEmpty() on a group: unexpected error: %v", err)
+	}
+	msg := &descriptorpb.DescriptorProto{Name: proto.String("Msg")}
+	if err := validateValidationRule(msg, group(optional, &protogo_values.FieldOptions{ValidationRule: proto.String("required")})); err != nil {
+		t.Errorf("validateValidationRule() required on a group: unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/benjamin-rood/protogo-values/internal/parser/types"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// ImportMappings returns the M<file>=<import path> entries of a plugin
+// parameter, keyed by proto file path. They override go_package, as they do
+// for protoc-gen-go.
+func ImportMappings(param string) map[string]string {
+	mappings := make(map[string]string)
+	for _, entry := range strings.Split(param, ",") {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok || !strings.HasPrefix(name, "M") {
+			continue
+		}
+		mappings[strings.TrimPrefix(name, "M")] = value
+	}
+	return mappings
+}
+
+// goImportPath returns the Go import path of file: its M mapping if it has
+// one, otherwise the path part of its go_package option
+func goImportPath(file *descriptorpb.FileDescriptorProto, mappings map[string]string) (string, bool) {
+	if path, ok := mappings[file.GetName()]; ok {
+		return path, true
+	}
+	goPackage := file.GetOptions().GetGoPackage()
+	if goPackage == "" {
+		return "", false
+	}
+	path, _, _ := strings.Cut(goPackage, ";")
+	return path, true
+}
+
+// collectGoTypes records the Go import path of every file in the request and
+// the Go identifier protoc-gen-go declares for each of its messages, so that
+// rewritten element types can be matched however the generated file refers
+// to them. Files without a resolvable import path are skipped; protoc-gen-go
+// rejects them when it has to generate or import them.
+func collectGoTypes(req *pluginpb.CodeGeneratorRequest, fields *types.AnnotatedFields) {
+	mappings := ImportMappings(req.GetParameter())
+	for _, file := range req.ProtoFile {
+		importPath, ok := goImportPath(file, mappings)
+		if !ok {
+			continue
+		}
+		fields.AddFileImportPath(file.GetName(), importPath)
+
+		prefix := "."
+		if file.GetPackage() != "" {
+			prefix += file.GetPackage() + "."
+		}
+		for _, msg := range file.MessageType {
+			collectMessageGoTypes(msg, prefix, "", importPath, fields)
+		}
+	}
+}
+
+// collectMessageGoTypes records the Go identifiers of msg and its nested
+// messages. protoc-gen-go derives them from the name relative to the package,
+// so the nested message bar_Baz of Foo is FooBar_Baz, not Foo_BarBaz.
+func collectMessageGoTypes(msg *descriptorpb.DescriptorProto, prefix, parentName, importPath string, fields *types.AnnotatedFields) {
+	name := msg.GetName()
+	if parentName != "" {
+		name = parentName + "." + name
+	}
+	fields.AddGoType(prefix+name, types.GoIdent{ImportPath: importPath, Name: goCamelCase(name)})
+
+	for _, nested := range msg.NestedType {
+		collectMessageGoTypes(nested, prefix, name, importPath, fields)
+	}
+}
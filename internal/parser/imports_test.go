@@ -0,0 +1,125 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/benjamin-rood/protogo-values/internal/parser/types"
+	"github.com/benjamin-rood/protogo-values/proto/protogo_values"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func TestImportMappings(t *testing.T) {
+	got := ImportMappings("paths=source_relative,Mcommon/user.proto=example.com/mapped/user,Mbad,module=example.com")
+	if len(got) != 1 || got["common/user.proto"] != "example.com/mapped/user" {
+		t.Errorf("ImportMappings() = %v, want only the common/user.proto mapping", got)
+	}
+}
+
+func TestFindAnnotatedFieldsElemTypes(t *testing.T) {
+	valueSlice := &descriptorpb.FieldOptions{}
+	proto.SetExtension(valueSlice, protogo_values.E_ValueSlice, true)
+
+	common := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("common/user.proto"),
+		Package: proto.String("common"),
+		Options: &descriptorpb.FileOptions{GoPackage: proto.String("example.com/common;common")},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name:       proto.String("User"),
+				NestedType: []*descriptorpb.DescriptorProto{{Name: proto.String("login_event")}},
+			},
+			{
+				Name: proto.String("Foo_bar_Baz"),
+				NestedType: []*descriptorpb.DescriptorProto{
+					{Name: proto.String("foo_1bar")},
+					{Name: proto.String("Inner")},
+				},
+			},
+		},
+	}
+	app := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("app.proto"),
+		Package:    proto.String("app"),
+		Dependency: []string{"common/user.proto"},
+		Options:    &descriptorpb.FileOptions{GoPackage: proto.String("example.com/app")},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Team"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{
+					Name:     proto.String("members"),
+					Number:   proto.Int32(1),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+					TypeName: proto.String(".common.User"),
+					Options:  valueSlice,
+				},
+				{
+					Name:     proto.String("logins"),
+					Number:   proto.Int32(2),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+					TypeName: proto.String(".common.User.login_event"),
+					Options:  valueSlice,
+				},
+				{
+					Name:     proto.String("bazzes"),
+					Number:   proto.Int32(3),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+					TypeName: proto.String(".common.Foo_bar_Baz"),
+					Options:  valueSlice,
+				},
+				{
+					Name:     proto.String("bars"),
+					Number:   proto.Int32(4),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+					TypeName: proto.String(".common.Foo_bar_Baz.foo_1bar"),
+					Options:  valueSlice,
+				},
+				{
+					Name:     proto.String("inners"),
+					Number:   proto.Int32(5),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+					TypeName: proto.String(".common.Foo_bar_Baz.Inner"),
+					Options:  valueSlice,
+				},
+			},
+		}},
+	}
+
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{"app.proto"},
+		Parameter:      proto.String("Mcommon/user.proto=example.com/mapped/common"),
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{common, app},
+	}
+	fields, err := FindAnnotatedFields(req)
+	if err != nil {
+		t.Fatalf("FindAnnotatedFields() unexpected error: %v", err)
+	}
+
+	// The M mapping overrides go_package, and the names are the ones
+	// protoc-gen-go generates
+	tests := []struct {
+		field string
+		want  types.GoIdent
+	}{
+		{"Members", types.GoIdent{ImportPath: "example.com/mapped/common", Name: "User"}},
+		{"Logins", types.GoIdent{ImportPath: "example.com/mapped/common", Name: "UserLoginEvent"}},
+		{"Bazzes", types.GoIdent{ImportPath: "example.com/mapped/common", Name: "FooBar_Baz"}},
+		{"Bars", types.GoIdent{ImportPath: "example.com/mapped/common", Name: "FooBar_BazFoo_1Bar"}},
+		{"Inners", types.GoIdent{ImportPath: "example.com/mapped/common", Name: "FooBar_Baz_Inner"}},
+	}
+	for _, tt := range tests {
+		idents, ok := fields.ElemTypes(tt.field)
+		if !ok || len(idents) != 1 || idents[0] != tt.want {
+			t.Errorf("ElemTypes(%q) = %v, %v, want [%v]", tt.field, idents, ok, tt.want)
+		}
+	}
+	if path, _ := fields.FileImportPath("app.proto"); path != "example.com/app" {
+		t.Errorf("FileImportPath(app.proto) = %q, want example.com/app", path)
+	}
+}
@@ -0,0 +1,89 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/benjamin-rood/protogo-values/internal/diag"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// ApplyJSONNameOverrides rewrites, in place, the json_name of every field in
+// req that sets json_name_override, so that protoc-gen-go embeds the new name
+// in the generated descriptor and protobuf tag. It must run before the
+// request is handed to protoc-gen-go.
+func ApplyJSONNameOverrides(req *pluginpb.CodeGeneratorRequest) error {
+	if req == nil {
+		return fmt.Errorf("request cannot be nil")
+	}
+
+	for _, protoFile := range req.ProtoFile {
+		for _, message := range protoFile.MessageType {
+			if err := applyJSONNameOverrides(message); err != nil {
+				return locate(protoFile, diag.SourcePositions(protoFile), fmt.Errorf("message %s: %w", message.GetName(), err))
+			}
+		}
+	}
+	return nil
+}
+
+// applyJSONNameOverrides rewrites the overridden JSON names of msg and its
+// nested messages, rejecting names that would be ambiguous when parsing.
+// protojson accepts both the JSON name and the proto name of a field.
+func applyJSONNameOverrides(msg *descriptorpb.DescriptorProto) error {
+	overridden := false
+	for _, field := range msg.Field {
+		opts := StructuredOptions(field.Options)
+		if opts.JsonNameOverride == nil {
+			continue
+		}
+		if err := validateJSONName(opts.GetJsonNameOverride()); err != nil {
+			return at(field, fmt.Errorf("field %s: %w", field.GetName(), err))
+		}
+		field.JsonName = opts.JsonNameOverride
+		overridden = true
+	}
+
+	if overridden {
+		for _, field := range msg.Field {
+			if StructuredOptions(field.Options).JsonNameOverride == nil {
+				continue
+			}
+			for _, other := range msg.Field {
+				if other == field {
+					continue
+				}
+				if field.GetJsonName() == other.GetJsonName() || field.GetJsonName() == other.GetName() {
+					return at(field, fmt.Errorf("field %s: json_name_override %q clashes with field %s",
+						field.GetName(), field.GetJsonName(), other.GetName()))
+				}
+			}
+		}
+	}
+
+	for _, nested := range msg.NestedType {
+		if nested.GetOptions().GetMapEntry() {
+			continue
+		}
+		if err := applyJSONNameOverrides(nested); err != nil {
+			return fmt.Errorf("message %s: %w", nested.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// validateJSONName checks that name can be used as a JSON key and inside the
+// generated json struct tag
+func validateJSONName(name string) error {
+	if name == "" {
+		return fmt.Errorf("json_name_override cannot be empty")
+	}
+	if strings.ContainsAny(name, "\"`,\\") || strings.IndexFunc(name, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r)
+	}) >= 0 {
+		return fmt.Errorf("invalid json_name_override %q: quotes, backticks, commas, backslashes and whitespace are not allowed", name)
+	}
+	return nil
+}
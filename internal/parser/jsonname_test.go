@@ -0,0 +1,132 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/benjamin-rood/protogo-values/internal/parser/types"
+	"github.com/benjamin-rood/protogo-values/proto/protogo_values"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func jsonNameField(name string, override *string, goTags *string) *descriptorpb.FieldDescriptorProto {
+	opts := &descriptorpb.FieldOptions{}
+	proto.SetExtension(opts, protogo_values.E_FieldOpts, &protogo_values.FieldOptions{
+		JsonNameOverride: override,
+		GoTags:           goTags,
+	})
+	return &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(name),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+		Options:  opts,
+	}
+}
+
+func TestApplyJSONNameOverrides(t *testing.T) {
+	req := &pluginpb.CodeGeneratorRequest{
+		ProtoFile: []*descriptorpb.FileDescriptorProto{{
+			Name: proto.String("test.proto"),
+			MessageType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("User"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					jsonNameField("user_id", proto.String("uid"), nil),
+					jsonNameField("display_name", nil, nil),
+				},
+				NestedType: []*descriptorpb.DescriptorProto{{
+					Name:  proto.String("Address"),
+					Field: []*descriptorpb.FieldDescriptorProto{jsonNameField("post_code", proto.String("zip"), nil)},
+				}},
+			}},
+		}},
+	}
+
+	if err := ApplyJSONNameOverrides(req); err != nil {
+		t.Fatalf("ApplyJSONNameOverrides() unexpected error: %v", err)
+	}
+
+	user := req.ProtoFile[0].MessageType[0]
+	if got := user.Field[0].GetJsonName(); got != "uid" {
+		t.Errorf("expected user_id json_name uid, got %q", got)
+	}
+	if got := user.Field[1].GetJsonName(); got != "display_name" {
+		t.Errorf("expected display_name json_name to be unchanged, got %q", got)
+	}
+	if got := user.NestedType[0].Field[0].GetJsonName(); got != "zip" {
+		t.Errorf("expected nested post_code json_name zip, got %q", got)
+	}
+}
+
+func TestApplyJSONNameOverridesErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []*descriptorpb.FieldDescriptorProto
+	}{
+		{"empty", []*descriptorpb.FieldDescriptorProto{jsonNameField("user_id", proto.String(""), nil)}},
+		{"quote", []*descriptorpb.FieldDescriptorProto{jsonNameField("user_id", proto.String(`u"id`), nil)}},
+		{"comma", []*descriptorpb.FieldDescriptorProto{jsonNameField("user_id", proto.String("u,id"), nil)}},
+		{"space", []*descriptorpb.FieldDescriptorProto{jsonNameField("user_id", proto.String("u id"), nil)}},
+		{"clashes with json name", []*descriptorpb.FieldDescriptorProto{
+			jsonNameField("user_id", proto.String("name"), nil),
+			jsonNameField("name", nil, nil),
+		}},
+		{"clashes with proto name", []*descriptorpb.FieldDescriptorProto{
+			jsonNameField("user_id", proto.String("display_name"), nil),
+			jsonNameField("display_name", nil, nil),
+		}},
+		{"clashes with override", []*descriptorpb.FieldDescriptorProto{
+			jsonNameField("user_id", proto.String("id"), nil),
+			jsonNameField("account_id", proto.String("id"), nil),
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &pluginpb.CodeGeneratorRequest{
+				ProtoFile: []*descriptorpb.FileDescriptorProto{{
+					Name:        proto.String("test.proto"),
+					MessageType: []*descriptorpb.DescriptorProto{{Name: proto.String("User"), Field: tt.fields}},
+				}},
+			}
+			if err := ApplyJSONNameOverrides(req); err == nil {
+				t.Error("ApplyJSONNameOverrides() expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestCollectStructTagsJSONNameOverride(t *testing.T) {
+	oneofMember := jsonNameField("email", proto.String("mail"), nil)
+	oneofMember.OneofIndex = proto.Int32(0)
+	msg := &descriptorpb.DescriptorProto{
+		Name: proto.String("User"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			jsonNameField("user_id", proto.String("uid"), proto.String(`db:"user_id"`)),
+			oneofMember,
+		},
+		OneofDecl: []*descriptorpb.OneofDescriptorProto{{Name: proto.String("contact")}},
+	}
+
+	fields := types.NewAnnotatedFields()
+	if err := collectStructTags("user.proto", msg, "User", fields); err != nil {
+		t.Fatalf("collectStructTags() unexpected error: %v", err)
+	}
+
+	got := fields.StructTags("user.proto")
+	if tags := got["User"]["UserId"]; tags != `json:"uid,omitempty" db:"user_id"` {
+		t.Errorf("unexpected UserId tags %q", tags)
+	}
+	if _, ok := got["User_Email"]; ok {
+		t.Errorf("oneof wrapper structs have no json tag to rewrite, got %v", got["User_Email"])
+	}
+
+	conflicting := &descriptorpb.DescriptorProto{
+		Name:  proto.String("User"),
+		Field: []*descriptorpb.FieldDescriptorProto{jsonNameField("user_id", proto.String("uid"), proto.String(`json:"id"`))},
+	}
+	if err := collectStructTags("user.proto", conflicting, "User", types.NewAnnotatedFields()); err == nil {
+		t.Error("expected error when go_tags sets json together with json_name_override")
+	}
+}
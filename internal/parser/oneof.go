@@ -0,0 +1,55 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/benjamin-rood/protogo-values/proto/protogo_values"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// OneofLevelOptions returns the (protogo_values.oneof_opts) extension of the
+// given oneof options, or an empty OneofOptions if it is not set.
+func OneofLevelOptions(opts *descriptorpb.OneofOptions) *protogo_values.OneofOptions {
+	if opts == nil || !proto.HasExtension(opts, protogo_values.E_OneofOpts) {
+		return &protogo_values.OneofOptions{}
+	}
+	oneofOpts := proto.GetExtension(opts, protogo_values.E_OneofOpts).(*protogo_values.OneofOptions)
+	if oneofOpts == nil {
+		return &protogo_values.OneofOptions{}
+	}
+	return oneofOpts
+}
+
+// oneofMembers groups the fields of msg by the index of the oneof they
+// belong to. Proto3 optional fields live in synthetic oneofs and are
+// grouped like any other member.
+func oneofMembers(msg *descriptorpb.DescriptorProto) map[int32][]*descriptorpb.FieldDescriptorProto {
+	members := make(map[int32][]*descriptorpb.FieldDescriptorProto)
+	for _, field := range msg.Field {
+		if field.OneofIndex != nil {
+			members[field.GetOneofIndex()] = append(members[field.GetOneofIndex()], field)
+		}
+	}
+	return members
+}
+
+// validateOneofs ensures value_oneof is only set on real oneofs. The
+// synthetic oneof protoc creates for a proto3 optional field has no
+// wrapper types to convert.
+func validateOneofs(msg *descriptorpb.DescriptorProto) error {
+	members := oneofMembers(msg)
+	for i, oneof := range msg.OneofDecl {
+		if !OneofLevelOptions(oneof.GetOptions()).GetValueOneof() {
+			continue
+		}
+		fields := members[int32(i)]
+		if len(fields) == 0 {
+			return at(oneof, fmt.Errorf("oneof %s: value_oneof requires at least one field", oneof.GetName()))
+		}
+		if fields[0].GetProto3Optional() {
+			return at(oneof, fmt.Errorf("oneof %s: value_oneof cannot be used on the synthetic oneof of a proto3 optional field", oneof.GetName()))
+		}
+	}
+	return nil
+}
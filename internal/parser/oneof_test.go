@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/benjamin-rood/protogo-values/internal/parser/types"
+	"github.com/benjamin-rood/protogo-values/proto/protogo_values"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func valueOneofDecl(name string) *descriptorpb.OneofDescriptorProto {
+	opts := &descriptorpb.OneofOptions{}
+	proto.SetExtension(opts, protogo_values.E_OneofOpts, &protogo_values.OneofOptions{ValueOneof: proto.Bool(true)})
+	return &descriptorpb.OneofDescriptorProto{Name: proto.String(name), Options: opts}
+}
+
+func TestValidateOneofs(t *testing.T) {
+	member := func(name string, proto3Optional bool) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:           proto.String(name),
+			Label:          descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:           descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+			OneofIndex:     proto.Int32(0),
+			Proto3Optional: proto.Bool(proto3Optional),
+		}
+	}
+
+	tests := []struct {
+		name    string
+		msg     *descriptorpb.DescriptorProto
+		wantErr bool
+	}{
+		{
+			name: "real oneof",
+			msg: &descriptorpb.DescriptorProto{
+				Name:      proto.String("Payment"),
+				Field:     []*descriptorpb.FieldDescriptorProto{member("voucher", false)},
+				OneofDecl: []*descriptorpb.OneofDescriptorProto{valueOneofDecl("method")},
+			},
+		},
+		{
+			name: "synthetic oneof",
+			msg: &descriptorpb.DescriptorProto{
+				Name:      proto.String("Payment"),
+				Field:     []*descriptorpb.FieldDescriptorProto{member("voucher", true)},
+				OneofDecl: []*descriptorpb.OneofDescriptorProto{valueOneofDecl("_voucher")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty oneof",
+			msg: &descriptorpb.DescriptorProto{
+				Name:      proto.String("Payment"),
+				OneofDecl: []*descriptorpb.OneofDescriptorProto{valueOneofDecl("method")},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateOneofs(tt.msg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateOneofs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProcessMessageOneofCases(t *testing.T) {
+	valueSlice := &descriptorpb.FieldOptions{}
+	proto.SetExtension(valueSlice, protogo_values.E_ValueSlice, true)
+
+	msg := &descriptorpb.DescriptorProto{
+		Name: proto.String("Payment"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			{
+				Name:       proto.String("invoice"),
+				Number:     proto.Int32(1),
+				Label:      descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:       descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName:   proto.String(".test.Payment.Invoice"),
+				OneofIndex: proto.Int32(0),
+			},
+		},
+		OneofDecl: []*descriptorpb.OneofDescriptorProto{{Name: proto.String("method")}},
+		NestedType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Invoice"),
+				Field: []*descriptorpb.FieldDescriptorProto{{
+					Name:     proto.String("lines"),
+					Number:   proto.Int32(1),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+					TypeName: proto.String(".test.LineItem"),
+					Options:  valueSlice,
+				}},
+			},
+			{
+				Name: proto.String("Unused"),
+				Field: []*descriptorpb.FieldDescriptorProto{{
+					Name:     proto.String("entries"),
+					Number:   proto.Int32(1),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+					TypeName: proto.String(".test.LineItem"),
+					Options:  valueSlice,
+				}},
+			},
+		},
+	}
+
+	fields := types.NewAnnotatedFields()
+	if err := processMessage(msg, fields); err != nil {
+		t.Fatalf("processMessage() unexpected error: %v", err)
+	}
+	if !fields.Contains("Lines") {
+		t.Error("expected Lines in the nested oneof case message to be found")
+	}
+	if !fields.Contains("Entries") {
+		t.Error("expected Entries in a nested message that is not a oneof case to be found too")
+	}
+}
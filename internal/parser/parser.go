@@ -2,8 +2,14 @@ package parser
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
 
+	"github.com/benjamin-rood/protogo-values/internal/diag"
 	"github.com/benjamin-rood/protogo-values/internal/parser/types"
+	"github.com/benjamin-rood/protogo-values/internal/structtag"
+	"github.com/benjamin-rood/protogo-values/internal/validation"
 	"github.com/benjamin-rood/protogo-values/proto/protogo_values"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/descriptorpb"
@@ -17,10 +23,11 @@ func FindAnnotatedFields(req *pluginpb.CodeGeneratorRequest) (*types.AnnotatedFi
 	}
 	
 	fields := types.NewAnnotatedFields()
+	collectGoTypes(req, fields)
 
 	for _, protoFile := range req.ProtoFile {
 		if err := processProtoFile(protoFile, fields); err != nil {
-			return nil, fmt.Errorf("failed to process proto file %s: %w", protoFile.GetName(), err)
+			return nil, locate(protoFile, diag.SourcePositions(protoFile), err)
 		}
 	}
 
@@ -33,8 +40,125 @@ func processProtoFile(protoFile *descriptorpb.FileDescriptorProto, fields *types
 		if err := processMessage(message, fields); err != nil {
 			return fmt.Errorf("failed to process message %s: %w", message.GetName(), err)
 		}
+		if err := collectStructTags(protoFile.GetName(), message, message.GetName(), fields); err != nil {
+			return fmt.Errorf("failed to process message %s: %w", message.GetName(), err)
+		}
+		if err := validatePresence(protoFile, message); err != nil {
+			return fmt.Errorf("failed to process message %s: %w", message.GetName(), err)
+		}
+	}
+
+	return nil
+}
+
+// collectStructTags records the go_tags of msg and its nested messages,
+// together with the json tag implied by json_name_override and omit_empty.
+// Fields in a oneof live in the oneof's generated wrapper struct, which has
+// no json tag. name is the name of msg relative to its package, from which
+// protoc-gen-go derives the struct name.
+func collectStructTags(protoPath string, msg *descriptorpb.DescriptorProto, name string, fields *types.AnnotatedFields) error {
+	goName := goCamelCase(name)
+	for _, field := range msg.Field {
+		opts := StructuredOptions(field.Options)
+		oneof := inRealOneof(field)
+		jsonTag := !oneof && (opts.JsonNameOverride != nil || opts.OmitEmpty != nil)
+		if opts.GoTags == nil && !jsonTag {
+			continue
+		}
+		if err := validateGoTags(opts.GetGoTags()); err != nil {
+			return at(field, fmt.Errorf("field %s: %w", field.GetName(), err))
+		}
+
+		tags := opts.GetGoTags()
+		if jsonTag {
+			if err := checkJSONTagUnset(tags); err != nil {
+				return at(field, fmt.Errorf("field %s: %w", field.GetName(), err))
+			}
+			tags = strings.TrimSpace(`json:"` + jsonTagValue(field, opts) + `" ` + tags)
+		}
+
+		goFieldName := toGoFieldName(field.GetName())
+		structName := goName
+		if oneof {
+			structName = goName + "_" + goFieldName
+		}
+		fields.AddStructTags(protoPath, structName, goFieldName, tags)
+	}
+
+	for _, nested := range msg.NestedType {
+		if nested.GetOptions().GetMapEntry() {
+			continue
+		}
+		if err := collectStructTags(protoPath, nested, name+"."+nested.GetName(), fields); err != nil {
+			return fmt.Errorf("failed to process message %s: %w", nested.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// validateGoTags checks that tags is a well-formed struct tag that does not
+// touch the tags reserved for the protobuf runtime
+func validateGoTags(tags string) error {
+	parsed, err := structtag.Parse(tags)
+	if err != nil {
+		return fmt.Errorf("invalid go_tags: %w", err)
+	}
+	for _, tag := range parsed {
+		if structtag.IsProtected(tag.Key) {
+			return fmt.Errorf("go_tags cannot set the %s tag", tag.Key)
+		}
+	}
+	return nil
+}
+
+// jsonTagValue returns the json struct tag value for field. Like
+// protoc-gen-go it is keyed by the proto name and carries omitempty, unless
+// json_name_override or omit_empty say otherwise.
+func jsonTagValue(field *descriptorpb.FieldDescriptorProto, opts *protogo_values.FieldOptions) string {
+	value := field.GetName()
+	if opts.JsonNameOverride != nil {
+		value = opts.GetJsonNameOverride()
+	}
+	switch {
+	case opts.OmitEmpty == nil:
+		return value + ",omitempty"
+	case !opts.GetOmitEmpty():
+		return value
+	case field.GetLabel() != descriptorpb.FieldDescriptorProto_LABEL_REPEATED:
+		// omitzero consults the generated IsZero method of the target
+		return value + ",omitempty,omitzero"
+	}
+	return value + ",omitempty"
+}
+
+// checkJSONTagUnset rejects go_tags that set the json tag, which
+// json_name_override and omit_empty already control
+func checkJSONTagUnset(tags string) error {
+	parsed, err := structtag.Parse(tags)
+	if err != nil {
+		return fmt.Errorf("invalid go_tags: %w", err)
 	}
+	for _, tag := range parsed {
+		if tag.Key == "json" {
+			return fmt.Errorf("go_tags cannot set the json tag together with json_name_override or omit_empty")
+		}
+	}
+	return nil
+}
 
+// validateOmitEmpty ensures omit_empty is only used on fields that
+// encoding/json can leave empty and that carry a json tag
+func validateOmitEmpty(field *descriptorpb.FieldDescriptorProto) error {
+	if StructuredOptions(field.Options).OmitEmpty == nil {
+		return nil
+	}
+	if inRealOneof(field) {
+		return fmt.Errorf("field %s: omit_empty cannot be used on oneof fields", field.GetName())
+	}
+	if field.GetLabel() != descriptorpb.FieldDescriptorProto_LABEL_REPEATED &&
+		!isMessageField(field) {
+		return fmt.Errorf("field %s: omit_empty can only be used on repeated, map and message fields", field.GetName())
+	}
 	return nil
 }
 
@@ -42,15 +166,50 @@ func processMessage(
 	msg *descriptorpb.DescriptorProto,
 	fields *types.AnnotatedFields,
 ) error {
+	if err := validateOneofs(msg); err != nil {
+		return err
+	}
+
 	// Check each field
 	for _, field := range msg.Field {
+		if err := validateValueMap(msg, field); err != nil {
+			return at(field, err)
+		}
+		if err := validateNullable(field); err != nil {
+			return at(field, err)
+		}
+		if err := validateNativeWKT(field); err != nil {
+			return at(field, err)
+		}
+		if err := validateGoType(field); err != nil {
+			return at(field, err)
+		}
+		if err := validateValidationRule(msg, field); err != nil {
+			return at(field, err)
+		}
+		if err := validateOmitEmpty(field); err != nil {
+			return at(field, err)
+		}
+		if err := validateValueSlicePolicy(msg, field); err != nil {
+			return at(field, err)
+		}
+
 		// Only process repeated message fields
 		if field.GetLabel() != descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
 			continue
 		}
 
-		// Only process message types (not primitives)
-		if field.GetType() != descriptorpb.FieldDescriptorProto_TYPE_MESSAGE {
+		// Only process message and group types (not primitives)
+		if !isMessageField(field) {
+			continue
+		}
+
+		// Map fields are repeated entry messages on the wire, but protoc-gen-go
+		// generates map[K]*V for them; value_map handles those in a sibling file
+		if findMapEntry(msg, field) != nil {
+			if shouldUseValueSlice(field) {
+				return at(field, fmt.Errorf("field %s is a map: use value_map instead of value_slice", field.GetName()))
+			}
 			continue
 		}
 
@@ -60,6 +219,19 @@ func processMessage(
 		if shouldUse {
 			goFieldName := toGoFieldName(field.GetName())
 			fields.Add(goFieldName)
+			fields.AddElemType(goFieldName, field.GetTypeName())
+		}
+	}
+
+	// Nested messages, including oneof cases and group bodies, are processed
+	// like top-level ones, so that their options are validated and honoured.
+	// Map entries become Go maps and have no struct of their own.
+	for _, nested := range msg.NestedType {
+		if nested.GetOptions().GetMapEntry() {
+			continue
+		}
+		if err := processMessage(nested, fields); err != nil {
+			return fmt.Errorf("failed to process message %s: %w", nested.GetName(), err)
 		}
 	}
 	return nil
@@ -87,6 +259,273 @@ func shouldUseValueSlice(field *descriptorpb.FieldDescriptorProto) bool {
 	return false
 }
 
+// StructuredOptions returns the (protogo_values.field_opts) extension of the
+// given field options, or an empty FieldOptions if it is not set.
+func StructuredOptions(opts *descriptorpb.FieldOptions) *protogo_values.FieldOptions {
+	if opts == nil || !proto.HasExtension(opts, protogo_values.E_FieldOpts) {
+		return &protogo_values.FieldOptions{}
+	}
+	fieldOpts := proto.GetExtension(opts, protogo_values.E_FieldOpts).(*protogo_values.FieldOptions)
+	if fieldOpts == nil {
+		return &protogo_values.FieldOptions{}
+	}
+	return fieldOpts
+}
+
+// shouldUseValueMap determines if a map field should get value-typed accessors
+func shouldUseValueMap(field *descriptorpb.FieldDescriptorProto) bool {
+	return StructuredOptions(field.Options).GetValueMap()
+}
+
+// validateValueMap rejects value_map on anything but a map with message values
+func validateValueMap(msg *descriptorpb.DescriptorProto, field *descriptorpb.FieldDescriptorProto) error {
+	if !shouldUseValueMap(field) {
+		return nil
+	}
+	entry := findMapEntry(msg, field)
+	if entry == nil {
+		return fmt.Errorf("field %s: value_map can only be used on map fields", field.GetName())
+	}
+	for _, f := range entry.Field {
+		if f.GetNumber() == 2 && f.GetType() != descriptorpb.FieldDescriptorProto_TYPE_MESSAGE {
+			return fmt.Errorf("field %s: value_map requires message values, got %s", field.GetName(), f.GetType())
+		}
+	}
+	return nil
+}
+
+// IsNonNullable reports whether the field options explicitly set
+// nullable = false. An unset nullable option means the field is nullable.
+func IsNonNullable(opts *descriptorpb.FieldOptions) bool {
+	fieldOpts := StructuredOptions(opts)
+	return fieldOpts.Nullable != nil && !fieldOpts.GetNullable()
+}
+
+// IsValueSlice reports whether a field's options request a value slice
+func IsValueSlice(field *descriptorpb.FieldDescriptorProto) bool {
+	return shouldUseValueSlice(field)
+}
+
+// validateNullable rejects nullable = false on anything but a singular
+// message field outside of a oneof
+func validateNullable(field *descriptorpb.FieldDescriptorProto) error {
+	if !IsNonNullable(field.Options) {
+		return nil
+	}
+	if field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
+		return fmt.Errorf("field %s: nullable = false cannot be used on repeated fields", field.GetName())
+	}
+	if !isMessageField(field) {
+		return fmt.Errorf("field %s: nullable = false can only be used on message fields", field.GetName())
+	}
+	if field.OneofIndex != nil {
+		return fmt.Errorf("field %s: nullable = false cannot be used on oneof or optional fields", field.GetName())
+	}
+	return nil
+}
+
+// nativeWKTs lists the well-known types that native_wkt maps to Go types
+var nativeWKTs = map[string]bool{
+	".google.protobuf.Timestamp":   true,
+	".google.protobuf.Duration":    true,
+	".google.protobuf.DoubleValue": true,
+	".google.protobuf.FloatValue":  true,
+	".google.protobuf.Int64Value":  true,
+	".google.protobuf.UInt64Value": true,
+	".google.protobuf.Int32Value":  true,
+	".google.protobuf.UInt32Value": true,
+	".google.protobuf.BoolValue":   true,
+	".google.protobuf.StringValue": true,
+	".google.protobuf.BytesValue":  true,
+}
+
+// IsNativeWKT reports whether the fully qualified message type name (with
+// or without a leading dot) is a well-known type that native_wkt can map
+func IsNativeWKT(typeName string) bool {
+	if !strings.HasPrefix(typeName, ".") {
+		typeName = "." + typeName
+	}
+	return nativeWKTs[typeName]
+}
+
+// FileLevelOptions returns the (protogo_values.file_opts) extension of the
+// given file options, or an empty FileOptions if it is not set.
+func FileLevelOptions(opts *descriptorpb.FileOptions) *protogo_values.FileOptions {
+	if opts == nil || !proto.HasExtension(opts, protogo_values.E_FileOpts) {
+		return &protogo_values.FileOptions{}
+	}
+	fileOpts := proto.GetExtension(opts, protogo_values.E_FileOpts).(*protogo_values.FileOptions)
+	if fileOpts == nil {
+		return &protogo_values.FileOptions{}
+	}
+	return fileOpts
+}
+
+// UsesNativeWKT reports whether well-known-type mapping is enabled for a
+// field. The field-level setting takes precedence over the file-level one.
+func UsesNativeWKT(fileOpts *descriptorpb.FileOptions, fieldOpts *descriptorpb.FieldOptions) bool {
+	if opts := StructuredOptions(fieldOpts); opts.NativeWkt != nil {
+		return opts.GetNativeWkt()
+	}
+	return FileLevelOptions(fileOpts).GetNativeWkt()
+}
+
+// validateNativeWKT rejects native_wkt = true on anything but a singular
+// well-known-type field outside of a oneof
+func validateNativeWKT(field *descriptorpb.FieldDescriptorProto) error {
+	if !StructuredOptions(field.Options).GetNativeWkt() {
+		return nil
+	}
+	if field.GetType() != descriptorpb.FieldDescriptorProto_TYPE_MESSAGE || !IsNativeWKT(field.GetTypeName()) {
+		return fmt.Errorf("field %s: native_wkt can only be used on Timestamp, Duration and wrapper fields", field.GetName())
+	}
+	if field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
+		return fmt.Errorf("field %s: native_wkt cannot be used on repeated fields", field.GetName())
+	}
+	if inRealOneof(field) {
+		return fmt.Errorf("field %s: native_wkt cannot be used on oneof fields", field.GetName())
+	}
+	return nil
+}
+
+// SplitGoName splits a package-qualified Go name such as
+// "github.com/google/uuid.UUID" into its import path and identifier
+func SplitGoName(qualified string) (importPath, name string, err error) {
+	i := strings.LastIndex(qualified, ".")
+	if i <= strings.LastIndex(qualified, "/") || i == len(qualified)-1 {
+		return "", "", fmt.Errorf("%q is not a package-qualified Go name", qualified)
+	}
+	importPath, name = qualified[:i], qualified[i+1:]
+	for j, r := range name {
+		if !unicode.IsLetter(r) && r != '_' && (j == 0 || !unicode.IsDigit(r)) {
+			return "", "", fmt.Errorf("%q is not a valid Go identifier", name)
+		}
+	}
+	return importPath, name, nil
+}
+
+// validateGoType checks that go_type and go_converter are set together,
+// are well formed, and are only used on singular scalar or bytes fields
+func validateGoType(field *descriptorpb.FieldDescriptorProto) error {
+	opts := StructuredOptions(field.Options)
+	if opts.GoType == nil && opts.GoConverter == nil {
+		return nil
+	}
+	if opts.GoType == nil || opts.GoConverter == nil {
+		return fmt.Errorf("field %s: go_type and go_converter must be set together", field.GetName())
+	}
+	if _, _, err := SplitGoName(opts.GetGoType()); err != nil {
+		return fmt.Errorf("field %s: invalid go_type: %w", field.GetName(), err)
+	}
+	if _, _, err := SplitGoName(opts.GetGoConverter()); err != nil {
+		return fmt.Errorf("field %s: invalid go_converter: %w", field.GetName(), err)
+	}
+	switch field.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_MESSAGE,
+		descriptorpb.FieldDescriptorProto_TYPE_GROUP,
+		descriptorpb.FieldDescriptorProto_TYPE_ENUM:
+		return fmt.Errorf("field %s: go_type can only be used on scalar and bytes fields", field.GetName())
+	}
+	if field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
+		return fmt.Errorf("field %s: go_type cannot be used on repeated fields", field.GetName())
+	}
+	if field.OneofIndex != nil {
+		return fmt.Errorf("field %s: go_type cannot be used on oneof or optional fields", field.GetName())
+	}
+	return nil
+}
+
+// validateValidationRule parses a field's validation_rule and checks that
+// each rule applies to the field's type
+func validateValidationRule(msg *descriptorpb.DescriptorProto, field *descriptorpb.FieldDescriptorProto) error {
+	opts := StructuredOptions(field.Options)
+	if opts.ValidationRule == nil {
+		return nil
+	}
+	rules, err := validation.Parse(opts.GetValidationRule())
+	if err != nil {
+		return fmt.Errorf("field %s: invalid validation_rule: %w", field.GetName(), err)
+	}
+	if inRealOneof(field) {
+		return fmt.Errorf("field %s: validation_rule cannot be used on oneof fields", field.GetName())
+	}
+
+	repeated := field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	if rules.Required && (repeated || !isMessageField(field)) {
+		return fmt.Errorf("field %s: rule required can only be used on singular message fields", field.GetName())
+	}
+	if (rules.MinLen != nil || rules.MaxLen != nil) && !repeated {
+		return fmt.Errorf("field %s: rules min_len and max_len can only be used on repeated and map fields", field.GetName())
+	}
+	if rules.Pattern != nil && (repeated || field.GetType() != descriptorpb.FieldDescriptorProto_TYPE_STRING) {
+		return fmt.Errorf("field %s: rule pattern can only be used on singular string fields", field.GetName())
+	}
+	if len(rules.Bounds) > 0 {
+		if repeated || findMapEntry(msg, field) != nil {
+			return fmt.Errorf("field %s: range rules can only be used on singular numeric fields", field.GetName())
+		}
+		for _, bound := range rules.Bounds {
+			if err := checkBound(field, bound.Value); err != nil {
+				return fmt.Errorf("field %s: %w", field.GetName(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkBound verifies that a range rule literal is representable in the
+// field's Go type
+func checkBound(field *descriptorpb.FieldDescriptorProto, value string) error {
+	var err error
+	switch field.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_INT32,
+		descriptorpb.FieldDescriptorProto_TYPE_SINT32,
+		descriptorpb.FieldDescriptorProto_TYPE_SFIXED32:
+		_, err = strconv.ParseInt(value, 10, 32)
+	case descriptorpb.FieldDescriptorProto_TYPE_INT64,
+		descriptorpb.FieldDescriptorProto_TYPE_SINT64,
+		descriptorpb.FieldDescriptorProto_TYPE_SFIXED64:
+		_, err = strconv.ParseInt(value, 10, 64)
+	case descriptorpb.FieldDescriptorProto_TYPE_UINT32,
+		descriptorpb.FieldDescriptorProto_TYPE_FIXED32:
+		_, err = strconv.ParseUint(value, 10, 32)
+	case descriptorpb.FieldDescriptorProto_TYPE_UINT64,
+		descriptorpb.FieldDescriptorProto_TYPE_FIXED64:
+		_, err = strconv.ParseUint(value, 10, 64)
+	case descriptorpb.FieldDescriptorProto_TYPE_FLOAT,
+		descriptorpb.FieldDescriptorProto_TYPE_DOUBLE:
+		_, err = strconv.ParseFloat(value, 64)
+	default:
+		return fmt.Errorf("range rules can only be used on numeric fields")
+	}
+	if err != nil {
+		return fmt.Errorf("range bound %s is not a valid %s", value, field.GetType())
+	}
+	return nil
+}
+
+// findMapEntry returns the synthetic map entry message backing field, or nil
+// if field is not a map. Map entries are always nested in the declaring
+// message, so matching the last segment of the type name is sufficient.
+func findMapEntry(msg *descriptorpb.DescriptorProto, field *descriptorpb.FieldDescriptorProto) *descriptorpb.DescriptorProto {
+	if field.GetLabel() != descriptorpb.FieldDescriptorProto_LABEL_REPEATED ||
+		field.GetType() != descriptorpb.FieldDescriptorProto_TYPE_MESSAGE {
+		return nil
+	}
+	typeName := field.GetTypeName()
+	if i := strings.LastIndex(typeName, "."); i >= 0 {
+		typeName = typeName[i+1:]
+	}
+	for _, nested := range msg.NestedType {
+		if nested.GetName() == typeName && nested.GetOptions().GetMapEntry() {
+			return nested
+		}
+	}
+	return nil
+}
+
+// toGoFieldName returns the name protoc-gen-go gives the struct field of a
+// proto field
 func toGoFieldName(protoName string) string {
-	return toCamelCase(protoName)
+	return goCamelCase(protoName)
 }
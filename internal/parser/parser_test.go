@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/benjamin-rood/protogo-values/internal/parser/types"
@@ -462,14 +463,87 @@ func TestProcessMessageNested(t *testing.T) {
 		t.Error("Expected OuterField to be found")
 	}
 
-	// Note: Nested message processing doesn't currently recurse into nested types
-	// This is by design - only top-level message fields are processed
-	if fields.Contains("InnerField") {
-		t.Error("InnerField should not be found (nested type processing not implemented)")
+	// Nested messages are processed like top-level ones
+	if !fields.Contains("InnerField") {
+		t.Error("Expected InnerField in the nested message to be found")
 	}
 }
 
-// Test malformed field names
+// Nested messages are processed at any depth: annotated fields are found,
+// unannotated ones and map entries are left alone, and invalid options are
+// reported with the path of the nested message
+func TestProcessMessageNestedDepth(t *testing.T) {
+	valueSlice := func() *descriptorpb.FieldOptions {
+		opts := &descriptorpb.FieldOptions{}
+		proto.SetExtension(opts, protogo_values.E_ValueSlice, true)
+		return opts
+	}
+	repeatedMessage := func(name, typeName string, opts *descriptorpb.FieldOptions) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			Number:   proto.Int32(1),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+			TypeName: proto.String(typeName),
+			Options:  opts,
+		}
+	}
+	labelsEntry := &descriptorpb.DescriptorProto{
+		Name:    proto.String("LabelsEntry"),
+		Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+		Field: []*descriptorpb.FieldDescriptorProto{
+			repeatedMessage("entry_values", ".test.Item", valueSlice()),
+		},
+	}
+	message := func(labelOpts *descriptorpb.FieldOptions) *descriptorpb.DescriptorProto {
+		return &descriptorpb.DescriptorProto{
+			Name: proto.String("Outer"),
+			NestedType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("Middle"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					repeatedMessage("plain_items", ".test.Item", nil),
+				},
+				NestedType: []*descriptorpb.DescriptorProto{{
+					Name: proto.String("Inner"),
+					Field: []*descriptorpb.FieldDescriptorProto{
+						repeatedMessage("deep_items", ".test.Item", valueSlice()),
+						repeatedMessage("labels", ".test.Outer.Middle.Inner.LabelsEntry", labelOpts),
+					},
+					NestedType: []*descriptorpb.DescriptorProto{labelsEntry},
+				}},
+			}},
+		}
+	}
+
+	fields := types.NewAnnotatedFields()
+	if err := processMessage(message(nil), fields); err != nil {
+		t.Fatalf("processMessage() unexpected error: %v", err)
+	}
+	if !fields.Contains("DeepItems") {
+		t.Error("Expected DeepItems two levels down to be found")
+	}
+	for _, name := range []string{"PlainItems", "Labels", "EntryValues"} {
+		if fields.Contains(name) {
+			t.Errorf("Expected %s not to be found", name)
+		}
+	}
+
+	err := processMessage(message(valueSlice()), types.NewAnnotatedFields())
+	if err == nil {
+		t.Fatal("processMessage() expected an error for value_slice on a nested map field")
+	}
+	for _, want := range []string{
+		"failed to process message Middle",
+		"failed to process message Inner",
+		"use value_map instead of value_slice",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("processMessage() error = %q, expected it to contain %q", err, want)
+		}
+	}
+}
+
+// Test malformed field names, named as protoc-gen-go names them
 func TestToGoFieldNameEdgeCases(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -477,12 +551,14 @@ func TestToGoFieldNameEdgeCases(t *testing.T) {
 		expected  string
 	}{
 		{"empty string", "", ""},
-		{"single underscore", "_", ""},
-		{"multiple underscores", "___", ""},
+		{"single underscore", "_", "X"},
+		{"multiple underscores", "___", "X__"},
 		{"numbers only", "123", "123"},
-		{"mixed numbers and underscores", "1_2_3", "123"},
+		{"mixed numbers and underscores", "1_2_3", "1_2_3"},
+		{"underscore before digit", "foo_1bar", "Foo_1Bar"},
+		{"double underscore", "a__b", "A_B"},
 		{"special characters remain", "field-name", "Field-name"},
-		{"unicode characters", "field_测试", "Field测试"},
+		{"unicode characters", "field_测试", "Field_测试"},
 	}
 
 	for _, tt := range tests {
@@ -493,4 +569,676 @@ func TestToGoFieldNameEdgeCases(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+// Test map field detection and value_map validation
+func TestProcessMessageMapFields(t *testing.T) {
+	valueMapOpts := func() *descriptorpb.FieldOptions {
+		opts := &descriptorpb.FieldOptions{}
+		proto.SetExtension(opts, protogo_values.E_FieldOpts, &protogo_values.FieldOptions{
+			ValueMap: proto.Bool(true),
+		})
+		return opts
+	}
+	valueSliceOpts := func() *descriptorpb.FieldOptions {
+		opts := &descriptorpb.FieldOptions{}
+		proto.SetExtension(opts, protogo_values.E_ValueSlice, true)
+		return opts
+	}
+	mapEntry := func(name string, valueType descriptorpb.FieldDescriptorProto_Type) *descriptorpb.DescriptorProto {
+		return &descriptorpb.DescriptorProto{
+			Name: proto.String(name),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("key"), Number: proto.Int32(1), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()},
+				{Name: proto.String("value"), Number: proto.Int32(2), Type: valueType.Enum(), TypeName: proto.String(".test.User")},
+			},
+			Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		field    *descriptorpb.FieldDescriptorProto
+		entry    *descriptorpb.DescriptorProto
+		wantErr bool
+	}{
+		{
+			name: "value_map on message-valued map",
+			field: &descriptorpb.FieldDescriptorProto{
+				Name:     proto.String("users_by_id"),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String(".test.Directory.UsersByIdEntry"),
+				Options:  valueMapOpts(),
+			},
+			entry: mapEntry("UsersByIdEntry", descriptorpb.FieldDescriptorProto_TYPE_MESSAGE),
+		},
+		{
+			name: "value_map on scalar-valued map",
+			field: &descriptorpb.FieldDescriptorProto{
+				Name:     proto.String("counts"),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String(".test.Directory.CountsEntry"),
+				Options:  valueMapOpts(),
+			},
+			entry:   mapEntry("CountsEntry", descriptorpb.FieldDescriptorProto_TYPE_INT32),
+			wantErr: true,
+		},
+		{
+			name: "value_map on repeated message field",
+			field: &descriptorpb.FieldDescriptorProto{
+				Name:     proto.String("users"),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String(".test.User"),
+				Options:  valueMapOpts(),
+			},
+			wantErr: true,
+		},
+		{
+			name: "value_slice on map field",
+			field: &descriptorpb.FieldDescriptorProto{
+				Name:     proto.String("users_by_id"),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String(".test.Directory.UsersByIdEntry"),
+				Options:  valueSliceOpts(),
+			},
+			entry:   mapEntry("UsersByIdEntry", descriptorpb.FieldDescriptorProto_TYPE_MESSAGE),
+			wantErr: true,
+		},
+		{
+			name: "unannotated map field",
+			field: &descriptorpb.FieldDescriptorProto{
+				Name:     proto.String("users_by_id"),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String(".test.Directory.UsersByIdEntry"),
+			},
+			entry: mapEntry("UsersByIdEntry", descriptorpb.FieldDescriptorProto_TYPE_MESSAGE),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &descriptorpb.DescriptorProto{
+				Name:  proto.String("Directory"),
+				Field: []*descriptorpb.FieldDescriptorProto{tt.field},
+			}
+			if tt.entry != nil {
+				msg.NestedType = append(msg.NestedType, tt.entry)
+			}
+
+			fields := types.NewAnnotatedFields()
+			err := processMessage(msg, fields)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("processMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			// Map fields are never rewritten in place
+			if fields.Count() != 0 {
+				t.Errorf("expected no fields to transform, got %v", fields.All())
+			}
+		})
+	}
+}
+
+// Test nullable option validation
+func TestValidateNullable(t *testing.T) {
+	nullableFalse := func() *descriptorpb.FieldOptions {
+		opts := &descriptorpb.FieldOptions{}
+		proto.SetExtension(opts, protogo_values.E_FieldOpts, &protogo_values.FieldOptions{
+			Nullable: proto.Bool(false),
+		})
+		return opts
+	}
+
+	tests := []struct {
+		name    string
+		field   *descriptorpb.FieldDescriptorProto
+		wantErr bool
+	}{
+		{
+			name: "singular message field",
+			field: &descriptorpb.FieldDescriptorProto{
+				Name:    proto.String("customer"),
+				Label:   descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:    descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				Options: nullableFalse(),
+			},
+		},
+		{
+			name: "no option",
+			field: &descriptorpb.FieldDescriptorProto{
+				Name:  proto.String("tags"),
+				Label: descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+				Type:  descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+			},
+		},
+		{
+			name: "repeated message field",
+			field: &descriptorpb.FieldDescriptorProto{
+				Name:    proto.String("customers"),
+				Label:   descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+				Type:    descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				Options: nullableFalse(),
+			},
+			wantErr: true,
+		},
+		{
+			name: "scalar field",
+			field: &descriptorpb.FieldDescriptorProto{
+				Name:    proto.String("name"),
+				Label:   descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:    descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+				Options: nullableFalse(),
+			},
+			wantErr: true,
+		},
+		{
+			name: "oneof member",
+			field: &descriptorpb.FieldDescriptorProto{
+				Name:       proto.String("card"),
+				Label:      descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:       descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				OneofIndex: proto.Int32(0),
+				Options:    nullableFalse(),
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateNullable(tt.field)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateNullable() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Test well-known type mapping options
+func TestUsesNativeWKT(t *testing.T) {
+	fileOpts := func(enabled bool) *descriptorpb.FileOptions {
+		opts := &descriptorpb.FileOptions{}
+		proto.SetExtension(opts, protogo_values.E_FileOpts, &protogo_values.FileOptions{
+			NativeWkt: proto.Bool(enabled),
+		})
+		return opts
+	}
+	fieldOpts := func(enabled bool) *descriptorpb.FieldOptions {
+		opts := &descriptorpb.FieldOptions{}
+		proto.SetExtension(opts, protogo_values.E_FieldOpts, &protogo_values.FieldOptions{
+			NativeWkt: proto.Bool(enabled),
+		})
+		return opts
+	}
+
+	tests := []struct {
+		name      string
+		fileOpts  *descriptorpb.FileOptions
+		fieldOpts *descriptorpb.FieldOptions
+		expected  bool
+	}{
+		{"no options", nil, nil, false},
+		{"file level", fileOpts(true), nil, true},
+		{"field level", nil, fieldOpts(true), true},
+		{"field overrides file", fileOpts(true), fieldOpts(false), false},
+		{"field enables despite file", fileOpts(false), fieldOpts(true), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UsesNativeWKT(tt.fileOpts, tt.fieldOpts); got != tt.expected {
+				t.Errorf("UsesNativeWKT() = %t, expected %t", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidateNativeWKT(t *testing.T) {
+	nativeWKT := func() *descriptorpb.FieldOptions {
+		opts := &descriptorpb.FieldOptions{}
+		proto.SetExtension(opts, protogo_values.E_FieldOpts, &protogo_values.FieldOptions{
+			NativeWkt: proto.Bool(true),
+		})
+		return opts
+	}
+
+	tests := []struct {
+		name    string
+		field   *descriptorpb.FieldDescriptorProto
+		wantErr bool
+	}{
+		{
+			name: "timestamp field",
+			field: &descriptorpb.FieldDescriptorProto{
+				Name:     proto.String("created_at"),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String(".google.protobuf.Timestamp"),
+				Options:  nativeWKT(),
+			},
+		},
+		{
+			name: "proto3 optional wrapper field",
+			field: &descriptorpb.FieldDescriptorProto{
+				Name:           proto.String("retries"),
+				Label:          descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:           descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName:       proto.String(".google.protobuf.Int64Value"),
+				OneofIndex:     proto.Int32(0),
+				Proto3Optional: proto.Bool(true),
+				Options:        nativeWKT(),
+			},
+		},
+		{
+			name: "non well-known message",
+			field: &descriptorpb.FieldDescriptorProto{
+				Name:     proto.String("user"),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String(".test.User"),
+				Options:  nativeWKT(),
+			},
+			wantErr: true,
+		},
+		{
+			name: "repeated timestamp",
+			field: &descriptorpb.FieldDescriptorProto{
+				Name:     proto.String("history"),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String(".google.protobuf.Timestamp"),
+				Options:  nativeWKT(),
+			},
+			wantErr: true,
+		},
+		{
+			name: "oneof member",
+			field: &descriptorpb.FieldDescriptorProto{
+				Name:       proto.String("at"),
+				Label:      descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:       descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName:   proto.String(".google.protobuf.Timestamp"),
+				OneofIndex: proto.Int32(0),
+				Options:    nativeWKT(),
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateNativeWKT(tt.field)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateNativeWKT() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Test go_type and go_converter options
+func TestSplitGoName(t *testing.T) {
+	tests := []struct {
+		qualified  string
+		importPath string
+		name       string
+		wantErr    bool
+	}{
+		{"github.com/google/uuid.UUID", "github.com/google/uuid", "UUID", false},
+		{"time.Duration", "time", "Duration", false},
+		{"example.com/v2/pkg.Type_2", "example.com/v2/pkg", "Type_2", false},
+		{"UUID", "", "", true},
+		{"github.com/google/uuid", "", "", true},
+		{"github.com/google/uuid.", "", "", true},
+		{"example.com/pkg.2Type", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.qualified, func(t *testing.T) {
+			importPath, name, err := SplitGoName(tt.qualified)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SplitGoName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if importPath != tt.importPath || name != tt.name {
+				t.Errorf("SplitGoName() = (%q, %q), expected (%q, %q)", importPath, name, tt.importPath, tt.name)
+			}
+		})
+	}
+}
+
+func TestValidateGoType(t *testing.T) {
+	goTypeOpts := func(goType, converter *string) *descriptorpb.FieldOptions {
+		opts := &descriptorpb.FieldOptions{}
+		proto.SetExtension(opts, protogo_values.E_FieldOpts, &protogo_values.FieldOptions{
+			GoType:      goType,
+			GoConverter: converter,
+		})
+		return opts
+	}
+	uuid := proto.String("github.com/google/uuid.UUID")
+	conv := proto.String("example.com/convert.UUID")
+
+	tests := []struct {
+		name    string
+		field   *descriptorpb.FieldDescriptorProto
+		wantErr bool
+	}{
+		{
+			name: "bytes field",
+			field: &descriptorpb.FieldDescriptorProto{
+				Name:    proto.String("id"),
+				Label:   descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:    descriptorpb.FieldDescriptorProto_TYPE_BYTES.Enum(),
+				Options: goTypeOpts(uuid, conv),
+			},
+		},
+		{
+			name: "missing converter",
+			field: &descriptorpb.FieldDescriptorProto{
+				Name:    proto.String("id"),
+				Label:   descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:    descriptorpb.FieldDescriptorProto_TYPE_BYTES.Enum(),
+				Options: goTypeOpts(uuid, nil),
+			},
+			wantErr: true,
+		},
+		{
+			name: "malformed type",
+			field: &descriptorpb.FieldDescriptorProto{
+				Name:    proto.String("id"),
+				Label:   descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:    descriptorpb.FieldDescriptorProto_TYPE_BYTES.Enum(),
+				Options: goTypeOpts(proto.String("UUID"), conv),
+			},
+			wantErr: true,
+		},
+		{
+			name: "message field",
+			field: &descriptorpb.FieldDescriptorProto{
+				Name:    proto.String("user"),
+				Label:   descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:    descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				Options: goTypeOpts(uuid, conv),
+			},
+			wantErr: true,
+		},
+		{
+			name: "repeated field",
+			field: &descriptorpb.FieldDescriptorProto{
+				Name:    proto.String("ids"),
+				Label:   descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+				Type:    descriptorpb.FieldDescriptorProto_TYPE_BYTES.Enum(),
+				Options: goTypeOpts(uuid, conv),
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateGoType(tt.field)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateGoType() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Test go_tags collection for top-level, nested and oneof fields
+func TestCollectStructTags(t *testing.T) {
+	goTags := func(tags string) *descriptorpb.FieldOptions {
+		opts := &descriptorpb.FieldOptions{}
+		proto.SetExtension(opts, protogo_values.E_FieldOpts, &protogo_values.FieldOptions{
+			GoTags: proto.String(tags),
+		})
+		return opts
+	}
+
+	msg := &descriptorpb.DescriptorProto{
+		Name: proto.String("Profile"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			{Name: proto.String("user_id"), Options: goTags(`db:"user_id"`)},
+			{Name: proto.String("email"), OneofIndex: proto.Int32(0), Options: goTags(`db:"email"`)},
+			{Name: proto.String("nickname"), OneofIndex: proto.Int32(1), Proto3Optional: proto.Bool(true), Options: goTags(`db:"nick"`)},
+			{Name: proto.String("untagged")},
+		},
+		NestedType: []*descriptorpb.DescriptorProto{
+			{
+				Name:  proto.String("Settings"),
+				Field: []*descriptorpb.FieldDescriptorProto{{Name: proto.String("dark_mode"), Options: goTags(`db:"dark"`)}},
+			},
+			{
+				// protoc-gen-go names this struct ProfileAuditLog
+				Name:  proto.String("audit_log"),
+				Field: []*descriptorpb.FieldDescriptorProto{{Name: proto.String("actor"), Options: goTags(`db:"actor"`)}},
+			},
+		},
+	}
+
+	fields := types.NewAnnotatedFields()
+	if err := collectStructTags("profile.proto", msg, "Profile", fields); err != nil {
+		t.Fatalf("collectStructTags() unexpected error: %v", err)
+	}
+
+	expected := map[string]map[string]string{
+		"Profile":          {"UserId": `db:"user_id"`, "Nickname": `db:"nick"`},
+		"Profile_Email":    {"Email": `db:"email"`},
+		"Profile_Settings": {"DarkMode": `db:"dark"`},
+		"ProfileAuditLog":  {"Actor": `db:"actor"`},
+	}
+	got := fields.StructTags("profile.proto")
+	for structName, fieldTags := range expected {
+		for fieldName, tags := range fieldTags {
+			if got[structName][fieldName] != tags {
+				t.Errorf("expected %s.%s tags %q, got %q", structName, fieldName, tags, got[structName][fieldName])
+			}
+		}
+	}
+	if len(got) != len(expected) {
+		t.Errorf("unexpected struct tags: %v", got)
+	}
+}
+
+func TestValidateGoTags(t *testing.T) {
+	tests := []struct {
+		tags    string
+		wantErr bool
+	}{
+		{`db:"user_id" yaml:"userId"`, false},
+		{`json:"name"`, false},
+		{`protobuf:"bytes,1"`, true},
+		{`protobuf_oneof:"contact"`, true},
+		{`db:user_id`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tags, func(t *testing.T) {
+			err := validateGoTags(tt.tags)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateGoTags(%q) error = %v, wantErr %v", tt.tags, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateValidationRule(t *testing.T) {
+	ruleField := func(name string, label descriptorpb.FieldDescriptorProto_Label, typ descriptorpb.FieldDescriptorProto_Type, rule string) *descriptorpb.FieldDescriptorProto {
+		opts := &descriptorpb.FieldOptions{}
+		proto.SetExtension(opts, protogo_values.E_FieldOpts, &protogo_values.FieldOptions{
+			ValidationRule: proto.String(rule),
+		})
+		return &descriptorpb.FieldDescriptorProto{
+			Name:    proto.String(name),
+			Label:   label.Enum(),
+			Type:    typ.Enum(),
+			Options: opts,
+		}
+	}
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+
+	oneofMember := ruleField("sku", optional, descriptorpb.FieldDescriptorProto_TYPE_STRING, "pattern=^a")
+	oneofMember.OneofIndex = proto.Int32(0)
+
+	tests := []struct {
+		name    string
+		field   *descriptorpb.FieldDescriptorProto
+		wantErr bool
+	}{
+		{"required message", ruleField("user", optional, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, "required"), false},
+		{"required string", ruleField("sku", optional, descriptorpb.FieldDescriptorProto_TYPE_STRING, "required"), true},
+		{"min_len repeated", ruleField("items", repeated, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, "min_len=1,max_len=5"), false},
+		{"min_len singular", ruleField("sku", optional, descriptorpb.FieldDescriptorProto_TYPE_STRING, "min_len=1"), true},
+		{"pattern string", ruleField("sku", optional, descriptorpb.FieldDescriptorProto_TYPE_STRING, `pattern="^[A-Z]+$"`), false},
+		{"pattern int", ruleField("count", optional, descriptorpb.FieldDescriptorProto_TYPE_INT32, "pattern=^1"), true},
+		{"bounds int32", ruleField("count", optional, descriptorpb.FieldDescriptorProto_TYPE_INT32, "gte=1,lt=100"), false},
+		{"bounds out of range", ruleField("count", optional, descriptorpb.FieldDescriptorProto_TYPE_INT32, "lt=9999999999"), true},
+		{"negative bound on uint", ruleField("count", optional, descriptorpb.FieldDescriptorProto_TYPE_UINT64, "gt=-1"), true},
+		{"fractional bound on int", ruleField("count", optional, descriptorpb.FieldDescriptorProto_TYPE_INT64, "gt=0.5"), true},
+		{"fractional bound on double", ruleField("ratio", optional, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, "gt=0.5"), false},
+		{"bounds string", ruleField("sku", optional, descriptorpb.FieldDescriptorProto_TYPE_STRING, "gt=1"), true},
+		{"bounds repeated", ruleField("counts", repeated, descriptorpb.FieldDescriptorProto_TYPE_INT32, "gt=1"), true},
+		{"unknown rule", ruleField("sku", optional, descriptorpb.FieldDescriptorProto_TYPE_STRING, "email"), true},
+		{"oneof member", oneofMember, true},
+	}
+
+	msg := &descriptorpb.DescriptorProto{Name: proto.String("Order")}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateValidationRule(msg, tt.field)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateValidationRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Test that options on fields of nested messages are validated like those of
+// top-level messages
+func TestProcessMessageValidatesNested(t *testing.T) {
+	innerField := func(name string, label descriptorpb.FieldDescriptorProto_Label, typ descriptorpb.FieldDescriptorProto_Type, fieldOpts *protogo_values.FieldOptions) *descriptorpb.FieldDescriptorProto {
+		opts := &descriptorpb.FieldOptions{}
+		proto.SetExtension(opts, protogo_values.E_FieldOpts, fieldOpts)
+		return &descriptorpb.FieldDescriptorProto{
+			Name:    proto.String(name),
+			Number:  proto.Int32(1),
+			Label:   label.Enum(),
+			Type:    typ.Enum(),
+			Options: opts,
+		}
+	}
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+
+	tests := []struct {
+		name    string
+		field   *descriptorpb.FieldDescriptorProto
+		wantErr bool
+	}{
+		{"valid rule", innerField("sku", optional, descriptorpb.FieldDescriptorProto_TYPE_STRING, &protogo_values.FieldOptions{
+			ValidationRule: proto.String(`pattern="^[A-Z]+$"`),
+		}), false},
+		{"unknown rule", innerField("sku", optional, descriptorpb.FieldDescriptorProto_TYPE_STRING, &protogo_values.FieldOptions{
+			ValidationRule: proto.String("bogus_rule"),
+		}), true},
+		{"required string", innerField("sku", optional, descriptorpb.FieldDescriptorProto_TYPE_STRING, &protogo_values.FieldOptions{
+			ValidationRule: proto.String("required"),
+		}), true},
+		{"min_len string", innerField("sku", optional, descriptorpb.FieldDescriptorProto_TYPE_STRING, &protogo_values.FieldOptions{
+			ValidationRule: proto.String("min_len=1"),
+		}), true},
+		{"value_map scalar", innerField("count", optional, descriptorpb.FieldDescriptorProto_TYPE_INT32, &protogo_values.FieldOptions{
+			ValueMap: proto.Bool(true),
+		}), true},
+		{"nullable repeated", innerField("items", descriptorpb.FieldDescriptorProto_LABEL_REPEATED, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, &protogo_values.FieldOptions{
+			Nullable: proto.Bool(false),
+		}), true},
+		{"go_type without converter", innerField("id", optional, descriptorpb.FieldDescriptorProto_TYPE_BYTES, &protogo_values.FieldOptions{
+			GoType: proto.String("github.com/google/uuid.UUID"),
+		}), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &descriptorpb.DescriptorProto{
+				Name: proto.String("Outer"),
+				NestedType: []*descriptorpb.DescriptorProto{{
+					Name:  proto.String("Inner"),
+					Field: []*descriptorpb.FieldDescriptorProto{tt.field},
+				}},
+			}
+			err := processMessage(msg, types.NewAnnotatedFields())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("processMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateOmitEmpty(t *testing.T) {
+	omitOpts := func() *descriptorpb.FieldOptions {
+		opts := &descriptorpb.FieldOptions{}
+		proto.SetExtension(opts, protogo_values.E_FieldOpts, &protogo_values.FieldOptions{OmitEmpty: proto.Bool(true)})
+		return opts
+	}
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+
+	oneofMember := &descriptorpb.FieldDescriptorProto{
+		Name:       proto.String("address"),
+		Label:      optional.Enum(),
+		Type:       descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+		OneofIndex: proto.Int32(0),
+		Options:    omitOpts(),
+	}
+
+	tests := []struct {
+		name    string
+		field   *descriptorpb.FieldDescriptorProto
+		wantErr bool
+	}{
+		{"message", &descriptorpb.FieldDescriptorProto{Name: proto.String("address"), Label: optional.Enum(), Type: descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(), Options: omitOpts()}, false},
+		{"repeated scalar", &descriptorpb.FieldDescriptorProto{Name: proto.String("ids"), Label: repeated.Enum(), Type: descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(), Options: omitOpts()}, false},
+		{"singular scalar", &descriptorpb.FieldDescriptorProto{Name: proto.String("name"), Label: optional.Enum(), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Options: omitOpts()}, true},
+		{"oneof member", oneofMember, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateOmitEmpty(tt.field)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateOmitEmpty() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestJSONTagValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		label descriptorpb.FieldDescriptorProto_Label
+		opts  *protogo_values.FieldOptions
+		want  string
+	}{
+		{"default", descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL, &protogo_values.FieldOptions{}, "address,omitempty"},
+		{"omit message", descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL, &protogo_values.FieldOptions{OmitEmpty: proto.Bool(true)}, "address,omitempty,omitzero"},
+		{"omit repeated", descriptorpb.FieldDescriptorProto_LABEL_REPEATED, &protogo_values.FieldOptions{OmitEmpty: proto.Bool(true)}, "address,omitempty"},
+		{"keep empty", descriptorpb.FieldDescriptorProto_LABEL_REPEATED, &protogo_values.FieldOptions{OmitEmpty: proto.Bool(false)}, "address"},
+		{"keep empty renamed", descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL, &protogo_values.FieldOptions{OmitEmpty: proto.Bool(false), JsonNameOverride: proto.String("addr")}, "addr"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			field := &descriptorpb.FieldDescriptorProto{
+				Name:  proto.String("address"),
+				Label: tt.label.Enum(),
+				Type:  descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+			}
+			if got := jsonTagValue(field, tt.opts); got != tt.want {
+				t.Errorf("jsonTagValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
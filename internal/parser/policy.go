@@ -0,0 +1,216 @@
+package parser
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/benjamin-rood/protogo-values/internal/diag"
+	"github.com/benjamin-rood/protogo-values/proto/protogo_values"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// DefaultAutoMaxBytes is the largest estimated element size for which
+// VALUE_SLICE_POLICY_AUTO generates a value slice, unless the auto_max_bytes
+// plugin parameter sets another
+const DefaultAutoMaxBytes = 128
+
+// AutoDecision is the value_slice VALUE_SLICE_POLICY_AUTO chose for a field
+type AutoDecision struct {
+	// Name is the field's full name
+	Name  protoreflect.FullName
+	Field *descriptorpb.FieldDescriptorProto
+	// Elem is the field's element message, and Size its estimate
+	Elem       protoreflect.MessageDescriptor
+	Size       Size
+	ValueSlice bool
+}
+
+// hasValueSlice reports whether field sets value_slice, to true or false,
+// in either of its forms
+func hasValueSlice(field *descriptorpb.FieldDescriptorProto) bool {
+	if field.Options == nil {
+		return false
+	}
+	return proto.HasExtension(field.Options, protogo_values.E_ValueSlice) || StructuredOptions(field.Options).ValueSlice != nil
+}
+
+// valueSlicePolicy returns the value_slice_policy in effect for field,
+// declared in file: the field's own, or else the file's
+func valueSlicePolicy(file *descriptorpb.FileDescriptorProto, field *descriptorpb.FieldDescriptorProto) protogo_values.ValueSlicePolicy {
+	if policy := StructuredOptions(field.GetOptions()).GetValueSlicePolicy(); policy != protogo_values.ValueSlicePolicy_VALUE_SLICE_POLICY_UNSPECIFIED {
+		return policy
+	}
+	return FileLevelOptions(file.GetOptions()).GetValueSlicePolicy()
+}
+
+// validateValueSlicePolicy rejects a field-level value_slice_policy on
+// anything but a repeated message field, or together with value_slice
+func validateValueSlicePolicy(msg *descriptorpb.DescriptorProto, field *descriptorpb.FieldDescriptorProto) error {
+	if StructuredOptions(field.GetOptions()).ValueSlicePolicy == nil {
+		return nil
+	}
+	if field.GetLabel() != descriptorpb.FieldDescriptorProto_LABEL_REPEATED || !isMessageField(field) || findMapEntry(msg, field) != nil {
+		return fmt.Errorf("field %s: value_slice_policy can only be used on repeated message fields", field.GetName())
+	}
+	if hasValueSlice(field) {
+		return fmt.Errorf("field %s: value_slice_policy cannot be combined with value_slice", field.GetName())
+	}
+	return nil
+}
+
+// AutoDecisions decides value_slice for every field of file that
+// VALUE_SLICE_POLICY_AUTO applies to: repeated message fields without an
+// explicit value_slice, in every message FindAnnotatedFields processes,
+// nested ones included.
+// Element types are resolved in files; an element is made a value slice if
+// it is not recursive and its estimated size is at most maxBytes.
+func AutoDecisions(file *descriptorpb.FileDescriptorProto, files *protoregistry.Files, maxBytes int) ([]AutoDecision, error) {
+	var decisions []AutoDecision
+	var walk func(msg *descriptorpb.DescriptorProto, name protoreflect.FullName) error
+	walk = func(msg *descriptorpb.DescriptorProto, name protoreflect.FullName) error {
+		for _, field := range msg.Field {
+			if field.GetLabel() != descriptorpb.FieldDescriptorProto_LABEL_REPEATED || !isMessageField(field) ||
+				findMapEntry(msg, field) != nil || hasValueSlice(field) ||
+				valueSlicePolicy(file, field) != protogo_values.ValueSlicePolicy_VALUE_SLICE_POLICY_AUTO {
+				continue
+			}
+			elemName := protoreflect.FullName(strings.TrimPrefix(field.GetTypeName(), "."))
+			desc, err := files.FindDescriptorByName(elemName)
+			if err != nil {
+				return at(field, fmt.Errorf("field %s: cannot resolve element type %s: %w", field.GetName(), elemName, err))
+			}
+			elem, ok := desc.(protoreflect.MessageDescriptor)
+			if !ok {
+				return at(field, fmt.Errorf("field %s: element type %s is not a message", field.GetName(), elemName))
+			}
+			size := EstimateSize(elem)
+			decisions = append(decisions, AutoDecision{
+				Name:       name.Append(protoreflect.Name(field.GetName())),
+				Field:      field,
+				Elem:       elem,
+				Size:       size,
+				ValueSlice: !size.Recursive && size.Bytes <= maxBytes,
+			})
+		}
+		for _, nested := range msg.NestedType {
+			if nested.GetOptions().GetMapEntry() {
+				continue
+			}
+			if err := walk(nested, name.Append(protoreflect.Name(nested.GetName()))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	pkg := protoreflect.FullName(file.GetPackage())
+	for _, msg := range file.MessageType {
+		if err := walk(msg, pkg.Append(protoreflect.Name(msg.GetName()))); err != nil {
+			return nil, err
+		}
+	}
+	return decisions, nil
+}
+
+// ApplyValueSlicePolicy decides value_slice for every field of req that
+// VALUE_SLICE_POLICY_AUTO applies to, and records each decision in place as
+// the field's (protogo_values.field_opts).value_slice, so that the parser
+// and the generator see it as explicit. It returns a note for each decision
+// in the files to generate. It should run after protoc-gen-go, whose
+// embedded descriptors then match the proto source.
+func ApplyValueSlicePolicy(req *pluginpb.CodeGeneratorRequest, maxBytes int) ([]*diag.Diagnostic, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	var files *protoregistry.Files
+	var notes []*diag.Diagnostic
+	for _, protoFile := range req.ProtoFile {
+		if !usesAutoPolicy(protoFile) {
+			continue
+		}
+		// Element types may come from any file, so resolve the request
+		// once, and only if the policy is used at all
+		if files == nil {
+			var err error
+			files, err = protodesc.NewFiles(&descriptorpb.FileDescriptorSet{File: req.ProtoFile})
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve element types: %w", err)
+			}
+		}
+
+		positions := diag.SourcePositions(protoFile)
+		decisions, err := AutoDecisions(protoFile, files, maxBytes)
+		if err != nil {
+			return nil, locate(protoFile, positions, err)
+		}
+		generate := slices.Contains(req.FileToGenerate, protoFile.GetName())
+		for _, d := range decisions {
+			setValueSlice(d.Field, d.ValueSlice)
+			if !generate {
+				continue
+			}
+			pos, ok := positions[d.Field]
+			if !ok {
+				pos = diag.Position{File: protoFile.GetName()}
+			}
+			notes = append(notes, autoNote(pos, d, maxBytes))
+		}
+	}
+	return notes, nil
+}
+
+// usesAutoPolicy reports whether file sets VALUE_SLICE_POLICY_AUTO at file
+// level or on any field
+func usesAutoPolicy(file *descriptorpb.FileDescriptorProto) bool {
+	auto := protogo_values.ValueSlicePolicy_VALUE_SLICE_POLICY_AUTO
+	if FileLevelOptions(file.GetOptions()).GetValueSlicePolicy() == auto {
+		return true
+	}
+	var walk func(messages []*descriptorpb.DescriptorProto) bool
+	walk = func(messages []*descriptorpb.DescriptorProto) bool {
+		for _, msg := range messages {
+			for _, field := range msg.Field {
+				if StructuredOptions(field.GetOptions()).GetValueSlicePolicy() == auto {
+					return true
+				}
+			}
+			if walk(msg.NestedType) {
+				return true
+			}
+		}
+		return false
+	}
+	return walk(file.MessageType)
+}
+
+// setValueSlice replaces the value_slice_policy of field with an explicit
+// value_slice
+func setValueSlice(field *descriptorpb.FieldDescriptorProto, valueSlice bool) {
+	fieldOpts := proto.Clone(StructuredOptions(field.GetOptions())).(*protogo_values.FieldOptions)
+	fieldOpts.ValueSlice = proto.Bool(valueSlice)
+	fieldOpts.ValueSlicePolicy = nil
+	if field.Options == nil {
+		field.Options = &descriptorpb.FieldOptions{}
+	}
+	proto.SetExtension(field.Options, protogo_values.E_FieldOpts, fieldOpts)
+}
+
+// autoNote explains the decision d, made with the limit maxBytes
+func autoNote(pos diag.Position, d AutoDecision, maxBytes int) *diag.Diagnostic {
+	name := d.Field.GetName()
+	switch {
+	case d.ValueSlice:
+		return diag.Notef(pos, "value_slice_policy AUTO made %s a value slice: %s is about %d bytes, within auto_max_bytes=%d", name, d.Elem.FullName(), d.Size.Bytes, maxBytes)
+	case d.Size.Recursive:
+		return diag.Notef(pos, "value_slice_policy AUTO left %s a pointer slice: %s is recursive", name, d.Elem.FullName())
+	default:
+		return diag.Notef(pos, "value_slice_policy AUTO left %s a pointer slice: %s is about %d bytes, over auto_max_bytes=%d", name, d.Elem.FullName(), d.Size.Bytes, maxBytes)
+	}
+}
@@ -0,0 +1,179 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/benjamin-rood/protogo-values/proto/protogo_values"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// policyRequest returns a request for a file whose Route message, and the
+// Leg message nested in it, have repeated fields of a flat Point and a
+// recursive Category, with the given file-level value_slice_policy
+func policyRequest(policy protogo_values.ValueSlicePolicy) *pluginpb.CodeGeneratorRequest {
+	repeated := func(name string, number int32, typeName string) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			Number:   proto.Int32(number),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+			TypeName: proto.String(typeName),
+		}
+	}
+	optedOut := repeated("waypoints", 3, ".geo.Point")
+	optedOut.Options = &descriptorpb.FieldOptions{}
+	proto.SetExtension(optedOut.Options, protogo_values.E_ValueSlice, false)
+
+	fileOpts := &descriptorpb.FileOptions{GoPackage: proto.String("example.com/geo")}
+	proto.SetExtension(fileOpts, protogo_values.E_FileOpts, &protogo_values.FileOptions{ValueSlicePolicy: policy.Enum()})
+	return &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{"geo.proto"},
+		ProtoFile: []*descriptorpb.FileDescriptorProto{{
+			Name:    proto.String("geo.proto"),
+			Package: proto.String("geo"),
+			Syntax:  proto.String("proto3"),
+			Options: fileOpts,
+			MessageType: []*descriptorpb.DescriptorProto{
+				{
+					Name: proto.String("Point"),
+					Field: []*descriptorpb.FieldDescriptorProto{{
+						Name:   proto.String("x"),
+						Number: proto.Int32(1),
+						Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+						Type:   descriptorpb.FieldDescriptorProto_TYPE_DOUBLE.Enum(),
+					}},
+				},
+				{
+					Name:  proto.String("Category"),
+					Field: []*descriptorpb.FieldDescriptorProto{repeated("children", 1, ".geo.Category")},
+				},
+				{
+					Name: proto.String("Route"),
+					Field: []*descriptorpb.FieldDescriptorProto{
+						repeated("stops", 1, ".geo.Point"),
+						repeated("categories", 2, ".geo.Category"),
+						optedOut,
+					},
+					NestedType: []*descriptorpb.DescriptorProto{{
+						Name:  proto.String("Leg"),
+						Field: []*descriptorpb.FieldDescriptorProto{repeated("turns", 1, ".geo.Point")},
+					}},
+				},
+			},
+		}},
+	}
+}
+
+func TestApplyValueSlicePolicy(t *testing.T) {
+	req := policyRequest(protogo_values.ValueSlicePolicy_VALUE_SLICE_POLICY_AUTO)
+	notes, err := ApplyValueSlicePolicy(req, DefaultAutoMaxBytes)
+	if err != nil {
+		t.Fatalf("ApplyValueSlicePolicy() failed: %v", err)
+	}
+	want := []string{
+		"geo.proto: note: value_slice_policy AUTO left children a pointer slice: geo.Category is recursive",
+		"geo.proto: note: value_slice_policy AUTO made stops a value slice: geo.Point is about 48 bytes, within auto_max_bytes=128",
+		"geo.proto: note: value_slice_policy AUTO left categories a pointer slice: geo.Category is recursive",
+		"geo.proto: note: value_slice_policy AUTO made turns a value slice: geo.Point is about 48 bytes, within auto_max_bytes=128",
+	}
+	if len(notes) != len(want) {
+		t.Fatalf("ApplyValueSlicePolicy() returned %d notes, want %d: %v", len(notes), len(want), notes)
+	}
+	for i, note := range notes {
+		if note.Error() != want[i] {
+			t.Errorf("note %d = %q, want %q", i, note.Error(), want[i])
+		}
+	}
+
+	// The decisions are now explicit, and the opted-out field is untouched
+	route := req.ProtoFile[0].MessageType[2]
+	for _, tt := range []struct {
+		field int
+		want  bool
+	}{{0, true}, {1, false}, {2, false}} {
+		field := route.Field[tt.field]
+		if !hasValueSlice(field) || IsValueSlice(field) != tt.want {
+			t.Errorf("field %s: value_slice set %v = %v, want set to %v", field.GetName(), hasValueSlice(field), IsValueSlice(field), tt.want)
+		}
+	}
+	fields, err := FindAnnotatedFields(req)
+	if err != nil {
+		t.Fatalf("FindAnnotatedFields() failed: %v", err)
+	}
+	if !fields.Contains("Stops") || !fields.Contains("Turns") || fields.Contains("Categories") {
+		t.Errorf("FindAnnotatedFields() = %v, want Stops and the nested Turns", fields.Names())
+	}
+
+	// A lower limit leaves the flat element a pointer slice too
+	req = policyRequest(protogo_values.ValueSlicePolicy_VALUE_SLICE_POLICY_AUTO)
+	if _, err := ApplyValueSlicePolicy(req, 32); err != nil {
+		t.Fatalf("ApplyValueSlicePolicy() failed: %v", err)
+	}
+	if IsValueSlice(req.ProtoFile[0].MessageType[2].Field[0]) {
+		t.Error("stops is a value slice with auto_max_bytes=32")
+	}
+
+	// A misplaced field-level policy in a nested message is rejected
+	req = policyRequest(protogo_values.ValueSlicePolicy_VALUE_SLICE_POLICY_EXPLICIT)
+	turns := req.ProtoFile[0].MessageType[2].NestedType[0].Field[0]
+	turns.Label = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	turns.Options = &descriptorpb.FieldOptions{}
+	proto.SetExtension(turns.Options, protogo_values.E_FieldOpts, &protogo_values.FieldOptions{
+		ValueSlicePolicy: protogo_values.ValueSlicePolicy_VALUE_SLICE_POLICY_AUTO.Enum(),
+	})
+	if _, err := FindAnnotatedFields(req); err == nil || !strings.Contains(err.Error(), "can only be used on repeated message fields") {
+		t.Errorf("FindAnnotatedFields() error = %v, want one rejecting the nested field's policy", err)
+	}
+
+	// Files that do not use the policy are left alone
+	req = policyRequest(protogo_values.ValueSlicePolicy_VALUE_SLICE_POLICY_EXPLICIT)
+	notes, err = ApplyValueSlicePolicy(req, DefaultAutoMaxBytes)
+	if err != nil || len(notes) != 0 {
+		t.Errorf("ApplyValueSlicePolicy() = %v, %v, want no notes", notes, err)
+	}
+	if hasValueSlice(req.ProtoFile[0].MessageType[2].Field[0]) {
+		t.Error("stops was given a value_slice without the AUTO policy")
+	}
+}
+
+func TestValidateValueSlicePolicy(t *testing.T) {
+	auto := func(field *descriptorpb.FieldDescriptorProto) *descriptorpb.FieldDescriptorProto {
+		field.Options = &descriptorpb.FieldOptions{}
+		proto.SetExtension(field.Options, protogo_values.E_FieldOpts, &protogo_values.FieldOptions{
+			ValueSlicePolicy: protogo_values.ValueSlicePolicy_VALUE_SLICE_POLICY_AUTO.Enum(),
+		})
+		return field
+	}
+	scalar := auto(&descriptorpb.FieldDescriptorProto{
+		Name:  proto.String("name"),
+		Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:  descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+	})
+	both := auto(&descriptorpb.FieldDescriptorProto{
+		Name:     proto.String("stops"),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+		Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+		TypeName: proto.String(".geo.Point"),
+	})
+	proto.SetExtension(both.Options, protogo_values.E_ValueSlice, true)
+
+	tests := []struct {
+		name    string
+		field   *descriptorpb.FieldDescriptorProto
+		wantErr string
+	}{
+		{"scalar", scalar, "can only be used on repeated message fields"},
+		{"with value_slice", both, "cannot be combined with value_slice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateValueSlicePolicy(&descriptorpb.DescriptorProto{}, tt.field)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateValueSlicePolicy() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/benjamin-rood/protogo-values/proto/protogo_values"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// MessageLevelOptions returns the (protogo_values.message_opts) extension of
+// the given message options, or an empty MessageOptions if it is not set.
+func MessageLevelOptions(opts *descriptorpb.MessageOptions) *protogo_values.MessageOptions {
+	if opts == nil || !proto.HasExtension(opts, protogo_values.E_MessageOpts) {
+		return &protogo_values.MessageOptions{}
+	}
+	msgOpts := proto.GetExtension(opts, protogo_values.E_MessageOpts).(*protogo_values.MessageOptions)
+	if msgOpts == nil {
+		return &protogo_values.MessageOptions{}
+	}
+	return msgOpts
+}
+
+// inRealOneof reports whether field belongs to a oneof declared in the
+// proto source, as opposed to the synthetic oneof protoc creates for a
+// proto3 optional field
+func inRealOneof(field *descriptorpb.FieldDescriptorProto) bool {
+	return field.OneofIndex != nil && !field.GetProto3Optional()
+}
+
+// HasExplicitPresence reports whether field, declared in file, tracks
+// whether it is set: message fields, oneof members (including proto3
+// optional), proto2 singular fields, and editions fields whose resolved
+// field_presence is not IMPLICIT. Editions features are resolved from the
+// field and the file.
+func HasExplicitPresence(file *descriptorpb.FileDescriptorProto, field *descriptorpb.FieldDescriptorProto) bool {
+	if field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
+		return false
+	}
+	switch field.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, descriptorpb.FieldDescriptorProto_TYPE_GROUP:
+		return true
+	}
+	if field.OneofIndex != nil {
+		return true
+	}
+
+	switch file.GetSyntax() {
+	case "proto3":
+		return false
+	case "editions":
+		presence := file.GetOptions().GetFeatures().GetFieldPresence()
+		if p := field.GetOptions().GetFeatures().GetFieldPresence(); p != descriptorpb.FeatureSet_FIELD_PRESENCE_UNKNOWN {
+			presence = p
+		}
+		return presence != descriptorpb.FeatureSet_IMPLICIT
+	}
+	// proto2, which protoc reports as an empty syntax
+	return true
+}
+
+// isPresenceScalar reports whether field is a scalar whose presence a mirror
+// struct has to represent. Bytes are excluded since nil already means unset.
+func isPresenceScalar(file *descriptorpb.FileDescriptorProto, field *descriptorpb.FieldDescriptorProto) bool {
+	switch field.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_MESSAGE,
+		descriptorpb.FieldDescriptorProto_TYPE_GROUP,
+		descriptorpb.FieldDescriptorProto_TYPE_BYTES:
+		return false
+	}
+	return !inRealOneof(field) && HasExplicitPresence(file, field)
+}
+
+// validatePresence checks the presence setting of msg and its nested
+// messages: it only affects explicit-presence scalars, so setting it on a
+// message without any is almost certainly a mistake
+func validatePresence(file *descriptorpb.FileDescriptorProto, msg *descriptorpb.DescriptorProto) error {
+	if MessageLevelOptions(msg.GetOptions()).Presence != nil {
+		found := false
+		for _, field := range msg.Field {
+			if isPresenceScalar(file, field) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return at(msg, fmt.Errorf("message %s: presence is set but the message has no scalar fields with explicit presence", msg.GetName()))
+		}
+	}
+
+	for _, nested := range msg.NestedType {
+		if err := validatePresence(file, nested); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/benjamin-rood/protogo-values/proto/protogo_values"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestHasExplicitPresence(t *testing.T) {
+	scalar := func(label descriptorpb.FieldDescriptorProto_Label) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:  proto.String("count"),
+			Label: label.Enum(),
+			Type:  descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(),
+		}
+	}
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+
+	proto3Optional := scalar(optional)
+	proto3Optional.OneofIndex = proto.Int32(0)
+	proto3Optional.Proto3Optional = proto.Bool(true)
+
+	message := scalar(optional)
+	message.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+
+	implicitField := scalar(optional)
+	implicitField.Options = &descriptorpb.FieldOptions{
+		Features: &descriptorpb.FeatureSet{FieldPresence: descriptorpb.FeatureSet_IMPLICIT.Enum()},
+	}
+
+	proto2 := &descriptorpb.FileDescriptorProto{}
+	proto3 := &descriptorpb.FileDescriptorProto{Syntax: proto.String("proto3")}
+	editions := &descriptorpb.FileDescriptorProto{Syntax: proto.String("editions")}
+	implicitEditions := &descriptorpb.FileDescriptorProto{
+		Syntax: proto.String("editions"),
+		Options: &descriptorpb.FileOptions{
+			Features: &descriptorpb.FeatureSet{FieldPresence: descriptorpb.FeatureSet_IMPLICIT.Enum()},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		file  *descriptorpb.FileDescriptorProto
+		field *descriptorpb.FieldDescriptorProto
+		want  bool
+	}{
+		{"proto2 optional", proto2, scalar(optional), true},
+		{"proto2 repeated", proto2, scalar(repeated), false},
+		{"proto3 singular", proto3, scalar(optional), false},
+		{"proto3 optional", proto3, proto3Optional, true},
+		{"proto3 message", proto3, message, true},
+		{"editions default", editions, scalar(optional), true},
+		{"editions implicit file", implicitEditions, scalar(optional), false},
+		{"editions implicit field", editions, implicitField, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HasExplicitPresence(tt.file, tt.field); got != tt.want {
+				t.Errorf("HasExplicitPresence() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePresence(t *testing.T) {
+	withPresence := func(fields ...*descriptorpb.FieldDescriptorProto) *descriptorpb.DescriptorProto {
+		opts := &descriptorpb.MessageOptions{}
+		proto.SetExtension(opts, protogo_values.E_MessageOpts, &protogo_values.MessageOptions{
+			Presence: protogo_values.PresenceStyle_PRESENCE_STYLE_HAS_FLAG.Enum(),
+		})
+		return &descriptorpb.DescriptorProto{Name: proto.String("Account"), Field: fields, Options: opts}
+	}
+	name := &descriptorpb.FieldDescriptorProto{
+		Name:  proto.String("name"),
+		Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:  descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+	}
+	proto3 := &descriptorpb.FileDescriptorProto{Syntax: proto.String("proto3")}
+
+	if err := validatePresence(&descriptorpb.FileDescriptorProto{}, withPresence(name)); err != nil {
+		t.Errorf("validatePresence() unexpected error for proto2 optional field: %v", err)
+	}
+	if err := validatePresence(proto3, withPresence(name)); err == nil {
+		t.Error("validatePresence() expected error for a message without explicit-presence scalars")
+	}
+
+	nested := &descriptorpb.DescriptorProto{
+		Name:       proto.String("Outer"),
+		NestedType: []*descriptorpb.DescriptorProto{withPresence(name)},
+	}
+	if err := validatePresence(proto3, nested); err == nil {
+		t.Error("validatePresence() expected error for a nested message")
+	}
+}
@@ -0,0 +1,129 @@
+package parser
+
+import (
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Size estimates the Go struct protoc-gen-go generates for a message, on a
+// 64-bit platform
+type Size struct {
+	// Bytes is the size of the struct itself: the message state, scalar
+	// fields at their Go widths and headers for strings, bytes, slices and
+	// maps. Message fields count as pointers, so Bytes is what one element
+	// of a value slice occupies.
+	Bytes int
+	// Depth is how deeply message fields nest below the message: 0 for a
+	// flat message, 1 for one whose message fields are flat, and so on
+	Depth int
+	// Recursive reports whether the message reaches a message that contains
+	// itself, in which case Depth is not meaningful
+	Recursive bool
+}
+
+// Go widths of the generated fields, in bytes
+const (
+	pointerSize   = 8
+	stringSize    = 16
+	sliceSize     = 24
+	interfaceSize = 16
+	// messageHeader covers the state, sizeCache and unknownFields fields
+	// every generated struct starts with
+	messageHeader = pointerSize + 4 + 4 + sliceSize
+)
+
+// EstimateSize estimates the Go struct of md and how deeply its message
+// fields nest
+func EstimateSize(md protoreflect.MessageDescriptor) Size {
+	size := Size{Bytes: structBytes(md)}
+	size.Depth, size.Recursive = nestingDepth(md, make(map[protoreflect.FullName]bool), make(map[protoreflect.FullName]int))
+	return size
+}
+
+// structBytes lays out the fields of md in the order protoc-gen-go declares
+// them, with Go's alignment rules
+func structBytes(md protoreflect.MessageDescriptor) int {
+	offset := messageHeader
+	if md.ExtensionRanges().Len() > 0 {
+		offset += pointerSize
+	}
+	seen := make(map[protoreflect.FullName]bool)
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		width, align := fieldWidth(field)
+		if oneof := field.ContainingOneof(); oneof != nil && !oneof.IsSynthetic() {
+			// A oneof is one interface field, where its first member is
+			if seen[oneof.FullName()] {
+				continue
+			}
+			seen[oneof.FullName()] = true
+			width, align = interfaceSize, pointerSize
+		}
+		offset = (offset+align-1)/align*align + width
+	}
+	return (offset + pointerSize - 1) / pointerSize * pointerSize
+}
+
+// fieldWidth returns the Go width and alignment of field outside a oneof
+func fieldWidth(field protoreflect.FieldDescriptor) (width, align int) {
+	switch {
+	case field.IsMap():
+		return pointerSize, pointerSize
+	case field.IsList():
+		return sliceSize, pointerSize
+	}
+	switch field.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return pointerSize, pointerSize
+	case protoreflect.BytesKind:
+		return sliceSize, pointerSize
+	}
+	if field.HasPresence() {
+		// Optional scalars are generated as pointers
+		return pointerSize, pointerSize
+	}
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		return 1, 1
+	case protoreflect.StringKind:
+		return stringSize, pointerSize
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind, protoreflect.DoubleKind:
+		return 8, 8
+	}
+	// 32-bit integers, enums and floats
+	return 4, 4
+}
+
+// nestingDepth returns how deeply message fields nest below md, and whether
+// one of them leads back to a message on the current path. Depths already
+// computed are kept in depths.
+func nestingDepth(md protoreflect.MessageDescriptor, path map[protoreflect.FullName]bool, depths map[protoreflect.FullName]int) (int, bool) {
+	if depth, ok := depths[md.FullName()]; ok {
+		return depth, false
+	}
+	if path[md.FullName()] {
+		return 0, true
+	}
+	path[md.FullName()] = true
+	defer delete(path, md.FullName())
+
+	depth := 0
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if field.IsMap() {
+			field = field.MapValue()
+		}
+		if field.Message() == nil {
+			continue
+		}
+		nested, recursive := nestingDepth(field.Message(), path, depths)
+		if recursive {
+			return 0, true
+		}
+		depth = max(depth, nested+1)
+	}
+	depths[md.FullName()] = depth
+	return depth, false
+}
@@ -0,0 +1,48 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestEstimateSize(t *testing.T) {
+	tests := []struct {
+		name      string
+		msg       proto.Message
+		depth     int
+		recursive bool
+	}{
+		{name: "int64 and int32", msg: &durationpb.Duration{}},
+		{name: "bool", msg: &wrapperspb.BoolValue{}},
+		{name: "string", msg: &wrapperspb.StringValue{}},
+		{name: "oneof", msg: &structpb.Value{}, recursive: true},
+		{name: "map", msg: &structpb.Struct{}, recursive: true},
+		{name: "proto2 optional scalars", msg: &descriptorpb.FieldDescriptorProto{}, depth: 3},
+		{name: "extension ranges", msg: &descriptorpb.FieldOptions{}, depth: 2},
+		{name: "nested messages", msg: &descriptorpb.SourceCodeInfo{}, depth: 1},
+		{name: "self reference", msg: &descriptorpb.DescriptorProto{}, recursive: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EstimateSize(tt.msg.ProtoReflect().Descriptor())
+			// The generated struct is the reference for Bytes
+			want := int(reflect.TypeOf(tt.msg).Elem().Size())
+			if got.Bytes != want {
+				t.Errorf("EstimateSize().Bytes = %d, want %d", got.Bytes, want)
+			}
+			if got.Recursive != tt.recursive {
+				t.Errorf("EstimateSize().Recursive = %v, want %v", got.Recursive, tt.recursive)
+			}
+			if !tt.recursive && got.Depth != tt.depth {
+				t.Errorf("EstimateSize().Depth = %d, want %d", got.Depth, tt.depth)
+			}
+		})
+	}
+}
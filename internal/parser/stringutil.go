@@ -1,36 +1,41 @@
 package parser
 
-import (
-	"strings"
-	"unicode"
-)
-
-// toCamelCase converts snake_case to CamelCase
-func toCamelCase(s string) string {
-	if s == "" {
-		return s
-	}
-
-	parts := strings.Split(s, "_")
-	if len(parts) == 1 {
-		return capitalizeFirst(s)
-	}
-
-	result := capitalizeFirst(parts[0])
-	for _, part := range parts[1:] {
-		if part != "" {
-			result += capitalizeFirst(part)
+// goCamelCase converts a proto name to the Go identifier protoc-gen-go
+// derives from it, following protogen's rules: an underscore is dropped only
+// before a lowercase letter, digits do not start a new word, and a dot
+// separating nested names becomes an underscore unless a lowercase letter
+// follows it. For example, foo_1bar becomes Foo_1Bar and
+// Foo.bar_Baz becomes FooBar_Baz.
+func goCamelCase(s string) string {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '.' && i+1 < len(s) && isASCIILower(s[i+1]):
+			// Drop the dot in ".x"
+		case c == '.':
+			b = append(b, '_')
+		case c == '_' && (i == 0 || s[i-1] == '.'):
+			// A leading underscore would make the identifier unexported
+			b = append(b, 'X')
+		case c == '_' && i+1 < len(s) && isASCIILower(s[i+1]):
+			// Drop the underscore in "_x"
+		case isASCIIDigit(c):
+			b = append(b, c)
+		default:
+			// A word starts here: capitalise it and keep its lowercase tail
+			if isASCIILower(c) {
+				c -= 'a' - 'A'
+			}
+			b = append(b, c)
+			for ; i+1 < len(s) && isASCIILower(s[i+1]); i++ {
+				b = append(b, s[i+1])
+			}
 		}
 	}
-	return result
+	return string(b)
 }
 
-// capitalizeFirst capitalizes the first character of a string
-func capitalizeFirst(s string) string {
-	if s == "" {
-		return s
-	}
-	r := []rune(s)
-	r[0] = unicode.ToUpper(r[0])
-	return string(r)
-}
\ No newline at end of file
+func isASCIILower(c byte) bool { return 'a' <= c && c <= 'z' }
+
+func isASCIIDigit(c byte) bool { return '0' <= c && c <= '9' }
@@ -1,17 +1,111 @@
 package types
 
+import (
+	"maps"
+	"slices"
+)
+
 // AnnotatedFields holds the set of field names that should be converted from pointer slices to value slices
 type AnnotatedFields struct {
 	fields map[string]bool
+	// structTags maps a proto file path, then a Go struct name generated for
+	// it, to its field names and the extra tags to merge into their struct
+	// tags. Struct names are only unique within a Go package, so they are
+	// scoped by the file declaring them.
+	structTags map[string]map[string]map[string]string
+	// elemTypes maps a field name to the fully-qualified proto names of its
+	// element types
+	elemTypes map[string]map[string]bool
+	// goTypes maps a fully-qualified proto message name to its Go identifier
+	goTypes map[string]GoIdent
+	// importPaths maps a proto file path to its Go import path
+	importPaths map[string]string
+}
+
+// GoIdent is a Go identifier qualified by the import path of its package
+type GoIdent struct {
+	ImportPath string
+	Name       string
 }
 
 // NewAnnotatedFields creates a new AnnotatedFields instance
 func NewAnnotatedFields() *AnnotatedFields {
 	return &AnnotatedFields{
-		fields: make(map[string]bool),
+		fields:      make(map[string]bool),
+		structTags:  make(map[string]map[string]map[string]string),
+		elemTypes:   make(map[string]map[string]bool),
+		goTypes:     make(map[string]GoIdent),
+		importPaths: make(map[string]string),
 	}
 }
 
+// AddElemType records a fully-qualified proto message name, such as
+// ".pkg.User", as an element type of an annotated field
+func (af *AnnotatedFields) AddElemType(fieldName, typeName string) {
+	if af.elemTypes[fieldName] == nil {
+		af.elemTypes[fieldName] = make(map[string]bool)
+	}
+	af.elemTypes[fieldName][typeName] = true
+}
+
+// AddGoType records the Go identifier of a fully-qualified proto message name
+func (af *AnnotatedFields) AddGoType(typeName string, ident GoIdent) {
+	af.goTypes[typeName] = ident
+}
+
+// AddFileImportPath records the Go import path of a proto file
+func (af *AnnotatedFields) AddFileImportPath(protoPath, importPath string) {
+	af.importPaths[protoPath] = importPath
+}
+
+// FileImportPath returns the Go import path of a proto file, if known
+func (af *AnnotatedFields) FileImportPath(protoPath string) (string, bool) {
+	importPath, ok := af.importPaths[protoPath]
+	return importPath, ok
+}
+
+// ElemTypes returns the Go identifiers of a field's element types. ok is
+// false if no element type was recorded or any of them cannot be resolved.
+func (af *AnnotatedFields) ElemTypes(fieldName string) (idents []GoIdent, ok bool) {
+	if len(af.elemTypes[fieldName]) == 0 {
+		return nil, false
+	}
+	for typeName := range af.elemTypes[fieldName] {
+		ident, ok := af.goTypes[typeName]
+		if !ok {
+			return nil, false
+		}
+		idents = append(idents, ident)
+	}
+	return idents, true
+}
+
+// AddStructTags records extra struct tags for a field of a struct generated
+// for the proto file protoPath
+func (af *AnnotatedFields) AddStructTags(protoPath, structName, fieldName, tags string) {
+	if af.structTags[protoPath] == nil {
+		af.structTags[protoPath] = make(map[string]map[string]string)
+	}
+	if af.structTags[protoPath][structName] == nil {
+		af.structTags[protoPath][structName] = make(map[string]string)
+	}
+	af.structTags[protoPath][structName][fieldName] = tags
+}
+
+// StructTags returns the extra struct tags of the structs generated for the
+// proto file protoPath, keyed by struct name, then field name
+func (af *AnnotatedFields) StructTags(protoPath string) map[string]map[string]string {
+	// Return a copy to prevent external modification
+	result := make(map[string]map[string]string)
+	for structName, fields := range af.structTags[protoPath] {
+		result[structName] = make(map[string]string)
+		for k, v := range fields {
+			result[structName][k] = v
+		}
+	}
+	return result
+}
+
 // Add adds a field name to the set
 func (af *AnnotatedFields) Add(fieldName string) {
 	af.fields[fieldName] = true
@@ -32,6 +126,11 @@ func (af *AnnotatedFields) All() map[string]bool {
 	return result
 }
 
+// Names returns all field names in sorted order
+func (af *AnnotatedFields) Names() []string {
+	return slices.Sorted(maps.Keys(af.fields))
+}
+
 // Count returns the number of annotated fields
 func (af *AnnotatedFields) Count() int {
 	return len(af.fields)
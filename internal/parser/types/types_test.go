@@ -64,4 +64,54 @@ func TestAnnotatedFieldsEmpty(t *testing.T) {
 	if len(all) != 0 {
 		t.Errorf("Expected empty map, got %d items", len(all))
 	}
-}
\ No newline at end of file
+}
+func TestAnnotatedFieldsStructTags(t *testing.T) {
+	fields := NewAnnotatedFields()
+	fields.AddStructTags("profile.proto", "Profile", "UserId", `db:"user_id"`)
+	fields.AddStructTags("profile.proto", "Profile", "Name", `db:"name"`)
+	fields.AddStructTags("profile.proto", "Profile_Email", "Email", `db:"email"`)
+	fields.AddStructTags("other/profile.proto", "Profile", "Name", `db:"other_name"`)
+
+	tags := fields.StructTags("profile.proto")
+	if len(tags) != 2 || len(tags["Profile"]) != 2 {
+		t.Fatalf("unexpected struct tags: %v", tags)
+	}
+	if tags["Profile_Email"]["Email"] != `db:"email"` {
+		t.Errorf("expected Profile_Email.Email tags, got %v", tags)
+	}
+
+	// Test that StructTags returns a copy
+	tags["Profile"]["UserId"] = "modified"
+	if fields.StructTags("profile.proto")["Profile"]["UserId"] != `db:"user_id"` {
+		t.Error("StructTags should return a copy, not the original map")
+	}
+
+	// Structs of the same name in other files keep their own tags
+	if other := fields.StructTags("other/profile.proto"); len(other["Profile"]) != 1 || other["Profile"]["Name"] != `db:"other_name"` {
+		t.Errorf("unexpected struct tags for other/profile.proto: %v", other)
+	}
+	if unknown := fields.StructTags("unknown.proto"); len(unknown) != 0 {
+		t.Errorf("expected no struct tags for an unknown file, got %v", unknown)
+	}
+}
+
+func TestAnnotatedFieldsElemTypes(t *testing.T) {
+	fields := NewAnnotatedFields()
+	user := GoIdent{ImportPath: "example.com/common", Name: "User"}
+	fields.AddGoType(".common.User", user)
+
+	if _, ok := fields.ElemTypes("Members"); ok {
+		t.Error("Expected no element types for an unknown field")
+	}
+
+	fields.AddElemType("Members", ".common.User")
+	if idents, ok := fields.ElemTypes("Members"); !ok || len(idents) != 1 || idents[0] != user {
+		t.Errorf("Expected [%v], got %v (ok %v)", user, idents, ok)
+	}
+
+	// An unresolvable element type makes the field's types unknown
+	fields.AddElemType("Members", ".missing.User")
+	if _, ok := fields.ElemTypes("Members"); ok {
+		t.Error("Expected element types with an unresolved name to be unknown")
+	}
+}
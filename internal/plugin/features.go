@@ -0,0 +1,172 @@
+package plugin
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/benjamin-rood/protogo-values/internal/diag"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/gofeaturespb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// capabilities are the protobuf language features a plugin can generate
+// code for, as reported in its CodeGeneratorResponse
+type capabilities struct {
+	features   uint64
+	minEdition descriptorpb.Edition
+	maxEdition descriptorpb.Edition
+}
+
+// ownCapabilities returns what the transformer and sibling generator
+// handle. Editions beyond 2023 default to the opaque Go API, whose unexported
+// struct fields cannot be rewritten.
+func ownCapabilities() capabilities {
+	return capabilities{
+		features: uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL |
+			pluginpb.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS),
+		minEdition: descriptorpb.Edition_EDITION_PROTO2,
+		maxEdition: descriptorpb.Edition_EDITION_2023,
+	}
+}
+
+// responseCapabilities returns the capabilities protoc-gen-go reported in
+// resp. A delegate that reports no editions bounds supports no editions.
+func responseCapabilities(resp *pluginpb.CodeGeneratorResponse) capabilities {
+	caps := capabilities{features: resp.GetSupportedFeatures()}
+	if resp.MinimumEdition == nil || resp.MaximumEdition == nil {
+		caps.features &^= uint64(pluginpb.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS)
+		return caps
+	}
+	caps.minEdition = descriptorpb.Edition(resp.GetMinimumEdition())
+	caps.maxEdition = descriptorpb.Edition(resp.GetMaximumEdition())
+	return caps
+}
+
+// intersect returns the capabilities supported by both c and other
+func (c capabilities) intersect(other capabilities) capabilities {
+	caps := capabilities{
+		features:   c.features & other.features,
+		minEdition: max(c.minEdition, other.minEdition),
+		maxEdition: min(c.maxEdition, other.maxEdition),
+	}
+	if !caps.supports(pluginpb.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS) || caps.minEdition > caps.maxEdition {
+		caps.features &^= uint64(pluginpb.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS)
+		caps.minEdition, caps.maxEdition = 0, 0
+	}
+	return caps
+}
+
+func (c capabilities) supports(feature pluginpb.CodeGeneratorResponse_Feature) bool {
+	return c.features&uint64(feature) != 0
+}
+
+// apply reports c in resp, replacing what protoc-gen-go reported
+func (c capabilities) apply(resp *pluginpb.CodeGeneratorResponse) {
+	resp.SupportedFeatures = proto.Uint64(c.features)
+	resp.MinimumEdition, resp.MaximumEdition = nil, nil
+	if c.supports(pluginpb.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS) {
+		resp.MinimumEdition = proto.Int32(int32(c.minEdition))
+		resp.MaximumEdition = proto.Int32(int32(c.maxEdition))
+	}
+}
+
+// check verifies that the files req asks to generate use only features in
+// c, naming the first unsupported one it finds
+func (c capabilities) check(req *pluginpb.CodeGeneratorRequest) error {
+	if level, ok := defaultAPILevel(req.GetParameter()); ok && level != "API_OPEN" {
+		return fmt.Errorf("default_api_level=%s is not supported: value slices need the open Go API", level)
+	}
+
+	generate := make(map[string]bool)
+	for _, name := range req.FileToGenerate {
+		generate[name] = true
+	}
+	for _, file := range req.ProtoFile {
+		if !generate[file.GetName()] {
+			continue
+		}
+		if err := c.checkFile(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkFile verifies that file uses only features in c
+func (c capabilities) checkFile(file *descriptorpb.FileDescriptorProto) error {
+	pos := diag.Position{File: file.GetName()}
+	if file.GetSyntax() == "editions" {
+		edition := file.GetEdition()
+		switch {
+		case !c.supports(pluginpb.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS):
+			return diag.New(pos, fmt.Errorf("editions are not supported by this protoc-gen-go"))
+		case edition < c.minEdition || edition > c.maxEdition:
+			return diag.New(pos, fmt.Errorf("%s is not supported: editions %s to %s are",
+				editionName(edition), editionName(c.minEdition), editionName(c.maxEdition)))
+		}
+	}
+	if err := checkAPILevel(file.GetOptions().GetFeatures()); err != nil {
+		return diag.New(pos, err)
+	}
+
+	positions := diag.SourcePositions(file)
+	var walk func(msgs []*descriptorpb.DescriptorProto) error
+	walk = func(msgs []*descriptorpb.DescriptorProto) error {
+		for _, msg := range msgs {
+			if err := checkAPILevel(msg.GetOptions().GetFeatures()); err != nil {
+				return diag.New(positions[msg], fmt.Errorf("message %s: %w", msg.GetName(), err))
+			}
+			if !c.supports(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL) {
+				for _, field := range msg.Field {
+					if field.GetProto3Optional() {
+						return diag.New(positions[field], fmt.Errorf("proto3 optional fields are not supported by this protoc-gen-go"))
+					}
+				}
+			}
+			if err := walk(msg.NestedType); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(file.MessageType)
+}
+
+// checkAPILevel rejects Go API levels other than the open API, since the
+// hybrid and opaque APIs hide the struct fields the transformer rewrites
+func checkAPILevel(features *descriptorpb.FeatureSet) error {
+	if features == nil || !proto.HasExtension(features, gofeaturespb.E_Go) {
+		return nil
+	}
+	goFeatures := proto.GetExtension(features, gofeaturespb.E_Go).(*gofeaturespb.GoFeatures)
+	switch level := goFeatures.GetApiLevel(); level {
+	case gofeaturespb.GoFeatures_API_LEVEL_UNSPECIFIED, gofeaturespb.GoFeatures_API_OPEN:
+		return nil
+	default:
+		return fmt.Errorf("features.(pb.go).api_level = %s is not supported: value slices need the open Go API", level)
+	}
+}
+
+// defaultAPILevel returns the value of protoc-gen-go's default_api_level
+// parameter, if set
+func defaultAPILevel(param string) (string, bool) {
+	for _, entry := range strings.Split(param, ",") {
+		if value, ok := strings.CutPrefix(entry, "default_api_level="); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// editionName formats an edition as it is written in proto sources
+func editionName(edition descriptorpb.Edition) string {
+	switch edition {
+	case descriptorpb.Edition_EDITION_PROTO2:
+		return "proto2"
+	case descriptorpb.Edition_EDITION_PROTO3:
+		return "proto3"
+	}
+	return "edition " + strings.TrimPrefix(edition.String(), "EDITION_")
+}
@@ -0,0 +1,206 @@
+package plugin
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/gofeaturespb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+const (
+	featureProto3Optional = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
+	featureEditions       = uint64(pluginpb.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS)
+)
+
+func TestCapabilitiesIntersect(t *testing.T) {
+	tests := []struct {
+		name     string
+		delegate *pluginpb.CodeGeneratorResponse
+		want     capabilities
+	}{
+		{
+			name: "newer delegate",
+			delegate: &pluginpb.CodeGeneratorResponse{
+				SupportedFeatures: proto.Uint64(featureProto3Optional | featureEditions),
+				MinimumEdition:    proto.Int32(int32(descriptorpb.Edition_EDITION_PROTO2)),
+				MaximumEdition:    proto.Int32(int32(descriptorpb.Edition_EDITION_2024)),
+			},
+			want: capabilities{
+				features:   featureProto3Optional | featureEditions,
+				minEdition: descriptorpb.Edition_EDITION_PROTO2,
+				maxEdition: descriptorpb.Edition_EDITION_2023,
+			},
+		},
+		{
+			name: "delegate without editions",
+			delegate: &pluginpb.CodeGeneratorResponse{
+				SupportedFeatures: proto.Uint64(featureProto3Optional),
+			},
+			want: capabilities{features: featureProto3Optional},
+		},
+		{
+			name: "editions flag without bounds",
+			delegate: &pluginpb.CodeGeneratorResponse{
+				SupportedFeatures: proto.Uint64(featureEditions),
+			},
+			want: capabilities{},
+		},
+		{
+			name:     "delegate reporting nothing",
+			delegate: &pluginpb.CodeGeneratorResponse{},
+			want:     capabilities{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ownCapabilities().intersect(responseCapabilities(tt.delegate)); got != tt.want {
+				t.Errorf("intersect() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapabilitiesApply(t *testing.T) {
+	resp := &pluginpb.CodeGeneratorResponse{
+		MinimumEdition: proto.Int32(int32(descriptorpb.Edition_EDITION_PROTO2)),
+		MaximumEdition: proto.Int32(int32(descriptorpb.Edition_EDITION_2024)),
+	}
+	capabilities{features: featureProto3Optional}.apply(resp)
+	if resp.GetSupportedFeatures() != featureProto3Optional || resp.MinimumEdition != nil || resp.MaximumEdition != nil {
+		t.Errorf("apply() left features %d, editions %v to %v", resp.GetSupportedFeatures(), resp.MinimumEdition, resp.MaximumEdition)
+	}
+
+	ownCapabilities().apply(resp)
+	if resp.GetMaximumEdition() != int32(descriptorpb.Edition_EDITION_2023) {
+		t.Errorf("apply() maximum edition = %d, want 2023", resp.GetMaximumEdition())
+	}
+}
+
+func TestCapabilitiesCheck(t *testing.T) {
+	apiLevel := func(level gofeaturespb.GoFeatures_APILevel) *descriptorpb.FeatureSet {
+		features := &descriptorpb.FeatureSet{}
+		proto.SetExtension(features, gofeaturespb.E_Go, &gofeaturespb.GoFeatures{ApiLevel: level.Enum()})
+		return features
+	}
+	editionsFile := func(edition descriptorpb.Edition) *descriptorpb.FileDescriptorProto {
+		return &descriptorpb.FileDescriptorProto{
+			Name:    proto.String("shop.proto"),
+			Syntax:  proto.String("editions"),
+			Edition: edition.Enum(),
+		}
+	}
+	optionalFile := &descriptorpb.FileDescriptorProto{
+		Name:   proto.String("shop.proto"),
+		Syntax: proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Order"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:           proto.String("note"),
+				Proto3Optional: proto.Bool(true),
+			}},
+		}},
+	}
+
+	tests := []struct {
+		name    string
+		caps    capabilities
+		file    *descriptorpb.FileDescriptorProto
+		param   string
+		wantErr string
+	}{
+		{
+			name: "edition 2023",
+			caps: ownCapabilities(),
+			file: editionsFile(descriptorpb.Edition_EDITION_2023),
+		},
+		{
+			name:    "edition 2024",
+			caps:    ownCapabilities(),
+			file:    editionsFile(descriptorpb.Edition_EDITION_2024),
+			wantErr: "shop.proto: edition 2024 is not supported: editions proto2 to edition 2023 are",
+		},
+		{
+			name:    "editions unsupported by the delegate",
+			caps:    capabilities{features: featureProto3Optional},
+			file:    editionsFile(descriptorpb.Edition_EDITION_2023),
+			wantErr: "editions are not supported",
+		},
+		{
+			name:    "proto3 optional unsupported by the delegate",
+			caps:    capabilities{},
+			file:    optionalFile,
+			wantErr: "proto3 optional fields are not supported",
+		},
+		{
+			name: "open API",
+			caps: ownCapabilities(),
+			file: &descriptorpb.FileDescriptorProto{
+				Name:    proto.String("shop.proto"),
+				Options: &descriptorpb.FileOptions{Features: apiLevel(gofeaturespb.GoFeatures_API_OPEN)},
+			},
+		},
+		{
+			name: "opaque API on a file",
+			caps: ownCapabilities(),
+			file: &descriptorpb.FileDescriptorProto{
+				Name:    proto.String("shop.proto"),
+				Options: &descriptorpb.FileOptions{Features: apiLevel(gofeaturespb.GoFeatures_API_OPAQUE)},
+			},
+			wantErr: "api_level = API_OPAQUE is not supported",
+		},
+		{
+			name: "hybrid API on a nested message",
+			caps: ownCapabilities(),
+			file: &descriptorpb.FileDescriptorProto{
+				Name: proto.String("shop.proto"),
+				MessageType: []*descriptorpb.DescriptorProto{{
+					Name: proto.String("Order"),
+					NestedType: []*descriptorpb.DescriptorProto{{
+						Name:    proto.String("Line"),
+						Options: &descriptorpb.MessageOptions{Features: apiLevel(gofeaturespb.GoFeatures_API_HYBRID)},
+					}},
+				}},
+			},
+			wantErr: "message Line: features.(pb.go).api_level = API_HYBRID is not supported",
+		},
+		{
+			name:    "opaque API by parameter",
+			caps:    ownCapabilities(),
+			file:    &descriptorpb.FileDescriptorProto{Name: proto.String("shop.proto")},
+			param:   "paths=source_relative,default_api_level=API_OPAQUE",
+			wantErr: "default_api_level=API_OPAQUE is not supported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &pluginpb.CodeGeneratorRequest{
+				FileToGenerate: []string{tt.file.GetName()},
+				ProtoFile:      []*descriptorpb.FileDescriptorProto{tt.file},
+				Parameter:      proto.String(tt.param),
+			}
+			err := tt.caps.check(req)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("check() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("check() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Imported files are not generated, so their features don't matter
+	req := &pluginpb.CodeGeneratorRequest{
+		ProtoFile: []*descriptorpb.FileDescriptorProto{editionsFile(descriptorpb.Edition_EDITION_2024)},
+	}
+	if err := ownCapabilities().check(req); err != nil {
+		t.Errorf("check() of an imported file: %v", err)
+	}
+}
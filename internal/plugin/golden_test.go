@@ -0,0 +1,205 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/benjamin-rood/protogo-values/internal/version"
+	"github.com/bufbuild/protocompile"
+	"github.com/bufbuild/protocompile/linker"
+	"golang.org/x/tools/txtar"
+	gengo "google.golang.org/protobuf/cmd/protoc-gen-go/internal_gengo"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// Golden fixtures are txtar archives in testdata/golden. Each holds:
+//
+//	-- params --       the plugin parameter
+//	-- generate --     the proto files to generate, one per line
+//	-- <path>.proto -- proto files of the fixture's own, which shadow the
+//	                   repository's; other imports resolve from its root
+//	-- out/<path> --   each file the plugin is expected to return
+//	-- diagnostics --  the error it is expected to report instead
+//	-- roundtrip/<path> --
+//	                   tests TestRoundTrip runs against the generated code,
+//	                   placed by the go_package path of the package they test
+const (
+	repoRoot       = "../.."
+	goldenTestdata = "../../testdata/golden"
+)
+
+var update = flag.Bool("update", false, "rewrite the expected output of the fixtures in testdata/golden")
+
+// goldenVersion stands in for the plugin version in provenance headers, so
+// that golden output does not depend on how the test binary was built
+const goldenVersion = "(golden)"
+
+// compileRequest compiles the files to generate in-process, the way protoc
+// would, and returns the request protoc would send
+func compileRequest(t *testing.T, protos map[string]string, generate []string, param string) *pluginpb.CodeGeneratorRequest {
+	t.Helper()
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(protocompile.CompositeResolver{
+			&protocompile.SourceResolver{Accessor: protocompile.SourceAccessorFromMap(protos)},
+			&protocompile.SourceResolver{ImportPaths: []string{repoRoot}},
+		}),
+		SourceInfoMode: protocompile.SourceInfoStandard,
+	}
+	files, err := compiler.Compile(context.Background(), generate...)
+	if err != nil {
+		t.Fatalf("failed to compile %v: %v", generate, err)
+	}
+
+	// protoc sends every file in dependency order, dependencies first
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: generate,
+		Parameter:      proto.String(param),
+	}
+	seen := make(map[string]bool)
+	var add func(file protoreflect.FileDescriptor)
+	add = func(file protoreflect.FileDescriptor) {
+		if seen[file.Path()] {
+			return
+		}
+		seen[file.Path()] = true
+		imports := file.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			add(imports.Get(i).FileDescriptor)
+		}
+		req.ProtoFile = append(req.ProtoFile, protodesc.ToFileDescriptorProto(file))
+	}
+	for _, file := range files {
+		add(file.(linker.Result))
+	}
+
+	// Custom options compile to dynamic messages; pass the request through
+	// the wire format, as protoc does, so they decode as the generated types
+	data, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatalf("failed to marshal request: %v", err)
+	}
+	wire := &pluginpb.CodeGeneratorRequest{}
+	if err := proto.Unmarshal(data, wire); err != nil {
+		t.Fatalf("failed to unmarshal request: %v", err)
+	}
+	return wire
+}
+
+// inProcessProtocGenGo runs the protoc-gen-go generator of the protobuf
+// module this repository is built with, without a subprocess
+func inProcessProtocGenGo(req *pluginpb.CodeGeneratorRequest, _ Params) (*pluginpb.CodeGeneratorResponse, error) {
+	gen, err := protogen.Options{}.New(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range gen.Files {
+		if file.Generate {
+			gengo.GenerateFile(gen, file)
+		}
+	}
+	gen.SupportedFeatures = gengo.SupportedFeatures
+	gen.SupportedEditionsMinimum = gengo.SupportedEditionsMinimum
+	gen.SupportedEditionsMaximum = gengo.SupportedEditionsMaximum
+	return gen.Response(), nil
+}
+
+// goldenSection returns the trimmed content of the named section of archive
+func goldenSection(archive *txtar.Archive, name string) (string, bool) {
+	for _, f := range archive.Files {
+		if f.Name == name {
+			return strings.TrimSpace(string(f.Data)), true
+		}
+	}
+	return "", false
+}
+
+// TestGolden runs the plugin end to end over every fixture in
+// testdata/golden, compiling protos and running protoc-gen-go in-process
+func TestGolden(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join(goldenTestdata, "*.txtar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no golden fixtures found")
+	}
+
+	version.Version = goldenVersion
+	t.Cleanup(func() { version.Version = "" })
+
+	for _, path := range paths {
+		t.Run(strings.TrimSuffix(filepath.Base(path), ".txtar"), func(t *testing.T) {
+			archive, err := txtar.ParseFile(path)
+			if err != nil {
+				t.Fatalf("failed to read fixture: %v", err)
+			}
+			param, _ := goldenSection(archive, "params")
+			generateList, ok := goldenSection(archive, "generate")
+			if !ok {
+				t.Fatalf("fixture has no generate section")
+			}
+			protos := make(map[string]string)
+			for _, f := range archive.Files {
+				if strings.HasSuffix(f.Name, ".proto") {
+					protos[f.Name] = string(f.Data)
+				}
+			}
+
+			req := compileRequest(t, protos, strings.Fields(generateList), param)
+			var got []txtar.File
+			resp, err := processRequest(req, inProcessProtocGenGo)
+			switch {
+			case err != nil:
+				got = append(got, txtar.File{Name: "diagnostics", Data: []byte(err.Error() + "\n")})
+			case resp.Error != nil:
+				got = append(got, txtar.File{Name: "diagnostics", Data: []byte(resp.GetError() + "\n")})
+			default:
+				for _, file := range resp.File {
+					got = append(got, txtar.File{Name: "out/" + file.GetName(), Data: []byte(file.GetContent())})
+				}
+			}
+
+			if *update {
+				files := slices.DeleteFunc(slices.Clone(archive.Files), func(f txtar.File) bool {
+					return f.Name == "diagnostics" || strings.HasPrefix(f.Name, "out/")
+				})
+				archive.Files = append(files, got...)
+				if err := os.WriteFile(path, txtar.Format(archive), 0o644); err != nil {
+					t.Fatalf("failed to write %s: %v", path, err)
+				}
+				return
+			}
+
+			want := make(map[string][]byte)
+			for _, f := range archive.Files {
+				if f.Name == "diagnostics" || strings.HasPrefix(f.Name, "out/") {
+					want[f.Name] = f.Data
+				}
+			}
+			for _, f := range got {
+				expected, ok := want[f.Name]
+				if !ok {
+					t.Errorf("unexpected %s:\n%s", f.Name, f.Data)
+					continue
+				}
+				delete(want, f.Name)
+				if !bytes.Equal(f.Data, expected) {
+					t.Errorf("%s differs from the fixture; rerun with -update and review the diff:\n%s", f.Name, f.Data)
+				}
+			}
+			for name := range want {
+				t.Errorf("missing %s", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package plugin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benjamin-rood/protogo-values/internal/parser"
+)
+
+// Defaults for the limits on the protoc-gen-go subprocess
+const (
+	DefaultDelegateTimeout   = time.Minute
+	DefaultDelegateMaxOutput = 256 << 20
+)
+
+// Params holds the plugin parameters protoc-gen-go-values handles itself.
+// All other parameters, such as paths and M mappings, are passed on to
+// protoc-gen-go.
+type Params struct {
+	// DelegateTimeout bounds how long protoc-gen-go may run; zero means no
+	// limit
+	DelegateTimeout time.Duration
+	// DelegateMaxOutput bounds the size in bytes of the response
+	// protoc-gen-go may write; zero means no limit
+	DelegateMaxOutput int64
+	// AutoMaxBytes is the largest estimated element size, in bytes, for
+	// which value_slice_policy AUTO generates a value slice
+	AutoMaxBytes int
+}
+
+// DefaultParams returns the parameters used when the request sets none
+func DefaultParams() Params {
+	return Params{
+		DelegateTimeout:   DefaultDelegateTimeout,
+		DelegateMaxOutput: DefaultDelegateMaxOutput,
+		AutoMaxBytes:      parser.DefaultAutoMaxBytes,
+	}
+}
+
+// ParseParams extracts the parameters handled by this plugin from a
+// comma-separated plugin parameter, returning them together with the
+// remaining parameter to pass on to protoc-gen-go
+func ParseParams(param string) (Params, string, error) {
+	params := DefaultParams()
+	var rest []string
+	for _, entry := range strings.Split(param, ",") {
+		if entry == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(entry, "=")
+		switch name {
+		case "delegate_timeout":
+			timeout, err := time.ParseDuration(value)
+			if err != nil || timeout < 0 {
+				return Params{}, "", fmt.Errorf("invalid delegate_timeout %q: want a duration such as 30s", value)
+			}
+			params.DelegateTimeout = timeout
+		case "delegate_max_output":
+			limit, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || limit < 0 {
+				return Params{}, "", fmt.Errorf("invalid delegate_max_output %q: want a size in bytes", value)
+			}
+			params.DelegateMaxOutput = limit
+		case "auto_max_bytes":
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 0 {
+				return Params{}, "", fmt.Errorf("invalid auto_max_bytes %q: want a size in bytes", value)
+			}
+			params.AutoMaxBytes = limit
+		default:
+			rest = append(rest, entry)
+		}
+	}
+	return params, strings.Join(rest, ","), nil
+}
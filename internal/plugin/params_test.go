@@ -0,0 +1,80 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benjamin-rood/protogo-values/internal/parser"
+)
+
+func TestParseParams(t *testing.T) {
+	tests := []struct {
+		name     string
+		param    string
+		want     Params
+		wantRest string
+		wantErr  bool
+	}{
+		{
+			name:  "empty",
+			param: "",
+			want:  DefaultParams(),
+		},
+		{
+			name:     "delegate parameters only",
+			param:    "paths=source_relative,Mfoo.proto=example.com/foo",
+			want:     DefaultParams(),
+			wantRest: "paths=source_relative,Mfoo.proto=example.com/foo",
+		},
+		{
+			name:     "limits",
+			param:    "paths=source_relative,delegate_timeout=30s,delegate_max_output=1024",
+			want:     Params{DelegateTimeout: 30 * time.Second, DelegateMaxOutput: 1024, AutoMaxBytes: parser.DefaultAutoMaxBytes},
+			wantRest: "paths=source_relative",
+		},
+		{
+			name:     "auto max bytes",
+			param:    "auto_max_bytes=64,paths=source_relative",
+			want:     Params{DelegateTimeout: DefaultDelegateTimeout, DelegateMaxOutput: DefaultDelegateMaxOutput, AutoMaxBytes: 64},
+			wantRest: "paths=source_relative",
+		},
+		{
+			name:  "limits disabled",
+			param: "delegate_timeout=0,delegate_max_output=0,auto_max_bytes=0",
+			want:  Params{},
+		},
+		{
+			name:    "bad timeout",
+			param:   "delegate_timeout=soon",
+			wantErr: true,
+		},
+		{
+			name:    "bad auto max bytes",
+			param:   "auto_max_bytes=small",
+			wantErr: true,
+		},
+		{
+			name:    "negative output limit",
+			param:   "delegate_max_output=-1",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rest, err := ParseParams(tt.param)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseParams() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseParams() params = %+v, want %+v", got, tt.want)
+			}
+			if rest != tt.wantRest {
+				t.Errorf("ParseParams() rest = %q, want %q", rest, tt.wantRest)
+			}
+		})
+	}
+}
@@ -2,56 +2,216 @@ package plugin
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"strings"
+	"time"
 
+	"github.com/benjamin-rood/protogo-values/internal/diag"
+	"github.com/benjamin-rood/protogo-values/internal/generate"
 	"github.com/benjamin-rood/protogo-values/internal/parser"
 	"github.com/benjamin-rood/protogo-values/internal/transform"
+	"google.golang.org/protobuf/compiler/protogen"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/pluginpb"
 )
 
+// delegateFunc generates plain protoc-gen-go output for a request
+type delegateFunc func(req *pluginpb.CodeGeneratorRequest, params Params) (*pluginpb.CodeGeneratorResponse, error)
+
 // ProcessRequest handles the main plugin workflow
 func ProcessRequest(req *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+	return processRequest(req, callProtocGenGo)
+}
+
+// processRequest handles the main plugin workflow, with delegate standing in
+// for protoc-gen-go
+func processRequest(req *pluginpb.CodeGeneratorRequest, delegate delegateFunc) (*pluginpb.CodeGeneratorResponse, error) {
+	// Take out this plugin's own parameters, which protoc-gen-go would reject
+	prov := provenance{param: req.GetParameter()}
+	params, rest, err := ParseParams(req.GetParameter())
+	if err != nil {
+		return nil, err
+	}
+	if req.Parameter != nil {
+		req.Parameter = proto.String(rest)
+	}
+
+	// Rename overridden JSON fields before any code is generated from them
+	if err := parser.ApplyJSONNameOverrides(req); err != nil {
+		return nil, wrap("failed to apply json name overrides", err)
+	}
+
+	// Refuse syntax the transformer cannot handle before doing any work
+	caps := ownCapabilities()
+	if err := caps.check(req); err != nil {
+		return nil, err
+	}
+
 	// Pass the request to the standard protoc-gen-go
-	resp, err := callProtocGenGo(req)
+	resp, err := delegate(req, params)
 	if err != nil {
 		return nil, fmt.Errorf("failed to call protoc-gen-go: %w", err)
 	}
+	// A response reporting an error has no files worth transforming; pass
+	// the error on to protoc exactly as protoc-gen-go gave it
+	if resp.Error != nil {
+		return resp, nil
+	}
+	// Claim only what both this plugin and protoc-gen-go support, and hold
+	// the request to that
+	caps = caps.intersect(responseCapabilities(resp))
+	if err := caps.check(req); err != nil {
+		return nil, err
+	}
+	caps.apply(resp)
+
+	// Hold protoc-gen-go to the releases whose output layout the transformer
+	// is tested against
+	warning, err := checkDelegateVersion(resp)
+	if err != nil {
+		return nil, err
+	}
+	if warning != "" {
+		fmt.Fprintln(os.Stderr, warning)
+	}
+	prov.delegate, _ = delegateVersion(resp)
+
+	// Decide the fields left to value_slice_policy AUTO, after protoc-gen-go
+	// has embedded the descriptors as written
+	notes, err := parser.ApplyValueSlicePolicy(req, params.AutoMaxBytes)
+	if err != nil {
+		return nil, wrap("failed to apply value_slice_policy", err)
+	}
+	for _, note := range notes {
+		fmt.Fprintln(os.Stderr, note)
+	}
 
 	// Parse the proto files to find annotated fields
 	annotatedFields, err := parser.FindAnnotatedFields(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse annotated fields: %w", err)
+		return nil, wrap("failed to parse annotated fields", err)
 	}
 
-	// Transform the generated files
+	// Transform the generated files, then mark the ones that changed
+	before := make(map[string]string, len(resp.File))
+	for _, file := range resp.File {
+		before[file.GetName()] = file.GetContent()
+	}
 	if err := transform.ApplyTransformations(resp, annotatedFields); err != nil {
 		return nil, fmt.Errorf("failed to apply transformations: %w", err)
 	}
+	prov.stamp(resp, before)
+
+	// Emit sibling files for options that add code rather than rewrite it
+	siblings, err := generateSiblingFiles(req)
+	if err != nil {
+		return nil, wrap("failed to generate sibling files", err)
+	}
+	resp.File = append(resp.File, siblings...)
 
 	return resp, nil
 }
 
-// callProtocGenGo calls the standard protoc-gen-go plugin
-func callProtocGenGo(req *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+// wrap adds context to err, unless err is a diagnostic, which already says
+// where in the proto sources it occurred and must keep protoc's
+// file:line:col: message format
+func wrap(context string, err error) error {
+	var d *diag.Diagnostic
+	if errors.As(err, &d) {
+		return err
+	}
+	return fmt.Errorf("%s: %w", context, err)
+}
+
+// generateSiblingFiles runs the in-process generator over the request and
+// returns the files it produced
+func generateSiblingFiles(req *pluginpb.CodeGeneratorRequest) ([]*pluginpb.CodeGeneratorResponse_File, error) {
+	gen, err := protogen.Options{}.New(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := generate.GenerateFiles(gen); err != nil {
+		return nil, err
+	}
+
+	genResp := gen.Response()
+	if genResp.Error != nil {
+		return nil, fmt.Errorf("%s", genResp.GetError())
+	}
+	return genResp.File, nil
+}
+
+// maxStderr bounds how much of protoc-gen-go's stderr is kept
+const maxStderr = 64 << 10
+
+// callProtocGenGo calls the standard protoc-gen-go plugin, within the time
+// and output limits of params. Its stderr is included in any error, and
+// forwarded to this plugin's stderr on success so its warnings still reach
+// the user.
+func callProtocGenGo(req *pluginpb.CodeGeneratorRequest, params Params) (*pluginpb.CodeGeneratorResponse, error) {
 	if req == nil {
 		return nil, fmt.Errorf("request cannot be nil")
 	}
-	
+
 	// Marshal the request
 	input, err := proto.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
+	ctx := context.Background()
+	if params.DelegateTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, params.DelegateTimeout)
+		defer cancel()
+	}
+	ctx, kill := context.WithCancel(ctx)
+	defer kill()
+
 	// Execute protoc-gen-go
-	cmd := exec.Command("protoc-gen-go")
+	cmd := exec.CommandContext(ctx, "protoc-gen-go")
 	cmd.Stdin = bytes.NewReader(input)
-	output, err := cmd.Output()
+	stderr := &limitedBuffer{max: maxStderr}
+	cmd.Stderr = stderr
+	// Don't wait on pipes held open by anything protoc-gen-go started once
+	// it has been killed
+	cmd.WaitDelay = time.Second
+	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute protoc-gen-go: %w", err)
 	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to execute protoc-gen-go: %w", err)
+	}
+
+	var src io.Reader = stdout
+	if params.DelegateMaxOutput > 0 {
+		src = io.LimitReader(stdout, params.DelegateMaxOutput+1)
+	}
+	output, readErr := io.ReadAll(src)
+	exceeded := params.DelegateMaxOutput > 0 && int64(len(output)) > params.DelegateMaxOutput
+	if exceeded {
+		kill()
+	}
+	waitErr := cmd.Wait()
+
+	switch {
+	case exceeded:
+		return nil, fmt.Errorf("protoc-gen-go wrote more than %d bytes; raise delegate_max_output to allow more", params.DelegateMaxOutput)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return nil, fmt.Errorf("protoc-gen-go did not finish within %s; raise delegate_timeout to allow longer%s", params.DelegateTimeout, stderr.suffix())
+	case waitErr != nil:
+		return nil, fmt.Errorf("failed to execute protoc-gen-go: %w%s", waitErr, stderr.suffix())
+	case readErr != nil:
+		return nil, fmt.Errorf("failed to read protoc-gen-go output: %w%s", readErr, stderr.suffix())
+	}
+	os.Stderr.Write(stderr.Bytes())
 
 	// Parse the response
 	var resp pluginpb.CodeGeneratorResponse
@@ -61,3 +221,32 @@ func callProtocGenGo(req *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGenerato
 
 	return &resp, nil
 }
+
+// limitedBuffer keeps the first max bytes written to it and discards the
+// rest
+type limitedBuffer struct {
+	bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); len(p) > room {
+		b.Buffer.Write(p[:max(room, 0)])
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// suffix formats the captured output for appending to an error message
+func (b *limitedBuffer) suffix() string {
+	text := strings.TrimSpace(b.String())
+	if text == "" {
+		return ""
+	}
+	if b.truncated {
+		text += "\n[stderr truncated]"
+	}
+	return ":\n" + text
+}
@@ -1,7 +1,12 @@
 package plugin
 
 import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/benjamin-rood/protogo-values/proto/protogo_values"
 	"google.golang.org/protobuf/proto"
@@ -142,7 +147,7 @@ func TestCallProtocGenGoErrors(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := callProtocGenGo(tt.request)
+			_, err := callProtocGenGo(tt.request, DefaultParams())
 			
 			if tt.expectError && err == nil {
 				t.Errorf("callProtocGenGo() expected error but got none")
@@ -170,7 +175,7 @@ func TestCallProtocGenGoMalformedData(t *testing.T) {
 	}
 
 	// This should handle the error gracefully
-	_, err := callProtocGenGo(req)
+	_, err := callProtocGenGo(req, DefaultParams())
 	if err == nil {
 		t.Skip("Skipping - protoc-gen-go not available or handles invalid input gracefully")
 	}
@@ -253,4 +258,102 @@ func TestProcessRequestEdgeCases(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+// fakeProtocGenGo puts a shell script named protoc-gen-go first on PATH
+func fakeProtocGenGo(t *testing.T, script string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake protoc-gen-go is a shell script")
+	}
+	dir := t.TempDir()
+	body := "#!/bin/sh\ncat >/dev/null\n" + script + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "protoc-gen-go"), []byte(body), 0o755); err != nil {
+		t.Fatalf("failed to write fake protoc-gen-go: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestCallProtocGenGoLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		params  Params
+		wantErr string
+	}{
+		{
+			name:    "stderr is reported",
+			script:  "echo 'unsupported feature in foo.proto' >&2; exit 1",
+			params:  DefaultParams(),
+			wantErr: "exit status 1:\nunsupported feature in foo.proto",
+		},
+		{
+			name:    "timeout",
+			script:  "sleep 5",
+			params:  Params{DelegateTimeout: 100 * time.Millisecond},
+			wantErr: "did not finish within 100ms",
+		},
+		{
+			name:    "output limit",
+			script:  "head -c 4096 /dev/zero",
+			params:  Params{DelegateMaxOutput: 1024},
+			wantErr: "wrote more than 1024 bytes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeProtocGenGo(t, tt.script)
+			_, err := callProtocGenGo(&pluginpb.CodeGeneratorRequest{}, tt.params)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("callProtocGenGo() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// fakeProtocGenGoResponse makes the fake protoc-gen-go write resp
+func fakeProtocGenGoResponse(t *testing.T, resp *pluginpb.CodeGeneratorResponse) {
+	t.Helper()
+	data, err := proto.Marshal(resp)
+	if err != nil {
+		t.Fatalf("failed to marshal response: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "response.bin")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	fakeProtocGenGo(t, "cat '"+path+"'")
+}
+
+func TestProcessRequestDelegateError(t *testing.T) {
+	fakeProtocGenGoResponse(t, &pluginpb.CodeGeneratorResponse{
+		Error: proto.String("foo.proto: unknown option"),
+		File:  []*pluginpb.CodeGeneratorResponse_File{{Name: proto.String("foo.pb.go")}},
+	})
+
+	resp, err := ProcessRequest(&pluginpb.CodeGeneratorRequest{Parameter: proto.String("delegate_timeout=10s")})
+	if err != nil {
+		t.Fatalf("ProcessRequest() unexpected error: %v", err)
+	}
+	if got := resp.GetError(); got != "foo.proto: unknown option" {
+		t.Errorf("ProcessRequest() error = %q, want the delegate's error verbatim", got)
+	}
+}
+
+func TestProcessRequestSupportedFeatures(t *testing.T) {
+	fakeProtocGenGoResponse(t, &pluginpb.CodeGeneratorResponse{
+		SupportedFeatures: proto.Uint64(uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL |
+			pluginpb.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS)),
+		MinimumEdition: proto.Int32(int32(descriptorpb.Edition_EDITION_PROTO2)),
+		MaximumEdition: proto.Int32(int32(descriptorpb.Edition_EDITION_2024)),
+	})
+
+	resp, err := ProcessRequest(&pluginpb.CodeGeneratorRequest{})
+	if err != nil {
+		t.Fatalf("ProcessRequest() unexpected error: %v", err)
+	}
+	if got, want := resp.GetMaximumEdition(), int32(descriptorpb.Edition_EDITION_2023); got != want {
+		t.Errorf("ProcessRequest() maximum edition = %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,104 @@
+package plugin
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/benjamin-rood/protogo-values/internal/transform"
+	"github.com/benjamin-rood/protogo-values/internal/version"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// provenance describes a run of the plugin, for the header it adds to the
+// files it transforms
+type provenance struct {
+	// delegate is the protoc-gen-go version whose output was transformed
+	delegate string
+	// param is the plugin parameter as given, including this plugin's own
+	// parameters
+	param string
+}
+
+// stamp adds a header to each file in resp whose content differs from
+// before, recording the plugin version, the transformations applied, the
+// parameters and the rewritten fields, so that transformed files can be told
+// apart from plain protoc-gen-go output
+func (p provenance) stamp(resp *pluginpb.CodeGeneratorResponse, before map[string]string) {
+	for _, file := range resp.File {
+		original, ok := before[file.GetName()]
+		if !ok || original == file.GetContent() {
+			continue
+		}
+		rewrites := transform.Rewrites(original, file.GetContent())
+		file.Content = proto.String(insertHeader(file.GetContent(), p.header(rewrites)))
+	}
+}
+
+// header formats the provenance header for a file with the given rewrites
+func (p provenance) header(rewrites []transform.Rewrite) string {
+	var valueSlices, tags bool
+	for _, r := range rewrites {
+		if r.OldType != r.NewType {
+			valueSlices = true
+		} else {
+			tags = true
+		}
+	}
+	var modes []string
+	if valueSlices {
+		modes = append(modes, "value_slice")
+	}
+	if tags {
+		modes = append(modes, "struct tags")
+	}
+
+	delegate := p.delegate
+	if delegate == "" {
+		delegate = "(unknown)"
+	}
+	param := p.param
+	if param == "" {
+		param = "(none)"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Transformed by protoc-gen-go-values %s. DO NOT EDIT.\n", version.String())
+	fmt.Fprintf(&b, "// mode: %s, applied to protoc-gen-go %s output\n", strings.Join(modes, ", "), delegate)
+	fmt.Fprintf(&b, "// parameters: %s\n", param)
+	b.WriteString("// rewritten fields:\n")
+	for _, r := range rewrites {
+		if r.OldType != r.NewType {
+			fmt.Fprintf(&b, "// \t%s.%s: %s -> %s\n", r.Struct, r.Field, r.OldType, r.NewType)
+		} else {
+			fmt.Fprintf(&b, "// \t%s.%s: struct tags\n", r.Struct, r.Field)
+		}
+	}
+	return b.String()
+}
+
+// insertHeader adds header to the comment block protoc-gen-go opens a file
+// with, after its "// source:" line, or before the package clause if there
+// is none. Blank lines keep it out of the package documentation.
+func insertHeader(content, header string) string {
+	lines := strings.SplitAfter(content, "\n")
+	at := -1
+	for i, line := range lines {
+		if strings.HasPrefix(line, "// source: ") {
+			at = i + 1
+			break
+		}
+		if strings.HasPrefix(line, "package ") {
+			at = i
+			break
+		}
+	}
+	if at < 0 {
+		return header + "\n" + content
+	}
+	rest := strings.Join(lines[at:], "")
+	if strings.HasPrefix(lines[max(at-1, 0)], "// source: ") {
+		return strings.Join(lines[:at], "") + "\n" + header + rest
+	}
+	return strings.Join(lines[:at], "") + header + "\n" + rest
+}
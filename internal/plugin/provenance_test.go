@@ -0,0 +1,92 @@
+package plugin
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/benjamin-rood/protogo-values/internal/version"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func TestProvenanceStamp(t *testing.T) {
+	version.Version = "v1.2.3"
+	t.Cleanup(func() { version.Version = "" })
+
+	const generated = "// Code generated by protoc-gen-go. DO NOT EDIT.\n" +
+		"// versions:\n" +
+		"// \tprotoc-gen-go v1.36.8\n" +
+		"// source: shop.proto\n" +
+		"\n" +
+		"package shop\n" +
+		"\n" +
+		"type Order struct {\n" +
+		"\tItems []*Item `protobuf:\"bytes,1,rep,name=items\"`\n" +
+		"\tId string `protobuf:\"bytes,2,opt,name=id\"`\n" +
+		"}\n"
+	transformed := strings.Replace(generated, "[]*Item", "[]Item", 1)
+	transformed = strings.Replace(transformed, `name=id"`, `name=id" db:"id"`, 1)
+
+	resp := &pluginpb.CodeGeneratorResponse{File: []*pluginpb.CodeGeneratorResponse_File{
+		{Name: proto.String("shop.pb.go"), Content: proto.String(transformed)},
+		{Name: proto.String("other.pb.go"), Content: proto.String(generated)},
+		{Name: proto.String("shop_values.pb.go"), Content: proto.String("package shop\n")},
+	}}
+	before := map[string]string{"shop.pb.go": generated, "other.pb.go": generated}
+	provenance{delegate: "v1.36.8", param: "paths=source_relative,delegate_timeout=30s"}.stamp(resp, before)
+
+	want := "// Code generated by protoc-gen-go. DO NOT EDIT.\n" +
+		"// versions:\n" +
+		"// \tprotoc-gen-go v1.36.8\n" +
+		"// source: shop.proto\n" +
+		"\n" +
+		"// Transformed by protoc-gen-go-values v1.2.3. DO NOT EDIT.\n" +
+		"// mode: value_slice, struct tags, applied to protoc-gen-go v1.36.8 output\n" +
+		"// parameters: paths=source_relative,delegate_timeout=30s\n" +
+		"// rewritten fields:\n" +
+		"// \tOrder.Items: []*Item -> []Item\n" +
+		"// \tOrder.Id: struct tags\n" +
+		"\n" +
+		"package shop\n"
+	if got := resp.File[0].GetContent(); !strings.HasPrefix(got, want) {
+		t.Errorf("stamped file starts:\n%s\nwant:\n%s", got, want)
+	}
+	if got := resp.File[1].GetContent(); got != generated {
+		t.Errorf("unchanged file was stamped:\n%s", got)
+	}
+	if got := resp.File[2].GetContent(); got != "package shop\n" {
+		t.Errorf("sibling file was stamped:\n%s", got)
+	}
+}
+
+func TestInsertHeader(t *testing.T) {
+	const header = "// header\n"
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "after source",
+			content: "// source: a.proto\n\npackage a\n",
+			want:    "// source: a.proto\n\n// header\n\npackage a\n",
+		},
+		{
+			name:    "without source",
+			content: "// Code generated. DO NOT EDIT.\n\npackage a\n",
+			want:    "// Code generated. DO NOT EDIT.\n\n// header\n\npackage a\n",
+		},
+		{
+			name:    "without package",
+			content: "// nothing\n",
+			want:    "// header\n\n// nothing\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := insertHeader(tt.content, header); got != tt.want {
+				t.Errorf("insertHeader() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
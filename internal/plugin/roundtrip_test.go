@@ -0,0 +1,476 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/benjamin-rood/protogo-values/internal/version"
+	"golang.org/x/tools/txtar"
+)
+
+// roundTripModule is the module path the round-trip harness generates code
+// into. Each fixture gets its own tree under it, so that fixtures declaring
+// the same proto messages are never linked into one binary.
+const roundTripModule = "roundtrip"
+
+// valueSliceBreaks lists, by fixture, the messages the protobuf runtime
+// cannot handle, with the panic each is expected to raise. Each declares a
+// value slice of messages, which the runtime rejects because it requires
+// repeated message fields to be pointer slices; see
+// specs/plugin-transformation-bug.spec.md. The runner does not descend into
+// these messages from other fields, so a break is only excused for the
+// message declaring it and only with its own panic. Anything else fails the
+// test, and so does an entry that starts to round-trip or no longer exists.
+var valueSliceBreaks = map[string]map[string]string{
+	"field_options_test": {
+		"test.TestMessage": "reflect: Elem of invalid type gen.User", // users_with_option
+	},
+	"group_test": {
+		"group_test.SearchResponse":        "reflect: Elem of invalid type group_test.SearchResponse_RelatedQuery",   // related_query, as result holds a broken type
+		"group_test.SearchResponse.Result": "reflect: Elem of invalid type group_test.SearchResponse_Result_Snippet", // snippet
+	},
+	"json_test": {
+		"json_test.EventLog": "reflect: Elem of invalid type json_test.Event", // events
+	},
+	"nested_value_slice": {
+		"golden.Outer.Inner": "reflect: Elem of invalid type golden.Point", // points
+	},
+	"oneof_test": {
+		"oneof_test.Payment.Invoice": "reflect: Elem of invalid type oneof_test.LineItem", // lines
+	},
+	"validation_test": {
+		"validation_test.Order": "reflect: Elem of invalid type validation_test.Item", // items
+	},
+	"protogen_field_names": {
+		"golden.Record": "reflect: Elem of invalid type golden.Item", // item_2list
+	},
+	"protogen_names": {
+		"golden.Foo_bar_Baz": "reflect: Elem of invalid type golden.FooBar_BazFoo_1Bar", // bars
+		"golden.Holder":      "reflect: Elem of invalid type golden.FooBar_BazFoo_1Bar", // nested_bars
+	},
+	"value_slice_policy": {
+		"golden.Route": "reflect: Elem of invalid type golden.Point", // stops, made a value slice by AUTO
+	},
+	"xpkg_test": {
+		"xpkg_test.Envelope": "reflect: Elem of invalid type anypb.Any", // payloads
+	},
+}
+
+// roundTripRunner is the main package built for each fixture. It fills in
+// every field of every message the fixture declares, except those of the
+// broken messages, marshals it, unmarshals the result and compares the two,
+// printing one line per message:
+//
+//	<full name> <tab> <result>
+const roundTripRunner = `package main
+
+import (
+	"fmt"
+	"slices"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+%s)
+
+// depth bounds how far nested messages are filled in
+const depth = 2
+
+var files = map[string]bool{%s}
+
+// broken holds the messages listed in valueSliceBreaks
+var broken = map[protoreflect.FullName]bool{%s}
+
+func main() {
+	var types []protoreflect.MessageType
+	protoregistry.GlobalTypes.RangeMessages(func(mt protoreflect.MessageType) bool {
+		if files[mt.Descriptor().ParentFile().Path()] {
+			types = append(types, mt)
+		}
+		return true
+	})
+	slices.SortFunc(types, func(a, b protoreflect.MessageType) int {
+		if a.Descriptor().FullName() < b.Descriptor().FullName() {
+			return -1
+		}
+		return 1
+	})
+	for _, mt := range types {
+		fmt.Printf("%%s\t%%s\n", mt.Descriptor().FullName(), roundTrip(mt))
+	}
+}
+
+func roundTrip(mt protoreflect.MessageType) (result string) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = fmt.Sprintf("panic: %%v", r)
+		}
+	}()
+	m := mt.New()
+	populate(m, depth)
+	data, err := proto.Marshal(m.Interface())
+	if err != nil {
+		return "marshal: " + err.Error()
+	}
+	got := mt.New().Interface()
+	if err := proto.Unmarshal(data, got); err != nil {
+		return "unmarshal: " + err.Error()
+	}
+	if !proto.Equal(m.Interface(), got) {
+		return "round trip changed the message"
+	}
+	return "ok"
+}
+
+// filled reports whether populate sets fd: only the first member of each
+// oneof is set, extensions are left alone, and so are fields of broken
+// message types
+func filled(fd protoreflect.FieldDescriptor) bool {
+	target := fd.Message()
+	if fd.IsMap() {
+		target = fd.MapValue().Message()
+	}
+	if target != nil && broken[target.FullName()] {
+		return false
+	}
+	oneof := fd.ContainingOneof()
+	return oneof == nil || oneof.IsSynthetic() || oneof.Fields().Get(0) == fd
+}
+
+func populate(m protoreflect.Message, depth int) {
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if !filled(fd) {
+			continue
+		}
+		switch {
+		case fd.IsMap():
+			value := fd.MapValue()
+			if value.Message() != nil && depth == 0 {
+				continue
+			}
+			mp := m.Mutable(fd).Map()
+			key := scalar(fd.MapKey()).MapKey()
+			if value.Message() != nil {
+				populate(mp.Mutable(key).Message(), depth-1)
+			} else {
+				mp.Set(key, scalar(value))
+			}
+		case fd.IsList():
+			if fd.Message() != nil && depth == 0 {
+				continue
+			}
+			list := m.Mutable(fd).List()
+			if fd.Message() != nil {
+				elem := list.NewElement()
+				populate(elem.Message(), depth-1)
+				list.Append(elem)
+			} else {
+				list.Append(scalar(fd))
+			}
+		case fd.Message() != nil:
+			if depth > 0 {
+				populate(m.Mutable(fd).Message(), depth-1)
+			}
+		default:
+			m.Set(fd, scalar(fd))
+		}
+	}
+}
+
+// scalar returns a non-zero value for a field of a scalar kind
+func scalar(fd protoreflect.FieldDescriptor) protoreflect.Value {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(true)
+	case protoreflect.EnumKind:
+		values := fd.Enum().Values()
+		return protoreflect.ValueOfEnum(values.Get(values.Len() - 1).Number())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(7)
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return protoreflect.ValueOfInt64(7)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(7)
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return protoreflect.ValueOfUint64(7)
+	case protoreflect.FloatKind:
+		return protoreflect.ValueOfFloat32(1.5)
+	case protoreflect.DoubleKind:
+		return protoreflect.ValueOfFloat64(2.5)
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString("x")
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes([]byte("x"))
+	}
+	panic(fmt.Sprintf("no scalar value for %%s", fd.FullName()))
+}
+`
+
+// TestRoundTrip compiles the code generated for every golden fixture in a
+// temporary module and checks that the protobuf runtime can marshal and
+// unmarshal each of its messages without loss
+func TestRoundTrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the generated code with the go command")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	root, err := filepath.Abs(repoRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version.Version = goldenVersion
+	t.Cleanup(func() { version.Version = "" })
+
+	paths, err := filepath.Glob(filepath.Join(goldenTestdata, "*.txtar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	module := t.TempDir()
+	var fixtures, packages []string
+	tests := make(map[string][]string)
+	for _, path := range paths {
+		archive, err := txtar.ParseFile(path)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", path, err)
+		}
+		if _, ok := goldenSection(archive, "diagnostics"); ok {
+			continue
+		}
+		fixture := strings.TrimSuffix(filepath.Base(path), ".txtar")
+		generated, tested := writeRoundTripFixture(t, module, fixture, archive)
+		packages = append(packages, generated...)
+		tests[fixture] = tested
+		fixtures = append(fixtures, fixture)
+	}
+
+	goMod := fmt.Sprintf("module %s\n\ngo 1.24\n\nrequire github.com/benjamin-rood/protogo-values v0.0.0\n\nreplace github.com/benjamin-rood/protogo-values => %s\n", roundTripModule, root)
+	if err := os.WriteFile(filepath.Join(module, "go.mod"), []byte(goMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	goSum, err := os.ReadFile(filepath.Join(root, "go.sum"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(module, "go.sum"), goSum, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	bin := t.TempDir()
+	build := exec.Command(goTool, "build", "-o", bin+string(filepath.Separator), "./...")
+	build.Dir = module
+	build.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("generated code does not build: %v\n%s", err, out)
+	}
+
+	// Users vet the generated code along with their own, so it must pass too.
+	// The packages are named explicitly: go vet ./... skips testdata trees.
+	vet := exec.Command(goTool, append([]string{"vet"}, packages...)...)
+	vet.Dir = module
+	vet.Env = build.Env
+	if out, err := vet.CombinedOutput(); err != nil {
+		t.Fatalf("generated code does not pass go vet: %v\n%s", err, out)
+	}
+
+	// Nor may it copy messages, as the protovalues analyzer defines it. Its
+	// reports of value slices reaching the runtime are left out:
+	// valueSliceBreaks covers those.
+	analyzer := exec.Command(goTool, "build", "-o", bin+string(filepath.Separator), "./cmd/protogo-values-vet")
+	analyzer.Dir = root
+	if out, err := analyzer.CombinedOutput(); err != nil {
+		t.Fatalf("failed to build protogo-values-vet: %v\n%s", err, out)
+	}
+	check := exec.Command(goTool, append([]string{"vet", "-vettool=" + filepath.Join(bin, "protogo-values-vet"), "-generated", "-json"}, packages...)...)
+	check.Dir = module
+	check.Env = build.Env
+	out, err := check.CombinedOutput()
+	if err != nil {
+		t.Fatalf("protogo-values-vet failed: %v\n%s", err, out)
+	}
+	for _, d := range analyzerDiagnostics(t, out) {
+		if !strings.Contains(d.Message, "protobuf runtime panics on") {
+			t.Errorf("generated code copies a message: %s: %s", d.Posn, d.Message)
+		}
+	}
+
+	for _, fixture := range fixtures {
+		t.Run(fixture, func(t *testing.T) {
+			out, err := exec.Command(filepath.Join(bin, fixture)).Output()
+			if err != nil {
+				t.Fatalf("round-trip runner failed: %v", err)
+			}
+			lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+			if len(lines) == 0 || lines[0] == "" {
+				t.Fatal("fixture declares no messages")
+			}
+			breaks := valueSliceBreaks[fixture]
+			seen := make(map[string]bool)
+			for _, line := range lines {
+				name, result, _ := strings.Cut(line, "\t")
+				seen[name] = true
+				reason, listed := breaks[name]
+				switch {
+				case listed && result == "ok":
+					t.Errorf("%s now round-trips; remove it from valueSliceBreaks and update the README's limitations", name)
+				case listed && result != "panic: "+reason:
+					t.Errorf("%s: %s (expected panic: %s)", name, result, reason)
+				case !listed && result != "ok":
+					t.Errorf("%s: %s", name, result)
+				}
+			}
+			for _, name := range slices.Sorted(maps.Keys(breaks)) {
+				if !seen[name] {
+					t.Errorf("valueSliceBreaks lists %s, which the fixture does not declare", name)
+				}
+			}
+
+			if len(tests[fixture]) == 0 {
+				return
+			}
+			test := exec.Command(goTool, append([]string{"test"}, tests[fixture]...)...)
+			test.Dir = module
+			test.Env = build.Env
+			if out, err := test.CombinedOutput(); err != nil {
+				t.Errorf("runtime tests failed: %v\n%s", err, out)
+			}
+		})
+	}
+	for _, fixture := range slices.Sorted(maps.Keys(valueSliceBreaks)) {
+		if !slices.Contains(fixtures, fixture) {
+			t.Errorf("valueSliceBreaks lists fixture %s, which has no output to round-trip", fixture)
+		}
+	}
+}
+
+// analyzerDiagnostic is a diagnostic in the output of go vet -json
+type analyzerDiagnostic struct {
+	Posn    string `json:"posn"`
+	Message string `json:"message"`
+}
+
+// analyzerDiagnostics decodes the output of go vet -json, a JSON object per
+// package, keyed by package path then analyzer, each after a "#" line naming
+// the package
+func analyzerDiagnostics(t *testing.T, out []byte) []analyzerDiagnostic {
+	var objects []byte
+	for _, line := range bytes.Split(out, []byte("\n")) {
+		if !bytes.HasPrefix(line, []byte("#")) {
+			objects = append(append(objects, line...), '\n')
+		}
+	}
+	var diagnostics []analyzerDiagnostic
+	dec := json.NewDecoder(bytes.NewReader(objects))
+	for dec.More() {
+		var pkgs map[string]map[string][]analyzerDiagnostic
+		if err := dec.Decode(&pkgs); err != nil {
+			t.Fatalf("failed to decode go vet -json output: %v\n%s", err, out)
+		}
+		for _, analyzers := range pkgs {
+			for _, found := range analyzers {
+				diagnostics = append(diagnostics, found...)
+			}
+		}
+	}
+	return diagnostics
+}
+
+// writeRoundTripFixture generates the code for a golden fixture into its
+// own tree of module, along with its round-trip runner and runtime tests.
+// It returns the generated packages and those the tests were added to.
+func writeRoundTripFixture(t *testing.T, module, fixture string, archive *txtar.Archive) (generated, tested []string) {
+	t.Helper()
+	generateList, _ := goldenSection(archive, "generate")
+	generate := strings.Fields(generateList)
+	protos := make(map[string]string)
+	for _, f := range archive.Files {
+		if strings.HasSuffix(f.Name, ".proto") {
+			protos[f.Name] = string(f.Data)
+		}
+	}
+
+	// Map the generated files into the fixture's tree, keeping the layout of
+	// their go_package import paths
+	req := compileRequest(t, protos, generate, "")
+	param := []string{"paths=import", "module=" + roundTripModule}
+	var fileSet []string
+	for _, file := range req.ProtoFile {
+		if !slices.Contains(generate, file.GetName()) {
+			continue
+		}
+		importPath, _, _ := strings.Cut(file.GetOptions().GetGoPackage(), ";")
+		importPath = strings.TrimPrefix(importPath, "github.com/benjamin-rood/protogo-values/")
+		param = append(param, "M"+file.GetName()+"="+path.Join(roundTripModule, fixture, importPath))
+		fileSet = append(fileSet, fmt.Sprintf("%q: true", file.GetName()))
+	}
+	req = compileRequest(t, protos, generate, strings.Join(param, ","))
+
+	resp, err := processRequest(req, inProcessProtocGenGo)
+	if err != nil {
+		t.Fatalf("%s: %v", fixture, err)
+	}
+	if resp.Error != nil {
+		t.Fatalf("%s: %s", fixture, resp.GetError())
+	}
+	packages := make(map[string]bool)
+	for _, file := range resp.File {
+		dest := filepath.Join(module, filepath.FromSlash(file.GetName()))
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(dest, []byte(file.GetContent()), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		packages[path.Join(roundTripModule, path.Dir(file.GetName()))] = true
+	}
+
+	var imports bytes.Buffer
+	for _, pkg := range slices.Sorted(maps.Keys(packages)) {
+		fmt.Fprintf(&imports, "\t_ %q\n", pkg)
+	}
+	var brokenSet []string
+	for _, name := range slices.Sorted(maps.Keys(valueSliceBreaks[fixture])) {
+		brokenSet = append(brokenSet, fmt.Sprintf("%q: true", name))
+	}
+	runner := fmt.Sprintf(roundTripRunner, imports.String(), strings.Join(fileSet, ", "), strings.Join(brokenSet, ", "))
+	dir := filepath.Join(module, "cmd", fixture)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(runner), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	testPackages := make(map[string]bool)
+	for _, f := range archive.Files {
+		name, ok := strings.CutPrefix(f.Name, "roundtrip/")
+		if !ok {
+			continue
+		}
+		dest := filepath.Join(module, fixture, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(dest, f.Data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		testPackages[path.Join(roundTripModule, fixture, path.Dir(name))] = true
+	}
+	return slices.Sorted(maps.Keys(packages)), slices.Sorted(maps.Keys(testPackages))
+}
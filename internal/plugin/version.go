@@ -0,0 +1,68 @@
+package plugin
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/mod/semver"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// testedDelegates is the compatibility matrix: the protoc-gen-go releases,
+// one for each minor version, whose output layout the transformer is tested
+// against. Each has golden fixtures in testdata/delegate. Other patch
+// releases of these minor versions are assumed to share their layout.
+var testedDelegates = []string{
+	"v1.30.0",
+	"v1.31.0",
+	"v1.32.0",
+	"v1.33.0",
+	"v1.34.2",
+	"v1.35.2",
+	"v1.36.11",
+}
+
+// delegateVersion returns the protoc-gen-go version recorded in the
+// "// versions:" header of the first generated Go file in resp
+func delegateVersion(resp *pluginpb.CodeGeneratorResponse) (string, bool) {
+	for _, file := range resp.File {
+		if !strings.HasSuffix(file.GetName(), ".go") {
+			continue
+		}
+		for _, line := range strings.Split(file.GetContent(), "\n") {
+			if strings.HasPrefix(line, "package ") {
+				break
+			}
+			if rest, ok := strings.CutPrefix(line, "// \tprotoc-gen-go "); ok {
+				return strings.TrimSpace(rest), true
+			}
+		}
+	}
+	return "", false
+}
+
+// checkDelegateVersion holds the protoc-gen-go that generated resp to the
+// compatibility matrix. Releases older than the matrix are refused, since
+// their output is known to differ; newer or unrecognised ones only draw a
+// warning, since their output usually still matches.
+func checkDelegateVersion(resp *pluginpb.CodeGeneratorResponse) (warning string, err error) {
+	version, ok := delegateVersion(resp)
+	if !ok {
+		// Nothing was generated, so nothing will be transformed
+		return "", nil
+	}
+	oldest := testedDelegates[0]
+	newest := testedDelegates[len(testedDelegates)-1]
+	tested := fmt.Sprintf("%s to %s", semver.MajorMinor(oldest), semver.MajorMinor(newest))
+
+	if !semver.IsValid(version) {
+		return fmt.Sprintf("warning: cannot tell which release protoc-gen-go %s is; protoc-gen-go-values is tested with %s", version, tested), nil
+	}
+	switch minor := semver.MajorMinor(version); {
+	case semver.Compare(minor, semver.MajorMinor(oldest)) < 0:
+		return "", fmt.Errorf("protoc-gen-go %s is not supported: protoc-gen-go-values is tested with %s; upgrade protoc-gen-go", version, tested)
+	case semver.Compare(minor, semver.MajorMinor(newest)) > 0:
+		return fmt.Sprintf("warning: protoc-gen-go %s is newer than the releases protoc-gen-go-values is tested with (%s); check the generated code", version, tested), nil
+	}
+	return "", nil
+}
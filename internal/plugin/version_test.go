@@ -0,0 +1,207 @@
+package plugin
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/benjamin-rood/protogo-values/internal/parser"
+	"github.com/benjamin-rood/protogo-values/internal/transform"
+	"github.com/benjamin-rood/protogo-values/proto/protogo_values"
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/txtar"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+var updateDelegates = flag.Bool("update-delegates", false, "re-record the protoc-gen-go golden fixtures in testdata/delegate, running each release with go run")
+
+const delegateTestdata = "../../testdata/delegate"
+
+// goldenRequest returns the request the delegate golden fixtures are
+// generated from
+func goldenRequest(t *testing.T) *pluginpb.CodeGeneratorRequest {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(delegateTestdata, "field_options_test.txtpb"))
+	if err != nil {
+		t.Fatalf("failed to read request: %v", err)
+	}
+	file := &descriptorpb.FileDescriptorProto{}
+	if err := prototext.Unmarshal(data, file); err != nil {
+		t.Fatalf("failed to parse request: %v", err)
+	}
+	return &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{file.GetName()},
+		Parameter:      proto.String("paths=source_relative"),
+		ProtoFile: []*descriptorpb.FileDescriptorProto{
+			protodesc.ToFileDescriptorProto(descriptorpb.File_google_protobuf_descriptor_proto),
+			protodesc.ToFileDescriptorProto(protogo_values.File_proto_protogo_values_options_proto),
+			file,
+		},
+	}
+}
+
+// recordDelegate runs a protoc-gen-go release over req and returns the Go
+// file it generates
+func recordDelegate(t *testing.T, version string, req *pluginpb.CodeGeneratorRequest) *pluginpb.CodeGeneratorResponse_File {
+	t.Helper()
+	input, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatalf("failed to marshal request: %v", err)
+	}
+	cmd := exec.Command("go", "run", "google.golang.org/protobuf/cmd/protoc-gen-go@"+version)
+	cmd.Env = append(os.Environ(), "GOFLAGS=")
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("failed to run protoc-gen-go %s: %v", version, err)
+	}
+	var resp pluginpb.CodeGeneratorResponse
+	if err := proto.Unmarshal(output, &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if resp.Error != nil || len(resp.File) != 1 {
+		t.Fatalf("protoc-gen-go %s returned error %q and %d files", version, resp.GetError(), len(resp.File))
+	}
+	return resp.File[0]
+}
+
+// TestDelegateGoldens transforms the recorded output of every release in the
+// compatibility matrix and compares it with the expected result
+func TestDelegateGoldens(t *testing.T) {
+	for _, version := range testedDelegates {
+		t.Run(version, func(t *testing.T) {
+			req := goldenRequest(t)
+			path := filepath.Join(delegateTestdata, version+".txtar")
+
+			if *updateDelegates {
+				file := recordDelegate(t, version, req)
+				resp := &pluginpb.CodeGeneratorResponse{File: []*pluginpb.CodeGeneratorResponse_File{proto.Clone(file).(*pluginpb.CodeGeneratorResponse_File)}}
+				fields, err := parser.FindAnnotatedFields(req)
+				if err != nil {
+					t.Fatalf("FindAnnotatedFields() failed: %v", err)
+				}
+				if err := transform.ApplyTransformations(resp, fields); err != nil {
+					t.Fatalf("ApplyTransformations() failed: %v", err)
+				}
+				archive := &txtar.Archive{
+					Comment: []byte("Output of protoc-gen-go " + version + " for field_options_test.txtpb, before\nand after transformation. Regenerate with go test -run TestDelegateGoldens -update-delegates.\n"),
+					Files: []txtar.File{
+						{Name: "delegate/" + file.GetName(), Data: []byte(file.GetContent())},
+						{Name: "want/" + file.GetName(), Data: []byte(resp.File[0].GetContent())},
+					},
+				}
+				if err := os.WriteFile(path, txtar.Format(archive), 0o644); err != nil {
+					t.Fatalf("failed to write %s: %v", path, err)
+				}
+			}
+
+			archive, err := txtar.ParseFile(path)
+			if err != nil {
+				t.Fatalf("failed to read fixture: %v", err)
+			}
+			files := make(map[string]string)
+			for _, f := range archive.Files {
+				files[f.Name] = string(f.Data)
+			}
+			name := strings.TrimSuffix(req.FileToGenerate[0], ".proto") + ".pb.go"
+			delegated, ok := files["delegate/"+name]
+			want, ok2 := files["want/"+name]
+			if !ok || !ok2 {
+				t.Fatalf("fixture %s lacks delegate/%s or want/%s", path, name, name)
+			}
+
+			resp := &pluginpb.CodeGeneratorResponse{File: []*pluginpb.CodeGeneratorResponse_File{
+				{Name: proto.String(name), Content: proto.String(delegated)},
+			}}
+			if got, _ := delegateVersion(resp); got != version {
+				t.Errorf("delegateVersion() = %q, want %q", got, version)
+			}
+			if warning, err := checkDelegateVersion(resp); warning != "" || err != nil {
+				t.Errorf("checkDelegateVersion() = %q, %v for a tested release", warning, err)
+			}
+
+			fields, err := parser.FindAnnotatedFields(req)
+			if err != nil {
+				t.Fatalf("FindAnnotatedFields() failed: %v", err)
+			}
+			if err := transform.ApplyTransformations(resp, fields); err != nil {
+				t.Fatalf("ApplyTransformations() failed: %v", err)
+			}
+			if got := resp.File[0].GetContent(); got != want {
+				t.Errorf("transformed output of protoc-gen-go %s differs from want/%s:\n%s", version, name, got)
+			}
+		})
+	}
+}
+
+// TestTestedDelegates checks that the compatibility matrix is ordered and
+// covers every minor version in its range, so that every release it accepts
+// is represented by a golden fixture
+func TestTestedDelegates(t *testing.T) {
+	var prevMinor int
+	for i, version := range testedDelegates {
+		var major, minor int
+		if _, err := fmt.Sscanf(semver.MajorMinor(version), "v%d.%d", &major, &minor); err != nil || !semver.IsValid(version) {
+			t.Fatalf("tested release %q is not a semantic version", version)
+		}
+		if major != 1 {
+			t.Errorf("tested release %s is not protoc-gen-go v1", version)
+		}
+		if i > 0 && minor != prevMinor+1 {
+			t.Errorf("tested releases skip from v1.%d to %s", prevMinor, semver.MajorMinor(version))
+		}
+		prevMinor = minor
+	}
+}
+
+func TestCheckDelegateVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     string
+		wantWarning string
+		wantErr     string
+	}{
+		{name: "tested release", version: "v1.36.11"},
+		{name: "other patch release", version: "v1.36.8"},
+		{name: "older release", version: "v1.28.1", wantErr: "protoc-gen-go v1.28.1 is not supported: protoc-gen-go-values is tested with v1.30 to v1.36"},
+		{name: "newer release", version: "v1.37.0", wantWarning: "newer than the releases"},
+		{name: "development build", version: "(devel)", wantWarning: "cannot tell which release"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &pluginpb.CodeGeneratorResponse{File: []*pluginpb.CodeGeneratorResponse_File{{
+				Name:    proto.String("shop.pb.go"),
+				Content: proto.String("// Code generated by protoc-gen-go. DO NOT EDIT.\n// versions:\n// \tprotoc-gen-go " + tt.version + "\n// \tprotoc        v5.29.3\n\npackage shop\n"),
+			}}}
+			warning, err := checkDelegateVersion(resp)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("checkDelegateVersion() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("checkDelegateVersion() unexpected error: %v", err)
+			}
+			if tt.wantWarning == "" && warning != "" || !strings.Contains(warning, tt.wantWarning) {
+				t.Errorf("checkDelegateVersion() warning = %q, want it to contain %q", warning, tt.wantWarning)
+			}
+		})
+	}
+
+	// Files without a version header, and empty responses, are let through
+	if warning, err := checkDelegateVersion(&pluginpb.CodeGeneratorResponse{}); warning != "" || err != nil {
+		t.Errorf("checkDelegateVersion() of an empty response = %q, %v", warning, err)
+	}
+}
@@ -0,0 +1,79 @@
+package structtag
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Tag is a single key:"value" pair of a Go struct tag
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// Parse splits a struct tag in the conventional key:"value" format into its
+// pairs, preserving order
+func Parse(tag string) ([]Tag, error) {
+	var tags []Tag
+	tag = strings.TrimSpace(tag)
+	for tag != "" {
+		i := strings.Index(tag, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("malformed struct tag %q: missing key", tag)
+		}
+		key := tag[:i]
+		if strings.ContainsAny(key, " \t\"`") {
+			return nil, fmt.Errorf("malformed struct tag key %q", key)
+		}
+		quoted, err := strconv.QuotedPrefix(tag[i+1:])
+		if err != nil || !strings.HasPrefix(quoted, `"`) {
+			return nil, fmt.Errorf("malformed struct tag value for key %q", key)
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			return nil, fmt.Errorf("malformed struct tag value for key %q: %w", key, err)
+		}
+		tags = append(tags, Tag{Key: key, Value: value})
+		tag = strings.TrimLeft(tag[i+1+len(quoted):], " ")
+	}
+	return tags, nil
+}
+
+// Format joins tag pairs back into a struct tag
+func Format(tags []Tag) string {
+	parts := make([]string, len(tags))
+	for i, t := range tags {
+		parts[i] = t.Key + ":" + strconv.Quote(t.Value)
+	}
+	return strings.Join(parts, " ")
+}
+
+// IsProtected reports whether key is reserved for the protobuf runtime and
+// must never be overwritten
+func IsProtected(key string) bool {
+	return strings.HasPrefix(key, "protobuf")
+}
+
+// Merge adds extra pairs to tags, replacing existing values for the same key
+// except for protected keys, which are left untouched
+func Merge(tags, extra []Tag) []Tag {
+	result := append([]Tag(nil), tags...)
+	for _, e := range extra {
+		if IsProtected(e.Key) {
+			continue
+		}
+		replaced := false
+		for i := range result {
+			if result[i].Key == e.Key {
+				result[i].Value = e.Value
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			result = append(result, e)
+		}
+	}
+	return result
+}
@@ -0,0 +1,74 @@
+package structtag
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		tag      string
+		expected []Tag
+		wantErr  bool
+	}{
+		{"empty", "", nil, false},
+		{"single", `db:"user_id"`, []Tag{{"db", "user_id"}}, false},
+		{
+			name:     "multiple",
+			tag:      `db:"user_id" yaml:"userId,omitempty"`,
+			expected: []Tag{{"db", "user_id"}, {"yaml", "userId,omitempty"}},
+		},
+		{
+			name:     "escaped quote",
+			tag:      `doc:"a \"quoted\" word"`,
+			expected: []Tag{{"doc", `a "quoted" word`}},
+		},
+		{"missing key", `:"x"`, nil, true},
+		{"missing value", `db:`, nil, true},
+		{"unquoted value", `db:user_id`, nil, true},
+		{"unterminated value", `db:"user_id`, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tags, err := Parse(tt.tag)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, wantErr %v", tt.tag, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(tags, tt.expected) {
+				t.Errorf("Parse(%q) = %v, expected %v", tt.tag, tags, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	generated, err := Parse(`protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extra, err := Parse(`db:"user_id" json:"userId" protobuf:"bytes,9"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := Format(Merge(generated, extra))
+	expected := `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"userId" db:"user_id"`
+	if got != expected {
+		t.Errorf("Merge() = %s, expected %s", got, expected)
+	}
+}
+
+func TestIsProtected(t *testing.T) {
+	for _, key := range []string{"protobuf", "protobuf_oneof", "protobuf_key", "protobuf_val"} {
+		if !IsProtected(key) {
+			t.Errorf("IsProtected(%q) = false, expected true", key)
+		}
+	}
+	for _, key := range []string{"json", "db", "yaml"} {
+		if IsProtected(key) {
+			t.Errorf("IsProtected(%q) = true, expected false", key)
+		}
+	}
+}
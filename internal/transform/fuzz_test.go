@@ -0,0 +1,284 @@
+package transform
+
+import (
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/benjamin-rood/protogo-values/internal/parser/types"
+	"golang.org/x/tools/txtar"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// transformSeed is protoc-gen-go output recorded in testdata, with the struct
+// fields the plugin rewrote in it
+type transformSeed struct {
+	src      string
+	rewrites []Rewrite
+}
+
+// fieldNames returns the rewritten fields, comma-separated
+func (s transformSeed) fieldNames() string {
+	var names []string
+	for _, r := range s.rewrites {
+		names = append(names, r.Field)
+	}
+	return strings.Join(names, ",")
+}
+
+// fieldTypes returns the rewritten fields, comma-separated, each followed by
+// its element type if its slice type changed, as in Users:common.User
+func (s transformSeed) fieldTypes() string {
+	var entries []string
+	for _, r := range s.rewrites {
+		if elem, ok := strings.CutPrefix(r.OldType, "[]*"); ok {
+			entries = append(entries, r.Field+":"+elem)
+			continue
+		}
+		entries = append(entries, r.Field)
+	}
+	return strings.Join(entries, ",")
+}
+
+// transformSeeds returns the protoc-gen-go output recorded in testdata
+func transformSeeds(f *testing.F) []transformSeed {
+	var seeds []transformSeed
+	delegates, err := filepath.Glob("../../testdata/delegate/*.txtar")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range delegates {
+		archive, err := txtar.ParseFile(path)
+		if err != nil {
+			f.Fatalf("failed to read %s: %v", path, err)
+		}
+		files := make(map[string]string)
+		for _, file := range archive.Files {
+			files[file.Name] = string(file.Data)
+		}
+		for name, delegated := range files {
+			rest, ok := strings.CutPrefix(name, "delegate/")
+			if !ok {
+				continue
+			}
+			seeds = append(seeds, transformSeed{delegated, Rewrites(delegated, files["want/"+rest])})
+		}
+	}
+
+	// Golden output is already transformed; its provenance header lists the
+	// fields that were rewritten, as Struct.Field: []*T -> []T
+	goldens, err := filepath.Glob("../../testdata/golden/*.txtar")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range goldens {
+		archive, err := txtar.ParseFile(path)
+		if err != nil {
+			f.Fatalf("failed to read %s: %v", path, err)
+		}
+		for _, file := range archive.Files {
+			if !strings.HasPrefix(file.Name, "out/") || !strings.HasSuffix(file.Name, ".go") {
+				continue
+			}
+			var rewrites []Rewrite
+			for _, line := range strings.Split(string(file.Data), "\n") {
+				rest, ok := strings.CutPrefix(line, "// \t")
+				if !ok {
+					continue
+				}
+				decl, change, ok := strings.Cut(rest, ": ")
+				structName, field, ok2 := strings.Cut(decl, ".")
+				if !ok || !ok2 {
+					continue
+				}
+				oldType, newType, _ := strings.Cut(change, " -> ")
+				rewrites = append(rewrites, Rewrite{Struct: structName, Field: field, OldType: oldType, NewType: newType})
+			}
+			seeds = append(seeds, transformSeed{string(file.Data), rewrites})
+		}
+	}
+	return seeds
+}
+
+// FuzzTransformField checks that transformField only ever drops the pointer
+// from slice types: Go source stays parseable, no lines are added or
+// removed, only lines naming one of the fields change, and no more of them
+// than declare a pointer slice of those fields
+func FuzzTransformField(f *testing.F) {
+	for _, seed := range transformSeeds(f) {
+		f.Add(seed.src, seed.fieldNames())
+	}
+	f.Add("type T struct {\n\tUsers []*User\n}\n", "Users")
+	f.Add("func (x *T) GetUsers() []*User { return x.Users }\n", "Users")
+	f.Add("package p\n\nvar x = 1 /*Users []*/ + 1\n", "Users")
+
+	f.Fuzz(func(t *testing.T, src, list string) {
+		names := strings.FieldsFunc(list, func(r rune) bool { return r == ',' })
+		out := src
+		for _, name := range names {
+			out = transformField(out, name)
+		}
+
+		if _, err := parser.ParseFile(token.NewFileSet(), "", src, parser.SkipObjectResolution); err == nil {
+			if _, err := parser.ParseFile(token.NewFileSet(), "", out, parser.SkipObjectResolution); err != nil {
+				t.Fatalf("transformed source no longer parses: %v\n%s", err, out)
+			}
+		}
+
+		before := strings.Split(src, "\n")
+		after := strings.Split(out, "\n")
+		if len(before) != len(after) {
+			t.Fatalf("transformField changed the number of lines from %d to %d", len(before), len(after))
+		}
+		for i := range before {
+			if before[i] == after[i] {
+				continue
+			}
+			if !onlyDropsPointers(before[i], after[i]) {
+				t.Fatalf("line %d changed beyond dropping slice pointers:\n%q\n%q", i+1, before[i], after[i])
+			}
+			if !containsAny(before[i], names) {
+				t.Fatalf("line %d names none of the fields %q but changed:\n%q", i+1, names, before[i])
+			}
+		}
+		checkChangedDeclarations(t, before, after, names)
+
+		// A second pass finds nothing left to do
+		again := out
+		for _, name := range names {
+			again = transformField(again, name)
+		}
+		if again != out {
+			t.Fatalf("transformField is not idempotent:\n%q\n%q", out, again)
+		}
+	})
+}
+
+// fuzzImportPath is the Go import path the fuzzed files are generated into
+const fuzzImportPath = "example.com/fuzz"
+
+// FuzzApplyTransformations runs the whole transformation with element types,
+// the file's import path and extra struct tags recorded, as the plugin does.
+// fieldTypes lists the annotated fields, each optionally followed by the Go
+// type of its elements as the file spells it, as in Members:common.User.
+// tags lists extra struct tags as Struct.Field tag, separated by semicolons.
+// The output must still parse if the input did, keep its line count, which
+// Rewrites relies on, change no more declarations than name the fields, and
+// be left unchanged by a second pass.
+func FuzzApplyTransformations(f *testing.F) {
+	for _, seed := range transformSeeds(f) {
+		f.Add(seed.src, seed.fieldTypes(), true, "")
+		f.Add(seed.src, seed.fieldTypes(), false, "")
+	}
+	f.Add("// source: a.proto\n\npackage a\n\ntype T struct {\n\tUsers []*User `json:\"users\"`\n}\n", "Users:User", true, `T.Users db:"users"`)
+	f.Add("// source: a.proto\n\npackage a\n\nimport c \"example.com/common\"\n\ntype T struct {\n\tUsers []*c.User\n\tRoles []*Role\n}\n", "Users:c.User,Roles:other.Role", true, `T.Roles db:"roles";T.Missing x:"y"`)
+
+	f.Fuzz(func(t *testing.T, src, fieldTypes string, scoped bool, tags string) {
+		fields := types.NewAnnotatedFields()
+		var names []string
+		source := sourceFile(src)
+		if scoped {
+			fields.AddFileImportPath(source, fuzzImportPath)
+		}
+		// Element types are resolved against the file's imports, so
+		// qualified ones name a package it imports where possible
+		packages := make(map[string]string)
+		if scope, ok := newFileScope(src, fields); ok {
+			for importPath, name := range scope.imports {
+				packages[name] = importPath
+			}
+		}
+		for _, entry := range strings.Split(fieldTypes, ",") {
+			name, elem, typed := strings.Cut(entry, ":")
+			if name == "" {
+				continue
+			}
+			fields.Add(name)
+			names = append(names, name)
+			if !typed {
+				continue
+			}
+			ident := types.GoIdent{ImportPath: fuzzImportPath, Name: elem}
+			if pkg, goName, ok := strings.Cut(elem, "."); ok {
+				importPath, ok := packages[pkg]
+				if !ok {
+					importPath = "example.com/" + pkg
+				}
+				ident = types.GoIdent{ImportPath: importPath, Name: goName}
+			}
+			fields.AddElemType(name, "."+elem)
+			fields.AddGoType("."+elem, ident)
+		}
+		for _, entry := range strings.Split(tags, ";") {
+			decl, tag, ok := strings.Cut(entry, " ")
+			structName, field, ok2 := strings.Cut(decl, ".")
+			if ok && ok2 {
+				fields.AddStructTags(source, structName, field, tag)
+			}
+		}
+
+		apply := func(content string) string {
+			resp := &pluginpb.CodeGeneratorResponse{
+				File: []*pluginpb.CodeGeneratorResponse_File{{Name: proto.String("a.pb.go"), Content: proto.String(content)}},
+			}
+			if err := ApplyTransformations(resp, fields); err != nil {
+				t.Fatalf("ApplyTransformations() unexpected error: %v", err)
+			}
+			return resp.File[0].GetContent()
+		}
+		out := apply(src)
+
+		if _, err := parser.ParseFile(token.NewFileSet(), "", src, parser.SkipObjectResolution); err == nil {
+			if _, err := parser.ParseFile(token.NewFileSet(), "", out, parser.SkipObjectResolution); err != nil {
+				t.Fatalf("transformed source no longer parses: %v\n%s", err, out)
+			}
+		}
+		before := strings.Split(src, "\n")
+		after := strings.Split(out, "\n")
+		if len(before) != len(after) {
+			t.Fatalf("ApplyTransformations changed the number of lines from %d to %d", len(before), len(after))
+		}
+		checkChangedDeclarations(t, before, after, names)
+		if again := apply(out); again != out {
+			t.Fatalf("ApplyTransformations is not idempotent:\n%q\n%q", out, again)
+		}
+	})
+}
+
+// checkChangedDeclarations checks that no more lines lost a slice pointer
+// than there are lines that can declare a pointer slice of one of the
+// fields, or its getter: those naming a field and holding a []*.
+func checkChangedDeclarations(t *testing.T, before, after, names []string) {
+	t.Helper()
+	changed, declarations := 0, 0
+	for i := range before {
+		if strings.Count(after[i], "[]*") < strings.Count(before[i], "[]*") {
+			changed++
+		}
+		if strings.Contains(before[i], "[]*") && containsAny(before[i], names) {
+			declarations++
+		}
+	}
+	if changed > declarations {
+		t.Fatalf("%d lines lost a slice pointer, but only %d can declare a pointer slice of the fields %q", changed, declarations, names)
+	}
+}
+
+// onlyDropsPointers reports whether after is before with some of its
+// pointer stars removed
+func onlyDropsPointers(before, after string) bool {
+	return len(after) < len(before) &&
+		strings.ReplaceAll(before, "*", "") == strings.ReplaceAll(after, "*", "")
+}
+
+func containsAny(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
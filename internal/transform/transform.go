@@ -2,13 +2,24 @@ package transform
 
 import (
 	"fmt"
+	"go/parser"
+	"go/token"
+	"path"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/benjamin-rood/protogo-values/internal/parallel"
 	"github.com/benjamin-rood/protogo-values/internal/parser/types"
+	"github.com/benjamin-rood/protogo-values/internal/structtag"
 	"google.golang.org/protobuf/types/pluginpb"
 )
 
-// ApplyTransformations modifies the generated Go code to convert pointer slices to value slices
+// ApplyTransformations modifies the generated Go code to convert pointer slices to value slices.
+// Files are transformed concurrently by a bounded worker pool. Each file's
+// result depends only on its own content and the annotated fields, so the
+// output is identical however the work is scheduled.
 func ApplyTransformations(resp *pluginpb.CodeGeneratorResponse, fields *types.AnnotatedFields) error {
 	if resp == nil {
 		return fmt.Errorf("response cannot be nil")
@@ -16,27 +27,181 @@ func ApplyTransformations(resp *pluginpb.CodeGeneratorResponse, fields *types.An
 	if fields == nil {
 		return fmt.Errorf("fields cannot be nil")
 	}
-	
-	for _, file := range resp.File {
-		if file.Content != nil {
-			content := *file.Content
-			content = transformPointerSlices(content, fields)
-			file.Content = &content
+
+	parallel.ForEach(len(resp.File), func(i int) {
+		file := resp.File[i]
+		if file.Content == nil {
+			return
 		}
-	}
+		content := transformPointerSlices(*file.Content, fields)
+		// Struct names are only unique within a package, so only the tags
+		// recorded for the file's own proto source apply
+		content = transformStructTags(content, fields.StructTags(sourceFile(content)))
+		file.Content = &content
+	})
 	return nil
 }
 
-// transformPointerSlices converts []*Type to []Type for annotated fields
+// transformStructTags merges extra tags into the struct tags of the given
+// struct fields, keyed by struct name then field name
+func transformStructTags(content string, tags map[string]map[string]string) string {
+	if len(tags) == 0 {
+		return content
+	}
+
+	lines := strings.Split(content, "\n")
+	var structTags map[string]string
+	for i, line := range lines {
+		// Track which struct declaration we are in
+		if strings.HasPrefix(line, "type ") && strings.HasSuffix(line, " struct {") {
+			structName := strings.TrimSuffix(strings.TrimPrefix(line, "type "), " struct {")
+			structTags = tags[structName]
+			continue
+		}
+		if line == "}" {
+			structTags = nil
+			continue
+		}
+		if structTags == nil {
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "//") {
+			continue
+		}
+		parts := strings.Fields(trimmed)
+		if len(parts) == 0 {
+			continue
+		}
+		extra, ok := structTags[parts[0]]
+		if !ok {
+			continue
+		}
+
+		lines[i] = mergeFieldTag(line, extra)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// mergeFieldTag merges extra into the raw string tag of a struct field line,
+// adding a tag if the field has none. Lines with unparseable tags are left
+// unchanged.
+func mergeFieldTag(line, extra string) string {
+	extraTags, err := structtag.Parse(extra)
+	if err != nil {
+		return line
+	}
+
+	start := strings.Index(line, "`")
+	end := strings.LastIndex(line, "`")
+	if start < 0 || start == end {
+		return line + " `" + structtag.Format(structtag.Merge(nil, extraTags)) + "`"
+	}
+
+	existing, err := structtag.Parse(line[start+1 : end])
+	if err != nil {
+		return line
+	}
+	merged := structtag.Format(structtag.Merge(existing, extraTags))
+	return line[:start+1] + merged + line[end:]
+}
+
+// transformPointerSlices converts []*Type to []Type for annotated fields.
+// Where the element types of a field and the Go package of the file are
+// known, only slices of those types are rewritten, so a field sharing its
+// name with an annotated field in another package is left alone.
 func transformPointerSlices(content string, fields *types.AnnotatedFields) string {
-	for field := range fields.All() {
-		content = transformField(content, field)
+	scope, scoped := newFileScope(content, fields)
+	// Fields are visited in sorted order so the result never depends on map
+	// iteration order
+	for _, field := range fields.Names() {
+		var elems map[string]bool
+		if idents, ok := fields.ElemTypes(field); ok && scoped {
+			elems = scope.typeNames(idents)
+		}
+		content = transformFieldTypes(content, field, elems)
 	}
 	return content
 }
 
+// fileScope describes how a generated file refers to Go types
+type fileScope struct {
+	// importPath is the Go import path of the file's own package
+	importPath string
+	// imports maps each imported Go import path to its package name in the file
+	imports map[string]string
+}
+
+// sourceFile returns the proto file a file generated by protoc-gen-go was
+// generated from, as named by its "// source:" header, or "" if it has none
+func sourceFile(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		if rest, ok := strings.CutPrefix(line, "// source: "); ok {
+			return strings.TrimSpace(rest)
+		}
+		if strings.HasPrefix(line, "package ") {
+			break
+		}
+	}
+	return ""
+}
+
+// newFileScope resolves the Go package of a file generated by protoc-gen-go
+// from its "// source:" header and the import paths recorded for the proto
+// files, and reads its imports. ok is false if either cannot be determined.
+func newFileScope(content string, fields *types.AnnotatedFields) (*fileScope, bool) {
+	importPath, ok := fields.FileImportPath(sourceFile(content))
+	if !ok {
+		return nil, false
+	}
+
+	file, err := parser.ParseFile(token.NewFileSet(), "", content, parser.ImportsOnly)
+	if err != nil {
+		return nil, false
+	}
+	scope := &fileScope{importPath: importPath, imports: make(map[string]string)}
+	for _, spec := range file.Imports {
+		imported, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := path.Base(imported)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		scope.imports[imported] = name
+	}
+	return scope, true
+}
+
+// typeNames returns how the file spells the given types: unqualified in its
+// own package, otherwise qualified with the name it imports their package
+// under. Types from packages the file does not import cannot occur in it.
+func (s *fileScope) typeNames(idents []types.GoIdent) map[string]bool {
+	names := make(map[string]bool)
+	for _, ident := range idents {
+		if ident.ImportPath == s.importPath {
+			names[ident.Name] = true
+			continue
+		}
+		if name, ok := s.imports[ident.ImportPath]; ok {
+			names[name+"."+ident.Name] = true
+		}
+	}
+	return names
+}
+
 // transformField transforms a specific field from pointer slice to value slice
 func transformField(content, fieldName string) string {
+	return transformFieldTypes(content, fieldName, nil)
+}
+
+// transformFieldTypes transforms a specific field from pointer slice to
+// value slice if its element type is one of elems, or whatever its element
+// type if elems is nil
+func transformFieldTypes(content, fieldName string, elems map[string]bool) string {
 	if fieldName == "" {
 		return content
 	}
@@ -63,7 +228,8 @@ func transformField(content, fieldName string) string {
 					if sliceIndex >= 0 {
 						// Check if there's only whitespace between field name and []*
 						whitespace := remaining[:sliceIndex]
-						if strings.TrimSpace(whitespace) == "" && len(whitespace) > 0 {
+						if strings.TrimSpace(whitespace) == "" && len(whitespace) > 0 &&
+							elemMatches(remaining[sliceIndex+3:], elems) {
 							// Replace []*Type with []Type
 							beforeSlice := line[:fieldIndex+len(fieldName)+sliceIndex]
 							afterSlice := line[fieldIndex+len(fieldName)+sliceIndex+3:] // +3 for "[]*"
@@ -76,10 +242,79 @@ func transformField(content, fieldName string) string {
 		
 		// Replace getter methods: GetFieldName() []*Type -> GetFieldName() []Type
 		oldGetter := "Get" + fieldName + "() []*"
-		if strings.Contains(line, oldGetter) {
+		if index := strings.Index(line, oldGetter); index >= 0 &&
+			elemMatches(line[index+len(oldGetter):], elems) {
 			lines[i] = strings.ReplaceAll(line, oldGetter, "Get"+fieldName+"() []")
 		}
 	}
 	
 	return strings.Join(lines, "\n")
 }
+
+// elemMatches reports whether rest starts with a type name that is one of
+// elems, or with any type name if elems is nil. Anything else, such as the
+// end of a block comment, is not a slice element to rewrite.
+func elemMatches(rest string, elems map[string]bool) bool {
+	r, _ := utf8.DecodeRuneInString(rest)
+	if r != '_' && !unicode.IsLetter(r) {
+		return false
+	}
+	if elems == nil {
+		return true
+	}
+	end := strings.IndexAny(rest, " \t`{}()[],;")
+	if end < 0 {
+		end = len(rest)
+	}
+	return elems[rest[:end]]
+}
+
+// Rewrite describes a struct field declaration changed by the transformer
+type Rewrite struct {
+	Struct string
+	Field  string
+	// OldType and NewType are the field's Go type before and after; they are
+	// equal if only the struct tags changed
+	OldType string
+	NewType string
+}
+
+// Rewrites compares a file generated by protoc-gen-go with its transformed
+// content and returns the struct field declarations that changed, in file
+// order. The transformer only edits lines in place, so the two are compared
+// line by line.
+func Rewrites(before, after string) []Rewrite {
+	oldLines := strings.Split(before, "\n")
+	newLines := strings.Split(after, "\n")
+	if len(oldLines) != len(newLines) {
+		return nil
+	}
+
+	var rewrites []Rewrite
+	var structName string
+	for i, line := range oldLines {
+		if strings.HasPrefix(line, "type ") && strings.HasSuffix(line, " struct {") {
+			structName = strings.TrimSuffix(strings.TrimPrefix(line, "type "), " struct {")
+			continue
+		}
+		if line == "}" {
+			structName = ""
+			continue
+		}
+		if structName == "" || line == newLines[i] {
+			continue
+		}
+		oldParts := strings.Fields(line)
+		newParts := strings.Fields(newLines[i])
+		if len(oldParts) < 2 || len(newParts) < 2 {
+			continue
+		}
+		rewrites = append(rewrites, Rewrite{
+			Struct:  structName,
+			Field:   oldParts[0],
+			OldType: oldParts[1],
+			NewType: newParts[1],
+		})
+	}
+	return rewrites
+}
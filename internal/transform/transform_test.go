@@ -107,6 +107,76 @@ func (m *Message) GetProducts() []Product {
 	}
 }
 
+func TestTransformPointerSlicesResolvesImports(t *testing.T) {
+	fields := types.NewAnnotatedFields()
+	fields.AddFileImportPath("app.proto", "example.com/app")
+	fields.AddGoType(".app.Role", types.GoIdent{ImportPath: "example.com/app", Name: "Role"})
+	fields.AddGoType(".common.User", types.GoIdent{ImportPath: "example.com/common", Name: "User"})
+	fields.Add("Roles")
+	fields.AddElemType("Roles", ".app.Role")
+	fields.Add("Members")
+	fields.AddElemType("Members", ".common.User")
+
+	content := `// source: app.proto
+
+package app
+
+import (
+	common "example.com/common"
+	other "example.com/other"
+)
+
+type Team struct {
+	Members []*common.User
+	Roles   []*Role
+}
+
+type Legacy struct {
+	Members []*other.User
+	Roles   []*other.Role
+}
+
+func (x *Team) GetMembers() []*common.User {
+	return x.Members
+}
+
+func (x *Legacy) GetMembers() []*other.User {
+	return x.Members
+}`
+
+	expected := `// source: app.proto
+
+package app
+
+import (
+	common "example.com/common"
+	other "example.com/other"
+)
+
+type Team struct {
+	Members []common.User
+	Roles   []Role
+}
+
+type Legacy struct {
+	Members []*other.User
+	Roles   []*other.Role
+}
+
+func (x *Team) GetMembers() []common.User {
+	return x.Members
+}
+
+func (x *Legacy) GetMembers() []*other.User {
+	return x.Members
+}`
+
+	result := transformPointerSlices(content, fields)
+	if result != expected {
+		t.Errorf("transformPointerSlices() failed:\nExpected:\n%s\nGot:\n%s", expected, result)
+	}
+}
+
 func TestApplyTransformations(t *testing.T) {
 	fields := types.NewAnnotatedFields()
 	fields.Add("Users")
@@ -344,3 +414,177 @@ func TestTransformFieldConcurrent(t *testing.T) {
 		t.Error("Expected transformation not found")
 	}
 }
+
+func TestTransformStructTags(t *testing.T) {
+	content := "type Profile struct {\n" +
+		"\tstate protoimpl.MessageState `protogen:\"open.v1\"`\n" +
+		"\tUserId string `protobuf:\"bytes,1,opt,name=user_id,json=userId,proto3\" json:\"user_id,omitempty\"`\n" +
+		"\tName string `protobuf:\"bytes,2,opt,name=name,proto3\" json:\"name,omitempty\"`\n" +
+		"}\n" +
+		"\n" +
+		"type Other struct {\n" +
+		"\tUserId string `protobuf:\"bytes,1,opt,name=user_id,json=userId,proto3\" json:\"user_id,omitempty\"`\n" +
+		"}\n" +
+		"\n" +
+		"type Profile_Email struct {\n" +
+		"\tEmail string `protobuf:\"bytes,3,opt,name=email,proto3,oneof\"`\n" +
+		"}\n"
+
+	tags := map[string]map[string]string{
+		"Profile": {
+			"UserId": `db:"user_id" yaml:"userId"`,
+			"Name":   `json:"display_name" protobuf:"bytes,9"`,
+		},
+		"Profile_Email": {
+			"Email": `db:"email"`,
+		},
+	}
+
+	expected := "type Profile struct {\n" +
+		"\tstate protoimpl.MessageState `protogen:\"open.v1\"`\n" +
+		"\tUserId string `protobuf:\"bytes,1,opt,name=user_id,json=userId,proto3\" json:\"user_id,omitempty\" db:\"user_id\" yaml:\"userId\"`\n" +
+		"\tName string `protobuf:\"bytes,2,opt,name=name,proto3\" json:\"display_name\"`\n" +
+		"}\n" +
+		"\n" +
+		"type Other struct {\n" +
+		"\tUserId string `protobuf:\"bytes,1,opt,name=user_id,json=userId,proto3\" json:\"user_id,omitempty\"`\n" +
+		"}\n" +
+		"\n" +
+		"type Profile_Email struct {\n" +
+		"\tEmail string `protobuf:\"bytes,3,opt,name=email,proto3,oneof\" db:\"email\"`\n" +
+		"}\n"
+
+	result := transformStructTags(content, tags)
+	if result != expected {
+		t.Errorf("transformStructTags() failed:\nExpected:\n%s\nGot:\n%s", expected, result)
+	}
+}
+
+func TestTransformStructTagsNoTags(t *testing.T) {
+	content := "type Profile struct {\n\tName string\n}\n"
+	if result := transformStructTags(content, nil); result != content {
+		t.Errorf("transformStructTags() with no tags changed content:\n%s", result)
+	}
+
+	tags := map[string]map[string]string{"Profile": {"Name": `db:"name"`}}
+	expected := "type Profile struct {\n\tName string `db:\"name\"`\n}\n"
+	if result := transformStructTags(content, tags); result != expected {
+		t.Errorf("transformStructTags() on untagged field:\nExpected:\n%s\nGot:\n%s", expected, result)
+	}
+}
+
+func TestApplyTransformationsScopesStructTags(t *testing.T) {
+	fields := types.NewAnnotatedFields()
+	fields.AddStructTags("app/user.proto", "User", "Name", `db:"name"`)
+
+	user := func(source string) *string {
+		return proto.String("// source: " + source + `
+
+package gen
+
+type User struct {
+	Name string ` + "`json:\"name,omitempty\"`" + `
+}
+`)
+	}
+	resp := &pluginpb.CodeGeneratorResponse{
+		File: []*pluginpb.CodeGeneratorResponse_File{
+			{Name: proto.String("app/user.pb.go"), Content: user("app/user.proto")},
+			{Name: proto.String("admin/user.pb.go"), Content: user("admin/user.proto")},
+		},
+	}
+	if err := ApplyTransformations(resp, fields); err != nil {
+		t.Fatalf("ApplyTransformations() returned error: %v", err)
+	}
+
+	if got := resp.File[0].GetContent(); !strings.Contains(got, "`json:\"name,omitempty\" db:\"name\"`") {
+		t.Errorf("expected app.User.Name to be tagged:\n%s", got)
+	}
+	if got := resp.File[1].GetContent(); strings.Contains(got, `db:"name"`) {
+		t.Errorf("admin.User.Name shares its struct name but not its file, and should not be tagged:\n%s", got)
+	}
+}
+
+// TestApplyTransformationsDeterministic checks that concurrent processing of
+// many files yields the same bytes as a sequential pass on every run. Run it
+// with -race (make test-race) to also check the worker pool.
+func TestApplyTransformationsDeterministic(t *testing.T) {
+	fields := types.NewAnnotatedFields()
+	for i := range 20 {
+		fields.Add(fmt.Sprintf("Items%d", i))
+	}
+	fields.Add("Users")
+	fields.AddStructTags("gen.proto", "Message", "Users", `db:"users"`)
+
+	newResponse := func() *pluginpb.CodeGeneratorResponse {
+		resp := &pluginpb.CodeGeneratorResponse{}
+		for i := range 200 {
+			var b strings.Builder
+			fmt.Fprintf(&b, "// source: gen.proto\n\npackage gen%d\n\ntype Message struct {\n", i)
+			fmt.Fprintf(&b, "\tUsers []*User `json:\"users,omitempty\"`\n")
+			for j := range 20 {
+				fmt.Fprintf(&b, "\tItems%d []*Item%d\n", j, (i+j)%7)
+			}
+			b.WriteString("}\n")
+			resp.File = append(resp.File, &pluginpb.CodeGeneratorResponse_File{
+				Name:    proto.String(fmt.Sprintf("gen%d.pb.go", i)),
+				Content: proto.String(b.String()),
+			})
+		}
+		return resp
+	}
+
+	expected := newResponse()
+	for _, file := range expected.File {
+		content := transformPointerSlices(file.GetContent(), fields)
+		content = transformStructTags(content, fields.StructTags("gen.proto"))
+		file.Content = &content
+	}
+	want, err := proto.MarshalOptions{Deterministic: true}.Marshal(expected)
+	if err != nil {
+		t.Fatalf("failed to marshal expected response: %v", err)
+	}
+
+	for run := range 10 {
+		resp := newResponse()
+		if err := ApplyTransformations(resp, fields); err != nil {
+			t.Fatalf("ApplyTransformations() returned error: %v", err)
+		}
+		got, err := proto.MarshalOptions{Deterministic: true}.Marshal(resp)
+		if err != nil {
+			t.Fatalf("failed to marshal response: %v", err)
+		}
+		if string(got) != string(want) {
+			t.Fatalf("run %d: output differs from a sequential pass", run)
+		}
+	}
+}
+
+func TestRewrites(t *testing.T) {
+	before := `type Order struct {
+	state protoimpl.MessageState
+	Items []*Item ` + "`protobuf:\"bytes,1,rep,name=items\"`" + `
+	Notes []*Note ` + "`protobuf:\"bytes,2,rep,name=notes\"`" + `
+	Id string ` + "`protobuf:\"bytes,3,opt,name=id\"`" + `
+}
+
+func (x *Order) GetItems() []*Item {
+	return nil
+}`
+	fields := types.NewAnnotatedFields()
+	fields.Add("Items")
+	after := transformPointerSlices(before, fields)
+	after = transformStructTags(after, map[string]map[string]string{"Order": {"Id": `db:"id"`}})
+
+	want := []Rewrite{
+		{Struct: "Order", Field: "Items", OldType: "[]*Item", NewType: "[]Item"},
+		{Struct: "Order", Field: "Id", OldType: "string", NewType: "string"},
+	}
+	got := Rewrites(before, after)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Rewrites() = %+v, want %+v", got, want)
+	}
+	if got := Rewrites(before, before); len(got) != 0 {
+		t.Errorf("Rewrites() of unchanged content = %+v, want none", got)
+	}
+}
@@ -0,0 +1,129 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Bound is a numeric comparison such as gte=0. Value holds the literal as
+// written so it can be emitted without losing precision.
+type Bound struct {
+	Op    string
+	Value string
+}
+
+// Rules is a parsed validation_rule option
+type Rules struct {
+	// Required requires a singular message field to be set
+	Required bool
+	// MinLen and MaxLen bound the number of elements of a repeated or map field
+	MinLen *uint64
+	MaxLen *uint64
+	// Pattern is a regular expression a string field must match
+	Pattern *string
+	// Bounds constrain a numeric field
+	Bounds []Bound
+}
+
+// boundOps maps rule names to the Go comparison operators they require
+var boundOps = map[string]string{
+	"gt":  ">",
+	"gte": ">=",
+	"lt":  "<",
+	"lte": "<=",
+}
+
+// Parse parses a rule string: a comma-separated list of rules, each either
+// a bare name or name=value. Values containing commas must be written as a
+// double-quoted Go string literal.
+//
+//	required
+//	min_len=1,max_len=100
+//	pattern="^[a-z0-9_]+$"
+//	gte=0,lt=150
+func Parse(rule string) (*Rules, error) {
+	rules := &Rules{}
+	rest := strings.TrimSpace(rule)
+	if rest == "" {
+		return nil, fmt.Errorf("empty validation rule")
+	}
+
+	for rest != "" {
+		name, value, hasValue, remaining, err := nextRule(rest)
+		if err != nil {
+			return nil, err
+		}
+		rest = remaining
+
+		switch name {
+		case "required":
+			if hasValue {
+				return nil, fmt.Errorf("rule required does not take a value")
+			}
+			rules.Required = true
+		case "min_len", "max_len":
+			n, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s requires a non-negative integer, got %q", name, value)
+			}
+			if name == "min_len" {
+				rules.MinLen = &n
+			} else {
+				rules.MaxLen = &n
+			}
+		case "pattern":
+			if _, err := regexp.Compile(value); err != nil {
+				return nil, fmt.Errorf("rule pattern: %w", err)
+			}
+			rules.Pattern = &value
+		case "gt", "gte", "lt", "lte":
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return nil, fmt.Errorf("rule %s requires a number, got %q", name, value)
+			}
+			rules.Bounds = append(rules.Bounds, Bound{Op: boundOps[name], Value: value})
+		default:
+			return nil, fmt.Errorf("unknown validation rule %q", name)
+		}
+	}
+
+	if rules.MinLen != nil && rules.MaxLen != nil && *rules.MinLen > *rules.MaxLen {
+		return nil, fmt.Errorf("min_len %d is greater than max_len %d", *rules.MinLen, *rules.MaxLen)
+	}
+	return rules, nil
+}
+
+// nextRule splits the first rule off s
+func nextRule(s string) (name, value string, hasValue bool, rest string, err error) {
+	end := strings.IndexAny(s, "=,")
+	if end < 0 {
+		return strings.TrimSpace(s), "", false, "", nil
+	}
+	name = strings.TrimSpace(s[:end])
+	if name == "" {
+		return "", "", false, "", fmt.Errorf("missing rule name in %q", s)
+	}
+	if s[end] == ',' {
+		return name, "", false, strings.TrimSpace(s[end+1:]), nil
+	}
+
+	s = strings.TrimSpace(s[end+1:])
+	if strings.HasPrefix(s, `"`) {
+		quoted, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return "", "", false, "", fmt.Errorf("rule %s: malformed quoted value", name)
+		}
+		value, _ = strconv.Unquote(quoted)
+		s = strings.TrimSpace(s[len(quoted):])
+		if s != "" && !strings.HasPrefix(s, ",") {
+			return "", "", false, "", fmt.Errorf("rule %s: unexpected %q after quoted value", name, s)
+		}
+		return name, value, true, strings.TrimSpace(strings.TrimPrefix(s, ",")), nil
+	}
+
+	if i := strings.Index(s, ","); i >= 0 {
+		return name, strings.TrimSpace(s[:i]), true, strings.TrimSpace(s[i+1:]), nil
+	}
+	return name, strings.TrimSpace(s), true, "", nil
+}
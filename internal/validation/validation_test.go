@@ -0,0 +1,60 @@
+package validation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func uint64Ptr(n uint64) *uint64 { return &n }
+func stringPtr(s string) *string { return &s }
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     string
+		expected *Rules
+		wantErr  bool
+	}{
+		{"required", "required", &Rules{Required: true}, false},
+		{
+			name:     "length bounds",
+			rule:     "min_len=1, max_len=100",
+			expected: &Rules{MinLen: uint64Ptr(1), MaxLen: uint64Ptr(100)},
+		},
+		{
+			name:     "unquoted pattern",
+			rule:     "pattern=^[a-z]+$",
+			expected: &Rules{Pattern: stringPtr("^[a-z]+$")},
+		},
+		{
+			name:     "quoted pattern with comma",
+			rule:     `pattern="^[a-z]{1,8}$",required`,
+			expected: &Rules{Pattern: stringPtr("^[a-z]{1,8}$"), Required: true},
+		},
+		{
+			name:     "numeric range",
+			rule:     "gte=0,lt=1.5",
+			expected: &Rules{Bounds: []Bound{{">=", "0"}, {"<", "1.5"}}},
+		},
+		{"empty", "", nil, true},
+		{"unknown rule", "unique", nil, true},
+		{"required with value", "required=true", nil, true},
+		{"negative length", "min_len=-1", nil, true},
+		{"min above max", "min_len=5,max_len=2", nil, true},
+		{"bad pattern", "pattern=[a-z", nil, true},
+		{"bad bound", "gt=ten", nil, true},
+		{"trailing junk after quote", `pattern="a"b`, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := Parse(tt.rule)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, wantErr %v", tt.rule, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(rules, tt.expected) {
+				t.Errorf("Parse(%q) = %+v, expected %+v", tt.rule, rules, tt.expected)
+			}
+		})
+	}
+}
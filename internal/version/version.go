@@ -0,0 +1,45 @@
+// Package version reports the version of protoc-gen-go-values and of the
+// protobuf runtime it is built with.
+package version
+
+import (
+	"runtime/debug"
+)
+
+// Version overrides the version read from the build info. Release builds
+// may set it with -ldflags "-X github.com/benjamin-rood/protogo-values/internal/version.Version=v1.2.3".
+var Version string
+
+// protobufModule is the module path of the protobuf runtime
+const protobufModule = "google.golang.org/protobuf"
+
+// String returns the version of protoc-gen-go-values: Version if set,
+// otherwise the main module version recorded by go install, or "(devel)"
+// for builds from a working tree
+func String() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// Protobuf returns the version of the google.golang.org/protobuf module
+// built into the binary, or "(unknown)" if the build info does not record it
+func Protobuf() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(unknown)"
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == protobufModule {
+			if dep.Replace != nil {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return "(unknown)"
+}
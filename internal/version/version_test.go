@@ -0,0 +1,21 @@
+package version
+
+import "testing"
+
+func TestString(t *testing.T) {
+	if got := String(); got == "" {
+		t.Error("String() is empty")
+	}
+
+	Version = "v1.2.3"
+	t.Cleanup(func() { Version = "" })
+	if got := String(); got != "v1.2.3" {
+		t.Errorf("String() = %q, want the Version override", got)
+	}
+}
+
+func TestProtobuf(t *testing.T) {
+	if got := Protobuf(); got == "" {
+		t.Error("Protobuf() is empty")
+	}
+}
@@ -30,6 +30,121 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// ValueSlicePolicy selects how a repeated message field without an explicit
+// value_slice is generated.
+type ValueSlicePolicy int32
+
+const (
+	// Inherit the file-level policy; at file level, same as
+	// VALUE_SLICE_POLICY_EXPLICIT.
+	ValueSlicePolicy_VALUE_SLICE_POLICY_UNSPECIFIED ValueSlicePolicy = 0
+	// Only fields setting value_slice = true are value slices. On a field,
+	// this opts out of a file-level VALUE_SLICE_POLICY_AUTO.
+	ValueSlicePolicy_VALUE_SLICE_POLICY_EXPLICIT ValueSlicePolicy = 1
+	// Value slices are generated for small, non-recursive element types: the
+	// plugin estimates the element's Go struct size from its descriptor and
+	// compares it with the auto_max_bytes plugin parameter (default 128).
+	// Each decision is reported as a note on stderr.
+	ValueSlicePolicy_VALUE_SLICE_POLICY_AUTO ValueSlicePolicy = 2
+)
+
+// Enum value maps for ValueSlicePolicy.
+var (
+	ValueSlicePolicy_name = map[int32]string{
+		0: "VALUE_SLICE_POLICY_UNSPECIFIED",
+		1: "VALUE_SLICE_POLICY_EXPLICIT",
+		2: "VALUE_SLICE_POLICY_AUTO",
+	}
+	ValueSlicePolicy_value = map[string]int32{
+		"VALUE_SLICE_POLICY_UNSPECIFIED": 0,
+		"VALUE_SLICE_POLICY_EXPLICIT":    1,
+		"VALUE_SLICE_POLICY_AUTO":        2,
+	}
+)
+
+func (x ValueSlicePolicy) Enum() *ValueSlicePolicy {
+	p := new(ValueSlicePolicy)
+	*p = x
+	return p
+}
+
+func (x ValueSlicePolicy) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ValueSlicePolicy) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_protogo_values_options_proto_enumTypes[0].Descriptor()
+}
+
+func (ValueSlicePolicy) Type() protoreflect.EnumType {
+	return &file_proto_protogo_values_options_proto_enumTypes[0]
+}
+
+func (x ValueSlicePolicy) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ValueSlicePolicy.Descriptor instead.
+func (ValueSlicePolicy) EnumDescriptor() ([]byte, []int) {
+	return file_proto_protogo_values_options_proto_rawDescGZIP(), []int{0}
+}
+
+// PresenceStyle selects how a mirror struct represents a scalar field with
+// explicit presence (proto2 optional, proto3 optional, or editions
+// field_presence = EXPLICIT).
+type PresenceStyle int32
+
+const (
+	// Same as PRESENCE_STYLE_POINTER.
+	PresenceStyle_PRESENCE_STYLE_UNSPECIFIED PresenceStyle = 0
+	// *T, nil when the field is unset.
+	PresenceStyle_PRESENCE_STYLE_POINTER PresenceStyle = 1
+	// T plus a Has<Field> bool. An unset field holds its default value, which
+	// for proto2 is the declared [default = ...].
+	PresenceStyle_PRESENCE_STYLE_HAS_FLAG PresenceStyle = 2
+)
+
+// Enum value maps for PresenceStyle.
+var (
+	PresenceStyle_name = map[int32]string{
+		0: "PRESENCE_STYLE_UNSPECIFIED",
+		1: "PRESENCE_STYLE_POINTER",
+		2: "PRESENCE_STYLE_HAS_FLAG",
+	}
+	PresenceStyle_value = map[string]int32{
+		"PRESENCE_STYLE_UNSPECIFIED": 0,
+		"PRESENCE_STYLE_POINTER":     1,
+		"PRESENCE_STYLE_HAS_FLAG":    2,
+	}
+)
+
+func (x PresenceStyle) Enum() *PresenceStyle {
+	p := new(PresenceStyle)
+	*p = x
+	return p
+}
+
+func (x PresenceStyle) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PresenceStyle) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_protogo_values_options_proto_enumTypes[1].Descriptor()
+}
+
+func (PresenceStyle) Type() protoreflect.EnumType {
+	return &file_proto_protogo_values_options_proto_enumTypes[1]
+}
+
+func (x PresenceStyle) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PresenceStyle.Descriptor instead.
+func (PresenceStyle) EnumDescriptor() ([]byte, []int) {
+	return file_proto_protogo_values_options_proto_rawDescGZIP(), []int{1}
+}
+
 // FieldOptions provides structured options for future extensibility.
 // This allows adding new field-level options without defining new extensions.
 //
@@ -39,9 +154,103 @@ const (
 type FieldOptions struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// value_slice controls value vs pointer slice generation
-	ValueSlice    *bool `protobuf:"varint,1,opt,name=value_slice,json=valueSlice,proto3,oneof" json:"value_slice,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	ValueSlice *bool `protobuf:"varint,1,opt,name=value_slice,json=valueSlice,proto3,oneof" json:"value_slice,omitempty"`
+	// omit_empty controls whether an empty repeated, map or message field is
+	// omitted by encoding/json. protoc-gen-go tags every field with omitempty;
+	// false removes it, so empty fields encode as null. true on a singular
+	// message field also adds omitzero, omitting a set but empty message.
+	// Messages declaring the option, and the targets of such message fields,
+	// get a generated IsZero() bool method in the sibling _values.pb.go file.
+	//
+	// Example usage:
+	//   Address address = 1 [(protogo_values.field_opts).omit_empty = true];
+	//   repeated Tag tags = 2 [(protogo_values.field_opts).omit_empty = false];
+	OmitEmpty *bool `protobuf:"varint,2,opt,name=omit_empty,json=omitEmpty,proto3,oneof" json:"omit_empty,omitempty"`
+	// validation_rule declares constraints checked by a generated
+	// Validate() error method in the sibling _values.pb.go file. Rules are
+	// comma separated; values containing commas are double-quoted:
+	//   required               singular message field must be set
+	//   min_len=N, max_len=N   element count of a repeated or map field
+	//   pattern="regex"        string field must match
+	//   gt=N, gte=N, lt=N, lte=N  numeric field range
+	//
+	// Example usage:
+	//   repeated Item items = 1 [(protogo_values.field_opts).validation_rule = "min_len=1,max_len=100"];
+	ValidationRule *string `protobuf:"bytes,3,opt,name=validation_rule,json=validationRule,proto3,oneof" json:"validation_rule,omitempty"`
+	// json_name_override replaces the field's JSON name. The descriptor's
+	// json_name is rewritten before code generation, so protojson and the
+	// generated protobuf tag use it, and the json struct tag is rewritten to
+	// match. The name must not clash with another field's JSON or proto name.
+	//
+	// Example usage:
+	//   string user_id = 1 [(protogo_values.field_opts).json_name_override = "uid"];
+	JsonNameOverride *string `protobuf:"bytes,4,opt,name=json_name_override,json=jsonNameOverride,proto3,oneof" json:"json_name_override,omitempty"`
+	// value_map generates value-typed accessors for a map field whose values
+	// are messages. The map[K]*Type field itself is left untouched; instead
+	// <Field>Values() map[K]Type and Set<Field>Values(map[K]Type) are emitted
+	// in a sibling _values.pb.go file.
+	//
+	// Example usage:
+	//   map<string, User> users_by_id = 3 [(protogo_values.field_opts).value_map = true];
+	ValueMap *bool `protobuf:"varint,5,opt,name=value_map,json=valueMap,proto3,oneof" json:"value_map,omitempty"`
+	// nullable = false marks a singular message field as always present.
+	// protoc-gen-go still generates *Type, so the plugin emits a plain Go
+	// mirror struct (<Message>Mirror, without protobuf runtime state) that
+	// embeds the field's own mirror by value, plus ToMirror()/ToProto()
+	// converters in the sibling _values.pb.go file. Recursive chains of
+	// non-nullable fields cannot be embedded by value and are rejected.
+	//
+	// Example usage:
+	//   User customer = 1 [(protogo_values.field_opts).nullable = false];
+	Nullable *bool `protobuf:"varint,6,opt,name=nullable,proto3,oneof" json:"nullable,omitempty"`
+	// native_wkt maps a singular well-known-type field to a native Go type in
+	// the message's mirror struct: google.protobuf.Timestamp becomes time.Time,
+	// Duration becomes time.Duration and the wrapper types become *int64,
+	// *string and so on (BytesValue becomes []byte). A nil Timestamp or
+	// Duration converts to the zero value and back. The zero value always
+	// converts back to nil, so a set Timestamp or Duration holding the zero
+	// value loses its presence on the round trip. Overrides the file-level
+	// (protogo_values.file_opts).native_wkt setting.
+	//
+	// Example usage:
+	//   google.protobuf.Timestamp created_at = 4 [(protogo_values.field_opts).native_wkt = true];
+	NativeWkt *bool `protobuf:"varint,7,opt,name=native_wkt,json=nativeWkt,proto3,oneof" json:"native_wkt,omitempty"`
+	// go_type overrides the Go type of a singular scalar or bytes field in the
+	// message's mirror struct, in the spirit of gogoproto customtype. The value
+	// is a package-qualified type name.
+	//
+	// go_converter names the package-qualified prefix of the user-declared
+	// functions converting between the proto and custom types:
+	//   func <Prefix>FromProto(v ProtoType) CustomType
+	//   func <Prefix>ToProto(v CustomType) ProtoType
+	// Both options must be set together. The generator type-checks the
+	// converters with go/packages before emitting code.
+	//
+	// Example usage:
+	//   bytes id = 1 [
+	//     (protogo_values.field_opts).go_type = "github.com/google/uuid.UUID",
+	//     (protogo_values.field_opts).go_converter = "example.com/convert.UUID"
+	//   ];
+	GoType      *string `protobuf:"bytes,8,opt,name=go_type,json=goType,proto3,oneof" json:"go_type,omitempty"`
+	GoConverter *string `protobuf:"bytes,9,opt,name=go_converter,json=goConverter,proto3,oneof" json:"go_converter,omitempty"`
+	// go_tags adds struct tags to the generated struct field, in the usual
+	// key:"value" format. An existing key such as json is replaced, but the
+	// protobuf and protobuf_oneof tags the runtime depends on can never be
+	// overwritten.
+	//
+	// Example usage:
+	//   string user_id = 1 [(protogo_values.field_opts).go_tags = "db:\"user_id\" yaml:\"userId\""];
+	GoTags *string `protobuf:"bytes,10,opt,name=go_tags,json=goTags,proto3,oneof" json:"go_tags,omitempty"`
+	// value_slice_policy lets the plugin decide value_slice for a repeated
+	// message field; see ValueSlicePolicy. An explicit value_slice always
+	// wins, so the two cannot both be set on a field. Overrides the
+	// file-level (protogo_values.file_opts).value_slice_policy.
+	//
+	// Example usage:
+	//   repeated Point route = 1 [(protogo_values.field_opts).value_slice_policy = VALUE_SLICE_POLICY_AUTO];
+	ValueSlicePolicy *ValueSlicePolicy `protobuf:"varint,11,opt,name=value_slice_policy,json=valueSlicePolicy,proto3,enum=protogo_values.ValueSlicePolicy,oneof" json:"value_slice_policy,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
 }
 
 func (x *FieldOptions) Reset() {
@@ -81,6 +290,261 @@ func (x *FieldOptions) GetValueSlice() bool {
 	return false
 }
 
+func (x *FieldOptions) GetOmitEmpty() bool {
+	if x != nil && x.OmitEmpty != nil {
+		return *x.OmitEmpty
+	}
+	return false
+}
+
+func (x *FieldOptions) GetValidationRule() string {
+	if x != nil && x.ValidationRule != nil {
+		return *x.ValidationRule
+	}
+	return ""
+}
+
+func (x *FieldOptions) GetJsonNameOverride() string {
+	if x != nil && x.JsonNameOverride != nil {
+		return *x.JsonNameOverride
+	}
+	return ""
+}
+
+func (x *FieldOptions) GetValueMap() bool {
+	if x != nil && x.ValueMap != nil {
+		return *x.ValueMap
+	}
+	return false
+}
+
+func (x *FieldOptions) GetNullable() bool {
+	if x != nil && x.Nullable != nil {
+		return *x.Nullable
+	}
+	return false
+}
+
+func (x *FieldOptions) GetNativeWkt() bool {
+	if x != nil && x.NativeWkt != nil {
+		return *x.NativeWkt
+	}
+	return false
+}
+
+func (x *FieldOptions) GetGoType() string {
+	if x != nil && x.GoType != nil {
+		return *x.GoType
+	}
+	return ""
+}
+
+func (x *FieldOptions) GetGoConverter() string {
+	if x != nil && x.GoConverter != nil {
+		return *x.GoConverter
+	}
+	return ""
+}
+
+func (x *FieldOptions) GetGoTags() string {
+	if x != nil && x.GoTags != nil {
+		return *x.GoTags
+	}
+	return ""
+}
+
+func (x *FieldOptions) GetValueSlicePolicy() ValueSlicePolicy {
+	if x != nil && x.ValueSlicePolicy != nil {
+		return *x.ValueSlicePolicy
+	}
+	return ValueSlicePolicy_VALUE_SLICE_POLICY_UNSPECIFIED
+}
+
+// FileOptions provides file-level defaults for the field options above.
+//
+// Example usage:
+//
+//	option (protogo_values.file_opts).native_wkt = true;
+type FileOptions struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// native_wkt enables well-known-type mapping for every singular
+	// well-known-type field in the file; see FieldOptions.native_wkt.
+	NativeWkt *bool `protobuf:"varint,1,opt,name=native_wkt,json=nativeWkt,proto3,oneof" json:"native_wkt,omitempty"`
+	// value_slice_policy applies a ValueSlicePolicy to every repeated message
+	// field in the file that sets neither value_slice nor its own policy.
+	//
+	// Example usage:
+	//   option (protogo_values.file_opts).value_slice_policy = VALUE_SLICE_POLICY_AUTO;
+	ValueSlicePolicy *ValueSlicePolicy `protobuf:"varint,2,opt,name=value_slice_policy,json=valueSlicePolicy,proto3,enum=protogo_values.ValueSlicePolicy,oneof" json:"value_slice_policy,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *FileOptions) Reset() {
+	*x = FileOptions{}
+	mi := &file_proto_protogo_values_options_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileOptions) ProtoMessage() {}
+
+func (x *FileOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_protogo_values_options_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileOptions.ProtoReflect.Descriptor instead.
+func (*FileOptions) Descriptor() ([]byte, []int) {
+	return file_proto_protogo_values_options_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *FileOptions) GetNativeWkt() bool {
+	if x != nil && x.NativeWkt != nil {
+		return *x.NativeWkt
+	}
+	return false
+}
+
+func (x *FileOptions) GetValueSlicePolicy() ValueSlicePolicy {
+	if x != nil && x.ValueSlicePolicy != nil {
+		return *x.ValueSlicePolicy
+	}
+	return ValueSlicePolicy_VALUE_SLICE_POLICY_UNSPECIFIED
+}
+
+// OneofOptions controls code generated for a oneof.
+//
+// Example usage:
+//
+//	oneof payment {
+//	  option (protogo_values.oneof_opts).value_oneof = true;
+//	  Card card = 1;
+//	  string voucher = 2;
+//	}
+type OneofOptions struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// value_oneof generates a sealed <Message>_<Oneof>Value interface with a
+	// value-typed case struct per member, and <Oneof>Value() and
+	// Set<Oneof>Value() accessors converting to and from protoc-gen-go's
+	// wrapper types, in the sibling _values.pb.go file.
+	ValueOneof    *bool `protobuf:"varint,1,opt,name=value_oneof,json=valueOneof,proto3,oneof" json:"value_oneof,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OneofOptions) Reset() {
+	*x = OneofOptions{}
+	mi := &file_proto_protogo_values_options_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OneofOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OneofOptions) ProtoMessage() {}
+
+func (x *OneofOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_protogo_values_options_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OneofOptions.ProtoReflect.Descriptor instead.
+func (*OneofOptions) Descriptor() ([]byte, []int) {
+	return file_proto_protogo_values_options_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *OneofOptions) GetValueOneof() bool {
+	if x != nil && x.ValueOneof != nil {
+		return *x.ValueOneof
+	}
+	return false
+}
+
+// MessageOptions controls code generated for a message.
+//
+// Example usage:
+//
+//	message Account {
+//	  option (protogo_values.message_opts) = { mirror: true, presence: PRESENCE_STYLE_HAS_FLAG };
+//	  optional string nickname = 1;
+//	}
+type MessageOptions struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// mirror generates a plain Go mirror struct with ToMirror/ToProto
+	// converters even when no field requires one; see FieldOptions.nullable.
+	Mirror *bool `protobuf:"varint,1,opt,name=mirror,proto3,oneof" json:"mirror,omitempty"`
+	// presence selects the representation of explicit-presence scalars in
+	// the message's mirror struct. Converters round-trip presence exactly.
+	Presence      *PresenceStyle `protobuf:"varint,2,opt,name=presence,proto3,enum=protogo_values.PresenceStyle,oneof" json:"presence,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MessageOptions) Reset() {
+	*x = MessageOptions{}
+	mi := &file_proto_protogo_values_options_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MessageOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessageOptions) ProtoMessage() {}
+
+func (x *MessageOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_protogo_values_options_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessageOptions.ProtoReflect.Descriptor instead.
+func (*MessageOptions) Descriptor() ([]byte, []int) {
+	return file_proto_protogo_values_options_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *MessageOptions) GetMirror() bool {
+	if x != nil && x.Mirror != nil {
+		return *x.Mirror
+	}
+	return false
+}
+
+func (x *MessageOptions) GetPresence() PresenceStyle {
+	if x != nil && x.Presence != nil {
+		return *x.Presence
+	}
+	return PresenceStyle_PRESENCE_STYLE_UNSPECIFIED
+}
+
 var file_proto_protogo_values_options_proto_extTypes = []protoimpl.ExtensionInfo{
 	{
 		ExtendedType:  (*descriptorpb.FieldOptions)(nil),
@@ -98,6 +562,30 @@ var file_proto_protogo_values_options_proto_extTypes = []protoimpl.ExtensionInfo
 		Tag:           "bytes,50002,opt,name=field_opts",
 		Filename:      "proto/protogo_values/options.proto",
 	},
+	{
+		ExtendedType:  (*descriptorpb.FileOptions)(nil),
+		ExtensionType: (*FileOptions)(nil),
+		Field:         50003,
+		Name:          "protogo_values.file_opts",
+		Tag:           "bytes,50003,opt,name=file_opts",
+		Filename:      "proto/protogo_values/options.proto",
+	},
+	{
+		ExtendedType:  (*descriptorpb.OneofOptions)(nil),
+		ExtensionType: (*OneofOptions)(nil),
+		Field:         50004,
+		Name:          "protogo_values.oneof_opts",
+		Tag:           "bytes,50004,opt,name=oneof_opts",
+		Filename:      "proto/protogo_values/options.proto",
+	},
+	{
+		ExtendedType:  (*descriptorpb.MessageOptions)(nil),
+		ExtensionType: (*MessageOptions)(nil),
+		Field:         50005,
+		Name:          "protogo_values.message_opts",
+		Tag:           "bytes,50005,opt,name=message_opts",
+		Filename:      "proto/protogo_values/options.proto",
+	},
 }
 
 // Extension fields to descriptorpb.FieldOptions.
@@ -108,19 +596,91 @@ var (
 	E_FieldOpts = &file_proto_protogo_values_options_proto_extTypes[1]
 )
 
+// Extension fields to descriptorpb.FileOptions.
+var (
+	// optional protogo_values.FileOptions file_opts = 50003;
+	E_FileOpts = &file_proto_protogo_values_options_proto_extTypes[2]
+)
+
+// Extension fields to descriptorpb.OneofOptions.
+var (
+	// optional protogo_values.OneofOptions oneof_opts = 50004;
+	E_OneofOpts = &file_proto_protogo_values_options_proto_extTypes[3]
+)
+
+// Extension fields to descriptorpb.MessageOptions.
+var (
+	// optional protogo_values.MessageOptions message_opts = 50005;
+	E_MessageOpts = &file_proto_protogo_values_options_proto_extTypes[4]
+)
+
 var File_proto_protogo_values_options_proto protoreflect.FileDescriptor
 
 const file_proto_protogo_values_options_proto_rawDesc = "" +
 	"\n" +
-	"\"proto/protogo_values/options.proto\x12\x0eprotogo_values\x1a google/protobuf/descriptor.proto\"D\n" +
+	"\"proto/protogo_values/options.proto\x12\x0eprotogo_values\x1a google/protobuf/descriptor.proto\"\x8d\x05\n" +
 	"\fFieldOptions\x12$\n" +
 	"\vvalue_slice\x18\x01 \x01(\bH\x00R\n" +
-	"valueSlice\x88\x01\x01B\x0e\n" +
-	"\f_value_slice:@\n" +
+	"valueSlice\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"omit_empty\x18\x02 \x01(\bH\x01R\tomitEmpty\x88\x01\x01\x12,\n" +
+	"\x0fvalidation_rule\x18\x03 \x01(\tH\x02R\x0evalidationRule\x88\x01\x01\x121\n" +
+	"\x12json_name_override\x18\x04 \x01(\tH\x03R\x10jsonNameOverride\x88\x01\x01\x12 \n" +
+	"\tvalue_map\x18\x05 \x01(\bH\x04R\bvalueMap\x88\x01\x01\x12\x1f\n" +
+	"\bnullable\x18\x06 \x01(\bH\x05R\bnullable\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"native_wkt\x18\a \x01(\bH\x06R\tnativeWkt\x88\x01\x01\x12\x1c\n" +
+	"\ago_type\x18\b \x01(\tH\aR\x06goType\x88\x01\x01\x12&\n" +
+	"\fgo_converter\x18\t \x01(\tH\bR\vgoConverter\x88\x01\x01\x12\x1c\n" +
+	"\ago_tags\x18\n" +
+	" \x01(\tH\tR\x06goTags\x88\x01\x01\x12S\n" +
+	"\x12value_slice_policy\x18\v \x01(\x0e2 .protogo_values.ValueSlicePolicyH\n" +
+	"R\x10valueSlicePolicy\x88\x01\x01B\x0e\n" +
+	"\f_value_sliceB\r\n" +
+	"\v_omit_emptyB\x12\n" +
+	"\x10_validation_ruleB\x15\n" +
+	"\x13_json_name_overrideB\f\n" +
+	"\n" +
+	"_value_mapB\v\n" +
+	"\t_nullableB\r\n" +
+	"\v_native_wktB\n" +
+	"\n" +
+	"\b_go_typeB\x0f\n" +
+	"\r_go_converterB\n" +
+	"\n" +
+	"\b_go_tagsB\x15\n" +
+	"\x13_value_slice_policy\"\xac\x01\n" +
+	"\vFileOptions\x12\"\n" +
+	"\n" +
+	"native_wkt\x18\x01 \x01(\bH\x00R\tnativeWkt\x88\x01\x01\x12S\n" +
+	"\x12value_slice_policy\x18\x02 \x01(\x0e2 .protogo_values.ValueSlicePolicyH\x01R\x10valueSlicePolicy\x88\x01\x01B\r\n" +
+	"\v_native_wktB\x15\n" +
+	"\x13_value_slice_policy\"D\n" +
+	"\fOneofOptions\x12$\n" +
+	"\vvalue_oneof\x18\x01 \x01(\bH\x00R\n" +
+	"valueOneof\x88\x01\x01B\x0e\n" +
+	"\f_value_oneof\"\x85\x01\n" +
+	"\x0eMessageOptions\x12\x1b\n" +
+	"\x06mirror\x18\x01 \x01(\bH\x00R\x06mirror\x88\x01\x01\x12>\n" +
+	"\bpresence\x18\x02 \x01(\x0e2\x1d.protogo_values.PresenceStyleH\x01R\bpresence\x88\x01\x01B\t\n" +
+	"\a_mirrorB\v\n" +
+	"\t_presence*t\n" +
+	"\x10ValueSlicePolicy\x12\"\n" +
+	"\x1eVALUE_SLICE_POLICY_UNSPECIFIED\x10\x00\x12\x1f\n" +
+	"\x1bVALUE_SLICE_POLICY_EXPLICIT\x10\x01\x12\x1b\n" +
+	"\x17VALUE_SLICE_POLICY_AUTO\x10\x02*h\n" +
+	"\rPresenceStyle\x12\x1e\n" +
+	"\x1aPRESENCE_STYLE_UNSPECIFIED\x10\x00\x12\x1a\n" +
+	"\x16PRESENCE_STYLE_POINTER\x10\x01\x12\x1b\n" +
+	"\x17PRESENCE_STYLE_HAS_FLAG\x10\x02:@\n" +
 	"\vvalue_slice\x12\x1d.google.protobuf.FieldOptions\x18ц\x03 \x01(\bR\n" +
 	"valueSlice:_\n" +
 	"\n" +
-	"field_opts\x12\x1d.google.protobuf.FieldOptions\x18҆\x03 \x01(\v2\x1c.protogo_values.FieldOptionsR\tfieldOpts\x88\x01\x01B>Z<github.com/benjamin-rood/protogo-values/proto/protogo_valuesb\x06proto3"
+	"field_opts\x12\x1d.google.protobuf.FieldOptions\x18҆\x03 \x01(\v2\x1c.protogo_values.FieldOptionsR\tfieldOpts\x88\x01\x01:[\n" +
+	"\tfile_opts\x12\x1c.google.protobuf.FileOptions\x18ӆ\x03 \x01(\v2\x1b.protogo_values.FileOptionsR\bfileOpts\x88\x01\x01:_\n" +
+	"\n" +
+	"oneof_opts\x12\x1d.google.protobuf.OneofOptions\x18Ԇ\x03 \x01(\v2\x1c.protogo_values.OneofOptionsR\toneofOpts\x88\x01\x01:g\n" +
+	"\fmessage_opts\x12\x1f.google.protobuf.MessageOptions\x18Ն\x03 \x01(\v2\x1e.protogo_values.MessageOptionsR\vmessageOpts\x88\x01\x01B>Z<github.com/benjamin-rood/protogo-values/proto/protogo_valuesb\x06proto3"
 
 var (
 	file_proto_protogo_values_options_proto_rawDescOnce sync.Once
@@ -134,20 +694,38 @@ func file_proto_protogo_values_options_proto_rawDescGZIP() []byte {
 	return file_proto_protogo_values_options_proto_rawDescData
 }
 
-var file_proto_protogo_values_options_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_proto_protogo_values_options_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_proto_protogo_values_options_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
 var file_proto_protogo_values_options_proto_goTypes = []any{
-	(*FieldOptions)(nil),              // 0: protogo_values.FieldOptions
-	(*descriptorpb.FieldOptions)(nil), // 1: google.protobuf.FieldOptions
+	(ValueSlicePolicy)(0),               // 0: protogo_values.ValueSlicePolicy
+	(PresenceStyle)(0),                  // 1: protogo_values.PresenceStyle
+	(*FieldOptions)(nil),                // 2: protogo_values.FieldOptions
+	(*FileOptions)(nil),                 // 3: protogo_values.FileOptions
+	(*OneofOptions)(nil),                // 4: protogo_values.OneofOptions
+	(*MessageOptions)(nil),              // 5: protogo_values.MessageOptions
+	(*descriptorpb.FieldOptions)(nil),   // 6: google.protobuf.FieldOptions
+	(*descriptorpb.FileOptions)(nil),    // 7: google.protobuf.FileOptions
+	(*descriptorpb.OneofOptions)(nil),   // 8: google.protobuf.OneofOptions
+	(*descriptorpb.MessageOptions)(nil), // 9: google.protobuf.MessageOptions
 }
 var file_proto_protogo_values_options_proto_depIdxs = []int32{
-	1, // 0: protogo_values.value_slice:extendee -> google.protobuf.FieldOptions
-	1, // 1: protogo_values.field_opts:extendee -> google.protobuf.FieldOptions
-	0, // 2: protogo_values.field_opts:type_name -> protogo_values.FieldOptions
-	3, // [3:3] is the sub-list for method output_type
-	3, // [3:3] is the sub-list for method input_type
-	2, // [2:3] is the sub-list for extension type_name
-	0, // [0:2] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	0,  // 0: protogo_values.FieldOptions.value_slice_policy:type_name -> protogo_values.ValueSlicePolicy
+	0,  // 1: protogo_values.FileOptions.value_slice_policy:type_name -> protogo_values.ValueSlicePolicy
+	1,  // 2: protogo_values.MessageOptions.presence:type_name -> protogo_values.PresenceStyle
+	6,  // 3: protogo_values.value_slice:extendee -> google.protobuf.FieldOptions
+	6,  // 4: protogo_values.field_opts:extendee -> google.protobuf.FieldOptions
+	7,  // 5: protogo_values.file_opts:extendee -> google.protobuf.FileOptions
+	8,  // 6: protogo_values.oneof_opts:extendee -> google.protobuf.OneofOptions
+	9,  // 7: protogo_values.message_opts:extendee -> google.protobuf.MessageOptions
+	2,  // 8: protogo_values.field_opts:type_name -> protogo_values.FieldOptions
+	3,  // 9: protogo_values.file_opts:type_name -> protogo_values.FileOptions
+	4,  // 10: protogo_values.oneof_opts:type_name -> protogo_values.OneofOptions
+	5,  // 11: protogo_values.message_opts:type_name -> protogo_values.MessageOptions
+	12, // [12:12] is the sub-list for method output_type
+	12, // [12:12] is the sub-list for method input_type
+	8,  // [8:12] is the sub-list for extension type_name
+	3,  // [3:8] is the sub-list for extension extendee
+	0,  // [0:3] is the sub-list for field type_name
 }
 
 func init() { file_proto_protogo_values_options_proto_init() }
@@ -156,18 +734,22 @@ func file_proto_protogo_values_options_proto_init() {
 		return
 	}
 	file_proto_protogo_values_options_proto_msgTypes[0].OneofWrappers = []any{}
+	file_proto_protogo_values_options_proto_msgTypes[1].OneofWrappers = []any{}
+	file_proto_protogo_values_options_proto_msgTypes[2].OneofWrappers = []any{}
+	file_proto_protogo_values_options_proto_msgTypes[3].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_protogo_values_options_proto_rawDesc), len(file_proto_protogo_values_options_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   1,
-			NumExtensions: 2,
+			NumEnums:      2,
+			NumMessages:   4,
+			NumExtensions: 5,
 			NumServices:   0,
 		},
 		GoTypes:           file_proto_protogo_values_options_proto_goTypes,
 		DependencyIndexes: file_proto_protogo_values_options_proto_depIdxs,
+		EnumInfos:         file_proto_protogo_values_options_proto_enumTypes,
 		MessageInfos:      file_proto_protogo_values_options_proto_msgTypes,
 		ExtensionInfos:    file_proto_protogo_values_options_proto_extTypes,
 	}.Build()
@@ -0,0 +1,35 @@
+// Package convert provides custom Go types and converters for the go_type
+// test fixtures.
+package convert
+
+// ID is a fixed-size identifier stored as bytes on the wire
+type ID [16]byte
+
+// IDFromProto converts the wire bytes of an ID, truncating or zero-padding
+// to 16 bytes
+func IDFromProto(v []byte) ID {
+	var id ID
+	copy(id[:], v)
+	return id
+}
+
+// IDToProto converts an ID to its wire bytes. The zero ID converts to nil.
+func IDToProto(v ID) []byte {
+	if v == (ID{}) {
+		return nil
+	}
+	return v[:]
+}
+
+// Cents is an amount of money in minor units
+type Cents int64
+
+// CentsFromProto converts a proto int64 amount
+func CentsFromProto(v int64) Cents {
+	return Cents(v)
+}
+
+// CentsToProto converts an amount back to a proto int64
+func CentsToProto(v Cents) int64 {
+	return int64(v)
+}